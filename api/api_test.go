@@ -0,0 +1,500 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tdeckers/sparkcli/api"
+	"github.com/tdeckers/sparkcli/sparktest"
+	"github.com/tdeckers/sparkcli/util"
+)
+
+// newClient starts a fake service and returns it with a client for it.
+func newClient(t *testing.T) (*sparktest.Server, *util.Client) {
+	server := sparktest.NewServer()
+	t.Cleanup(server.Close)
+	client, err := util.NewClient(server.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, client
+}
+
+func TestRoomService(t *testing.T) {
+	_, client := newClient(t)
+	ctx := context.Background()
+	rs := api.RoomService{Client: client}
+
+	room, err := rs.Create(ctx, "ops")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if room.Id == "" || room.Title != "ops" {
+		t.Errorf("Create() = %+v, want a room titled ops", room)
+	}
+	if _, err := rs.Update(ctx, room.Id, api.RoomUpdate{Title: "devops"}); err != nil {
+		t.Errorf("Update() error = %v", err)
+	}
+	locked := true
+	if _, err := rs.Update(ctx, room.Id, api.RoomUpdate{IsLocked: &locked}); err != nil {
+		t.Errorf("Update() to lock error = %v", err)
+	}
+	got, err := rs.Get(ctx, room.Id)
+	if err != nil || got.Title != "devops" || !got.IsLocked || got.CreatorId == "" {
+		t.Errorf("Get() = %+v, %v, want locked room devops with its creator", got, err)
+	}
+	if err := rs.Delete(ctx, room.Id); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	if _, err := rs.Get(ctx, room.Id); err == nil {
+		t.Errorf("Get() of deleted room: error = nil, want not found")
+	}
+}
+
+func TestRoomServiceListAll(t *testing.T) {
+	server, client := newClient(t)
+	ctx := context.Background()
+	server.SetPageSize(2)
+	for _, title := range []string{"a", "b", "c", "d", "e"} {
+		server.AddRoom(title)
+	}
+	team := server.AddTeam("team")
+	rooms := server.Rooms()
+	server.AddMessage(rooms[2].Id, sparktest.MeEmail, "c is active")
+
+	tests := []struct {
+		name   string
+		filter api.RoomFilter
+		want   []string
+	}{
+		{"all", api.RoomFilter{}, []string{"a", "b", "c", "d", "e", "team"}},
+		{"team", api.RoomFilter{TeamId: team.Id}, []string{"team"}},
+		{"group", api.RoomFilter{Type: "group"}, []string{"a", "b", "c", "d", "e", "team"}},
+		{"direct", api.RoomFilter{Type: "direct"}, nil},
+		{"created", api.RoomFilter{SortBy: "created"}, []string{"team", "e", "d", "c", "b", "a"}},
+		{"lastactivity", api.RoomFilter{SortBy: "lastactivity"}, []string{"c", "team", "e", "d", "b", "a"}},
+	}
+	for _, tt := range tests {
+		rooms, err := api.RoomService{Client: client}.ListAll(ctx, tt.filter)
+		if err != nil {
+			t.Errorf("%q. ListAll() error = %v", tt.name, err)
+			continue
+		}
+		var got []string
+		for _, room := range *rooms {
+			got = append(got, room.Title)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%q. ListAll() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMessageService(t *testing.T) {
+	server, client := newClient(t)
+	ctx := context.Background()
+	ms := api.MessageService{Client: client}
+	room := server.AddRoom("ops", "alice@example.com")
+	server.AddMessage(room.Id, "alice@example.com", "first")
+
+	if _, err := ms.Create(ctx, room.Id, "second"); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	msg, err := ms.CreateMarkdown(ctx, room.Id, "**third**")
+	if err != nil {
+		t.Fatalf("CreateMarkdown() error = %v", err)
+	}
+	if msg.PersonEmail != sparktest.MeEmail || msg.Markdown != "**third**" {
+		t.Errorf("CreateMarkdown() = %+v, want markdown from %s", msg, sparktest.MeEmail)
+	}
+
+	msgs, err := ms.List(ctx, room.Id, 2)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(*msgs) != 2 || (*msgs)[0].Id != msg.Id {
+		t.Errorf("List() = %+v, want the 2 newest messages, newest first", *msgs)
+	}
+
+	var pages, count int
+	server.SetPageSize(1)
+	err = ms.ListPages(ctx, room.Id, 10, func(page []api.Message) bool {
+		pages++
+		count += len(page)
+		return true
+	})
+	if err != nil || pages != 3 || count != 3 {
+		t.Errorf("ListPages() = %d pages, %d messages, %v, want 3 pages of 1", pages, count, err)
+	}
+
+	if err := ms.Delete(ctx, msg.Id); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	if got := len(server.Messages(room.Id)); got != 2 {
+		t.Errorf("Delete() left %d messages, want 2", got)
+	}
+}
+
+func TestMessageServiceFiles(t *testing.T) {
+	server, client := newClient(t)
+	ctx := context.Background()
+	room := server.AddRoom("ops")
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := ioutil.WriteFile(path, []byte("some notes"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := api.MessageService{Client: client}.CreateFiles(ctx, room.Id, "see file", "", []string{path}, nil)
+	if err != nil {
+		t.Fatalf("CreateFiles() error = %v", err)
+	}
+	if msg.Text != "see file" || len(msg.Files) != 1 {
+		t.Fatalf("CreateFiles() = %+v, want text and 1 file", msg)
+	}
+
+	info, content, err := api.FileService{Client: client}.Download(ctx, msg.Files[0])
+	if err != nil {
+		t.Fatalf("Download() error = %v", err)
+	}
+	defer content.Close()
+	data, _ := ioutil.ReadAll(content)
+	if info.Name != "notes.txt" || string(data) != "some notes" {
+		t.Errorf("Download() = %+v, %q, want notes.txt with its content", info, data)
+	}
+}
+
+func TestMessageServiceCard(t *testing.T) {
+	server, client := newClient(t)
+	ctx := context.Background()
+	room := server.AddRoom("ops")
+	card := json.RawMessage(`{"type":"AdaptiveCard","version":"1.2","actions":[{"type":"Action.Submit"}]}`)
+
+	msg, err := api.MessageService{Client: client}.CreateCard(ctx, room.Id, "Approve?", card)
+	if err != nil {
+		t.Fatalf("CreateCard() error = %v", err)
+	}
+	if len(msg.Attachments) != 1 || msg.Attachments[0].ContentType != api.CardContentType ||
+		string(msg.Attachments[0].Content) != string(card) {
+		t.Fatalf("CreateCard() = %+v, want the card attached", msg)
+	}
+
+	submitted := server.AddAttachmentAction(msg.Id, "alice@example.com", map[string]interface{}{"approved": "true"})
+	action, err := api.AttachmentActionService{Client: client}.Get(ctx, submitted.Id)
+	if err != nil {
+		t.Fatalf("AttachmentActionService.Get() error = %v", err)
+	}
+	if action.MessageId != msg.Id || action.RoomId != room.Id || action.Inputs["approved"] != "true" {
+		t.Errorf("AttachmentActionService.Get() = %+v, want the submission of the card", action)
+	}
+}
+
+func TestMemberService(t *testing.T) {
+	server, client := newClient(t)
+	ctx := context.Background()
+	ms := api.MemberService{Client: client}
+	room := server.AddRoom("ops", "alice@example.com", "bob@example.com")
+	server.SetPageSize(1)
+
+	membership, err := ms.Create(ctx, room.Id, "", "carol@example.com", true)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := ms.Create(ctx, room.Id, "", "carol@example.com", false); err == nil {
+		t.Errorf("Create() of existing member: error = nil, want conflict")
+	}
+	all, err := ms.ListAll(ctx, room.Id)
+	if err != nil {
+		t.Fatalf("ListAll() error = %v", err)
+	}
+	if len(*all) != 4 {
+		t.Errorf("ListAll() = %d memberships, want 4", len(*all))
+	}
+	updated, err := ms.Update(ctx, membership.Id, false)
+	if err != nil || updated.IsModerator {
+		t.Errorf("Update() = %+v, %v, want no moderator", updated, err)
+	}
+	if err := ms.Delete(ctx, membership.Id); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	found, err := ms.List(ctx, room.Id, "", "carol@example.com")
+	if err != nil || len(*found) != 0 {
+		t.Errorf("List() after Delete() = %+v, %v, want none", found, err)
+	}
+}
+
+func TestPeopleService(t *testing.T) {
+	server, client := newClient(t)
+	ctx := context.Background()
+	ps := api.PeopleService{Client: client}
+	alice := server.AddPerson("alice@example.com", "Alice Smith")
+
+	me, err := ps.GetMe(ctx)
+	if err != nil || me.Emails[0] != sparktest.MeEmail {
+		t.Errorf("GetMe() = %+v, %v, want %s", me, err, sparktest.MeEmail)
+	}
+	tests := []struct {
+		name        string
+		email       string
+		displayName string
+		want        int
+	}{
+		{"email", "alice@example.com", "", 1},
+		{"name", "", "alice", 1},
+		{"unknown", "nobody@example.com", "", 0},
+	}
+	for _, tt := range tests {
+		people, err := ps.List(ctx, tt.email, tt.displayName)
+		if err != nil {
+			t.Errorf("%q. List() error = %v", tt.name, err)
+			continue
+		}
+		if len(*people) != tt.want || (tt.want > 0 && (*people)[0].Id != alice.Id) {
+			t.Errorf("%q. List() = %+v, want %d", tt.name, *people, tt.want)
+		}
+	}
+}
+
+func TestPeopleServiceAdmin(t *testing.T) {
+	server, client := newClient(t)
+	ctx := context.Background()
+	ps := api.PeopleService{Client: client}
+	alice := server.AddPerson("alice@example.com", "Alice Smith")
+	bob := server.AddPerson("bob@example.com", "Bob Jones")
+	server.SetPageSize(1)
+	var manyIds []string
+	for i := 0; i < api.MaxPeopleIds; i++ {
+		manyIds = append(manyIds, fmt.Sprintf("unknown-%d", i))
+	}
+	manyIds = append(manyIds, alice.Id, bob.Id)
+
+	tests := []struct {
+		name   string
+		filter api.PeopleFilter
+		want   int
+	}{
+		{"ids", api.PeopleFilter{Ids: []string{alice.Id, bob.Id}}, 2},
+		{"more ids than a request takes", api.PeopleFilter{Ids: manyIds}, 2},
+		{"org", api.PeopleFilter{OrgId: sparktest.OrgId}, 3},
+		{"org and name", api.PeopleFilter{OrgId: sparktest.OrgId, DisplayName: "bob"}, 1},
+	}
+	for _, tt := range tests {
+		people, err := ps.ListAll(ctx, tt.filter)
+		if err != nil || len(*people) != tt.want {
+			t.Errorf("%q. ListAll() = %+v, %v, want %d people", tt.name, people, err, tt.want)
+		}
+	}
+
+	carol, err := ps.Create(ctx, api.People{Emails: []string{"carol@example.com"}, DisplayName: "Carol",
+		Roles: []string{"admin-role"}})
+	if err != nil || carol.Id == "" || carol.OrgId != sparktest.OrgId || len(carol.Roles) != 1 {
+		t.Fatalf("Create() = %+v, %v, want carol in the organization with a role", carol, err)
+	}
+	if _, err := ps.Create(ctx, api.People{Emails: []string{"carol@example.com"}}); err == nil {
+		t.Errorf("Create() of an existing person: error = nil, want a conflict")
+	}
+	carol.NickName = "CJ"
+	carol.PhoneNumbers = []api.PhoneNumber{{Type: "work", Value: "+3212345678"}}
+	if _, err := ps.Update(ctx, carol.Id, *carol); err != nil {
+		t.Errorf("Update() error = %v", err)
+	}
+	got, err := ps.Get(ctx, carol.Id)
+	if err != nil || got.NickName != "CJ" || len(got.PhoneNumbers) != 1 || got.DisplayName != "Carol" {
+		t.Errorf("Get() = %+v, %v, want the updated carol", got, err)
+	}
+	if err := ps.Delete(ctx, carol.Id); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	if _, err := ps.Get(ctx, carol.Id); err == nil {
+		t.Errorf("Get() of deleted person: error = nil, want not found")
+	}
+}
+
+func TestTeamService(t *testing.T) {
+	server, client := newClient(t)
+	ctx := context.Background()
+	ts := api.TeamService{Client: client}
+	tms := api.TeamMemberService{Client: client}
+
+	team, err := ts.Create(ctx, "platform")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := tms.Create(ctx, team.Id, "", "alice@example.com", false); err != nil {
+		t.Errorf("TeamMemberService.Create() error = %v", err)
+	}
+	members, err := tms.List(ctx, team.Id)
+	if err != nil || len(*members) != 2 {
+		t.Errorf("TeamMemberService.List() = %+v, %v, want 2 members", members, err)
+	}
+	teams, err := ts.List(ctx)
+	if err != nil || len(*teams) != 1 {
+		t.Errorf("List() = %+v, %v, want 1 team", teams, err)
+	}
+	if err := ts.Delete(ctx, team.Id); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	if got := len(server.Teams()); got != 0 {
+		t.Errorf("Delete() left %d teams, want 0", got)
+	}
+}
+
+func TestEventService(t *testing.T) {
+	server, client := newClient(t)
+	ctx := context.Background()
+	es := api.EventService{Client: client}
+	room := server.AddRoom("ops", "alice@example.com")
+	msg := server.AddMessage(room.Id, "alice@example.com", "hi there")
+	if err := (api.MessageService{Client: client}).Delete(ctx, msg.Id); err != nil {
+		t.Fatalf("MessageService.Delete() error = %v", err)
+	}
+	server.SetPageSize(1)
+
+	all, err := es.ListAll(ctx, api.EventFilter{})
+	if err != nil || len(*all) != 4 {
+		t.Fatalf("ListAll() = %+v, %v, want 4 events", all, err)
+	}
+	newest := (*all)[0]
+	if newest.Resource != "messages" || newest.Type != "deleted" {
+		t.Errorf("ListAll() starts with %v %v, want the deleted message", newest.Resource, newest.Type)
+	}
+
+	tests := []struct {
+		name   string
+		filter api.EventFilter
+		want   int
+	}{
+		{"resource", api.EventFilter{Resource: "messages"}, 2},
+		{"type", api.EventFilter{Type: "created"}, 3},
+		{"resource and type", api.EventFilter{Resource: "memberships", Type: "created"}, 2},
+		{"actor", api.EventFilter{ActorId: msg.PersonId}, 1},
+		{"from", api.EventFilter{From: newest.Created}, 1},
+		{"to", api.EventFilter{To: newest.Created}, 3},
+	}
+	for _, tt := range tests {
+		events, err := es.ListAll(ctx, tt.filter)
+		if err != nil || len(*events) != tt.want {
+			t.Errorf("%q. ListAll() = %+v, %v, want %d events", tt.name, events, err, tt.want)
+		}
+	}
+
+	first, err := es.List(ctx, api.EventFilter{}, 0)
+	if err != nil || len(*first) != 1 {
+		t.Errorf("List() = %+v, %v, want the first page of 1 event", first, err)
+	}
+	event, err := es.Get(ctx, newest.Id)
+	if err != nil || event.Id != newest.Id || len(event.Data) == 0 {
+		t.Errorf("Get() = %+v, %v, want event %v with data", event, err, newest.Id)
+	}
+}
+
+func TestAdminServices(t *testing.T) {
+	server, client := newClient(t)
+	ctx := context.Background()
+	server.SetPageSize(1)
+	messaging := server.AddLicense("Messaging", 10)
+	server.AddLicense("Meetings", 5)
+	admin := server.AddRole("Full Administrator")
+	server.AddRole("Read-only Administrator")
+	ps := api.PeopleService{Client: client}
+	if _, err := ps.Create(ctx, api.People{Emails: []string{"alice@example.com"}, Licenses: []string{messaging.Id}}); err != nil {
+		t.Fatalf("PeopleService.Create() error = %v", err)
+	}
+
+	orgs, err := api.OrganizationService{Client: client}.List(ctx)
+	if err != nil || len(*orgs) != 1 || (*orgs)[0].Id != sparktest.OrgId {
+		t.Errorf("OrganizationService.List() = %+v, %v, want %s", orgs, err, sparktest.OrgId)
+	}
+	org, err := api.OrganizationService{Client: client}.Get(ctx, sparktest.OrgId)
+	if err != nil || org.DisplayName != sparktest.OrgName {
+		t.Errorf("OrganizationService.Get() = %+v, %v, want %s", org, err, sparktest.OrgName)
+	}
+
+	ls := api.LicenseService{Client: client}
+	licenses, err := ls.List(ctx, sparktest.OrgId)
+	if err != nil || len(*licenses) != 2 {
+		t.Fatalf("LicenseService.List() = %+v, %v, want 2 licenses", licenses, err)
+	}
+	license, err := ls.Get(ctx, messaging.Id)
+	if err != nil || license.ConsumedUnits != 1 || license.TotalUnits != 10 {
+		t.Errorf("LicenseService.Get() = %+v, %v, want 1 of 10 units consumed", license, err)
+	}
+
+	roles, err := api.RoleService{Client: client}.List(ctx)
+	if err != nil || len(*roles) != 2 {
+		t.Errorf("RoleService.List() = %+v, %v, want 2 roles", roles, err)
+	}
+	role, err := api.RoleService{Client: client}.Get(ctx, admin.Id)
+	if err != nil || role.Name != "Full Administrator" {
+		t.Errorf("RoleService.Get() = %+v, %v, want Full Administrator", role, err)
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		status         int
+		wantRetryAfter time.Duration
+	}{
+		{"server error", 500, 0},
+		{"unavailable", 503, 0},
+		{"rate limited", 429, time.Second},
+	}
+	for _, tt := range tests {
+		server, client := newClient(t)
+		server.Fail("GET", "/rooms", tt.status, 1)
+		_, err := api.RoomService{Client: client}.List(context.Background())
+		apiErr, ok := err.(*util.APIError)
+		if !ok {
+			t.Errorf("%q. List() error = %v, want an APIError", tt.name, err)
+			continue
+		}
+		if apiErr.StatusCode != tt.status || apiErr.RetryAfter != tt.wantRetryAfter {
+			t.Errorf("%q. List() error = %d (retry after %s), want %d (retry after %s)", tt.name,
+				apiErr.StatusCode, apiErr.RetryAfter, tt.status, tt.wantRetryAfter)
+		}
+		// The failure is used up.
+		if _, err := (api.RoomService{Client: client}).List(context.Background()); err != nil {
+			t.Errorf("%q. second List() error = %v, want nil", tt.name, err)
+		}
+	}
+}
+
+func TestTokenRefresh(t *testing.T) {
+	server, client := newClient(t)
+	room := server.AddRoom("ops")
+	server.ExpireToken()
+
+	if _, err := (api.RoomService{Client: client}).Get(context.Background(), room.Id); err != nil {
+		t.Errorf("Get() with an expired token: error = %v, want a refreshed token", err)
+	}
+	if got := strings.Join(server.Requests(), ", "); !strings.Contains(got, "POST /access_token") {
+		t.Errorf("Get() with an expired token sent %s, want a token request", got)
+	}
+}
+
+func TestClientsWithDifferentConfigs(t *testing.T) {
+	ctx := context.Background()
+	var clients []*util.Client
+	for _, email := range []string{"alice@example.com", "bob@example.com"} {
+		server := sparktest.NewServer()
+		defer server.Close()
+		server.AddRoom("room of " + email)
+		client, err := util.NewClient(server.Config())
+		if err != nil {
+			t.Fatal(err)
+		}
+		clients = append(clients, client)
+	}
+	for i, want := range []string{"room of alice@example.com", "room of bob@example.com"} {
+		rooms, err := api.RoomService{Client: clients[i]}.List(ctx)
+		if err != nil || len(*rooms) != 1 || (*rooms)[0].Title != want {
+			t.Errorf("client %d: List() = %+v, %v, want %q", i, rooms, err, want)
+		}
+	}
+}
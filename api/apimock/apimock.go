@@ -0,0 +1,571 @@
+// Package apimock provides mocks of the service interfaces in package api, to
+// test code using the services without HTTP.  Every mock has a function field
+// per method (the method name with Func appended); set the ones the test
+// needs:
+//
+//	rooms := &apimock.RoomAPI{
+//		GetFunc: func(ctx context.Context, id string) (*api.Room, error) {
+//			return &api.Room{Id: id, Title: "ops"}, nil
+//		},
+//	}
+//	spark := &api.Spark{Rooms: rooms}
+//
+// Calling a method whose function isn't set returns an error.
+package apimock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/tdeckers/sparkcli/api"
+)
+
+// notSet returns the error of a mocked method without function.
+func notSet(method string) error {
+	return fmt.Errorf("apimock: %sFunc not set", method)
+}
+
+// NewSpark returns a Spark with an empty mock for every service.
+func NewSpark() *api.Spark {
+	return &api.Spark{
+		Rooms:             &RoomAPI{},
+		Messages:          &MessageAPI{},
+		Memberships:       &MemberAPI{},
+		People:            &PeopleAPI{},
+		Teams:             &TeamAPI{},
+		TeamMembers:       &TeamMemberAPI{},
+		Webhooks:          &WebhookAPI{},
+		Files:             &FileAPI{},
+		Events:            &EventAPI{},
+		AttachmentActions: &AttachmentActionAPI{},
+		Organizations:     &OrganizationAPI{},
+		Licenses:          &LicenseAPI{},
+		Roles:             &RoleAPI{},
+	}
+}
+
+// RoomAPI mocks api.RoomAPI.
+type RoomAPI struct {
+	ListFunc         func(ctx context.Context) (*[]api.Room, error)
+	ListAllFunc      func(ctx context.Context, filter api.RoomFilter) (*[]api.Room, error)
+	CreateFunc       func(ctx context.Context, name string) (*api.Room, error)
+	CreateInTeamFunc func(ctx context.Context, name string, teamId string) (*api.Room, error)
+	GetFunc          func(ctx context.Context, id string) (*api.Room, error)
+	UpdateFunc       func(ctx context.Context, id string, update api.RoomUpdate) (*api.Room, error)
+	DeleteFunc       func(ctx context.Context, id string) error
+}
+
+func (m *RoomAPI) List(ctx context.Context) (*[]api.Room, error) {
+	if m.ListFunc == nil {
+		return nil, notSet("RoomAPI.List")
+	}
+	return m.ListFunc(ctx)
+}
+
+func (m *RoomAPI) ListAll(ctx context.Context, filter api.RoomFilter) (*[]api.Room, error) {
+	if m.ListAllFunc == nil {
+		return nil, notSet("RoomAPI.ListAll")
+	}
+	return m.ListAllFunc(ctx, filter)
+}
+
+func (m *RoomAPI) Create(ctx context.Context, name string) (*api.Room, error) {
+	if m.CreateFunc == nil {
+		return nil, notSet("RoomAPI.Create")
+	}
+	return m.CreateFunc(ctx, name)
+}
+
+func (m *RoomAPI) CreateInTeam(ctx context.Context, name string, teamId string) (*api.Room, error) {
+	if m.CreateInTeamFunc == nil {
+		return nil, notSet("RoomAPI.CreateInTeam")
+	}
+	return m.CreateInTeamFunc(ctx, name, teamId)
+}
+
+func (m *RoomAPI) Get(ctx context.Context, id string) (*api.Room, error) {
+	if m.GetFunc == nil {
+		return nil, notSet("RoomAPI.Get")
+	}
+	return m.GetFunc(ctx, id)
+}
+
+func (m *RoomAPI) Update(ctx context.Context, id string, update api.RoomUpdate) (*api.Room, error) {
+	if m.UpdateFunc == nil {
+		return nil, notSet("RoomAPI.Update")
+	}
+	return m.UpdateFunc(ctx, id, update)
+}
+
+func (m *RoomAPI) Delete(ctx context.Context, id string) error {
+	if m.DeleteFunc == nil {
+		return notSet("RoomAPI.Delete")
+	}
+	return m.DeleteFunc(ctx, id)
+}
+
+// MessageAPI mocks api.MessageAPI.
+type MessageAPI struct {
+	ListFunc           func(ctx context.Context, roomId string, max int) (*[]api.Message, error)
+	ListPagesFunc      func(ctx context.Context, roomId string, max int, page func([]api.Message) bool) error
+	CreateFunc         func(ctx context.Context, roomId string, txt string) (*api.Message, error)
+	CreateMarkdownFunc func(ctx context.Context, roomId string, markdown string) (*api.Message, error)
+	CreateDirectFunc   func(ctx context.Context, toPersonEmail string, txt string) (*api.Message, error)
+	CreateFileFunc     func(ctx context.Context, roomId string, file string) (*api.Message, error)
+	CreateFilesFunc    func(ctx context.Context, roomId string, txt string, markdown string, paths []string, progress io.Writer) (*api.Message, error)
+	CreateCardFunc     func(ctx context.Context, roomId string, txt string, card json.RawMessage) (*api.Message, error)
+	GetFunc            func(ctx context.Context, id string) (*api.Message, error)
+	DeleteFunc         func(ctx context.Context, id string) error
+}
+
+func (m *MessageAPI) List(ctx context.Context, roomId string, max int) (*[]api.Message, error) {
+	if m.ListFunc == nil {
+		return nil, notSet("MessageAPI.List")
+	}
+	return m.ListFunc(ctx, roomId, max)
+}
+
+func (m *MessageAPI) ListPages(ctx context.Context, roomId string, max int, page func([]api.Message) bool) error {
+	if m.ListPagesFunc == nil {
+		return notSet("MessageAPI.ListPages")
+	}
+	return m.ListPagesFunc(ctx, roomId, max, page)
+}
+
+func (m *MessageAPI) Create(ctx context.Context, roomId string, txt string) (*api.Message, error) {
+	if m.CreateFunc == nil {
+		return nil, notSet("MessageAPI.Create")
+	}
+	return m.CreateFunc(ctx, roomId, txt)
+}
+
+func (m *MessageAPI) CreateMarkdown(ctx context.Context, roomId string, markdown string) (*api.Message, error) {
+	if m.CreateMarkdownFunc == nil {
+		return nil, notSet("MessageAPI.CreateMarkdown")
+	}
+	return m.CreateMarkdownFunc(ctx, roomId, markdown)
+}
+
+func (m *MessageAPI) CreateDirect(ctx context.Context, toPersonEmail string, txt string) (*api.Message, error) {
+	if m.CreateDirectFunc == nil {
+		return nil, notSet("MessageAPI.CreateDirect")
+	}
+	return m.CreateDirectFunc(ctx, toPersonEmail, txt)
+}
+
+func (m *MessageAPI) CreateFile(ctx context.Context, roomId string, file string) (*api.Message, error) {
+	if m.CreateFileFunc == nil {
+		return nil, notSet("MessageAPI.CreateFile")
+	}
+	return m.CreateFileFunc(ctx, roomId, file)
+}
+
+func (m *MessageAPI) CreateFiles(ctx context.Context, roomId string, txt string, markdown string, paths []string, progress io.Writer) (*api.Message, error) {
+	if m.CreateFilesFunc == nil {
+		return nil, notSet("MessageAPI.CreateFiles")
+	}
+	return m.CreateFilesFunc(ctx, roomId, txt, markdown, paths, progress)
+}
+
+func (m *MessageAPI) CreateCard(ctx context.Context, roomId string, txt string, card json.RawMessage) (*api.Message, error) {
+	if m.CreateCardFunc == nil {
+		return nil, notSet("MessageAPI.CreateCard")
+	}
+	return m.CreateCardFunc(ctx, roomId, txt, card)
+}
+
+func (m *MessageAPI) Get(ctx context.Context, id string) (*api.Message, error) {
+	if m.GetFunc == nil {
+		return nil, notSet("MessageAPI.Get")
+	}
+	return m.GetFunc(ctx, id)
+}
+
+func (m *MessageAPI) Delete(ctx context.Context, id string) error {
+	if m.DeleteFunc == nil {
+		return notSet("MessageAPI.Delete")
+	}
+	return m.DeleteFunc(ctx, id)
+}
+
+// MemberAPI mocks api.MemberAPI.
+type MemberAPI struct {
+	ListFunc    func(ctx context.Context, roomId string, personId string, personEmail string) (*[]api.Membership, error)
+	ListAllFunc func(ctx context.Context, roomId string) (*[]api.Membership, error)
+	CreateFunc  func(ctx context.Context, roomId, personId, personEmail string, isModerator bool) (*api.Membership, error)
+	GetFunc     func(ctx context.Context, id string) (*api.Membership, error)
+	UpdateFunc  func(ctx context.Context, id string, isModerator bool) (*api.Membership, error)
+	DeleteFunc  func(ctx context.Context, id string) error
+}
+
+func (m *MemberAPI) List(ctx context.Context, roomId string, personId string, personEmail string) (*[]api.Membership, error) {
+	if m.ListFunc == nil {
+		return nil, notSet("MemberAPI.List")
+	}
+	return m.ListFunc(ctx, roomId, personId, personEmail)
+}
+
+func (m *MemberAPI) ListAll(ctx context.Context, roomId string) (*[]api.Membership, error) {
+	if m.ListAllFunc == nil {
+		return nil, notSet("MemberAPI.ListAll")
+	}
+	return m.ListAllFunc(ctx, roomId)
+}
+
+func (m *MemberAPI) Create(ctx context.Context, roomId, personId, personEmail string, isModerator bool) (*api.Membership, error) {
+	if m.CreateFunc == nil {
+		return nil, notSet("MemberAPI.Create")
+	}
+	return m.CreateFunc(ctx, roomId, personId, personEmail, isModerator)
+}
+
+func (m *MemberAPI) Get(ctx context.Context, id string) (*api.Membership, error) {
+	if m.GetFunc == nil {
+		return nil, notSet("MemberAPI.Get")
+	}
+	return m.GetFunc(ctx, id)
+}
+
+func (m *MemberAPI) Update(ctx context.Context, id string, isModerator bool) (*api.Membership, error) {
+	if m.UpdateFunc == nil {
+		return nil, notSet("MemberAPI.Update")
+	}
+	return m.UpdateFunc(ctx, id, isModerator)
+}
+
+func (m *MemberAPI) Delete(ctx context.Context, id string) error {
+	if m.DeleteFunc == nil {
+		return notSet("MemberAPI.Delete")
+	}
+	return m.DeleteFunc(ctx, id)
+}
+
+// PeopleAPI mocks api.PeopleAPI.
+type PeopleAPI struct {
+	ListFunc    func(ctx context.Context, email string, displayName string) (*[]api.People, error)
+	ListAllFunc func(ctx context.Context, filter api.PeopleFilter) (*[]api.People, error)
+	GetFunc     func(ctx context.Context, id string) (*api.People, error)
+	GetMeFunc   func(ctx context.Context) (*api.People, error)
+	CreateFunc  func(ctx context.Context, person api.People) (*api.People, error)
+	UpdateFunc  func(ctx context.Context, id string, person api.People) (*api.People, error)
+	DeleteFunc  func(ctx context.Context, id string) error
+}
+
+func (m *PeopleAPI) List(ctx context.Context, email string, displayName string) (*[]api.People, error) {
+	if m.ListFunc == nil {
+		return nil, notSet("PeopleAPI.List")
+	}
+	return m.ListFunc(ctx, email, displayName)
+}
+
+func (m *PeopleAPI) ListAll(ctx context.Context, filter api.PeopleFilter) (*[]api.People, error) {
+	if m.ListAllFunc == nil {
+		return nil, notSet("PeopleAPI.ListAll")
+	}
+	return m.ListAllFunc(ctx, filter)
+}
+
+func (m *PeopleAPI) Get(ctx context.Context, id string) (*api.People, error) {
+	if m.GetFunc == nil {
+		return nil, notSet("PeopleAPI.Get")
+	}
+	return m.GetFunc(ctx, id)
+}
+
+func (m *PeopleAPI) GetMe(ctx context.Context) (*api.People, error) {
+	if m.GetMeFunc == nil {
+		return nil, notSet("PeopleAPI.GetMe")
+	}
+	return m.GetMeFunc(ctx)
+}
+
+func (m *PeopleAPI) Create(ctx context.Context, person api.People) (*api.People, error) {
+	if m.CreateFunc == nil {
+		return nil, notSet("PeopleAPI.Create")
+	}
+	return m.CreateFunc(ctx, person)
+}
+
+func (m *PeopleAPI) Update(ctx context.Context, id string, person api.People) (*api.People, error) {
+	if m.UpdateFunc == nil {
+		return nil, notSet("PeopleAPI.Update")
+	}
+	return m.UpdateFunc(ctx, id, person)
+}
+
+func (m *PeopleAPI) Delete(ctx context.Context, id string) error {
+	if m.DeleteFunc == nil {
+		return notSet("PeopleAPI.Delete")
+	}
+	return m.DeleteFunc(ctx, id)
+}
+
+// TeamAPI mocks api.TeamAPI.
+type TeamAPI struct {
+	ListFunc   func(ctx context.Context) (*[]api.Team, error)
+	CreateFunc func(ctx context.Context, name string) (*api.Team, error)
+	GetFunc    func(ctx context.Context, id string) (*api.Team, error)
+	UpdateFunc func(ctx context.Context, id string, name string) (*api.Team, error)
+	DeleteFunc func(ctx context.Context, id string) error
+}
+
+func (m *TeamAPI) List(ctx context.Context) (*[]api.Team, error) {
+	if m.ListFunc == nil {
+		return nil, notSet("TeamAPI.List")
+	}
+	return m.ListFunc(ctx)
+}
+
+func (m *TeamAPI) Create(ctx context.Context, name string) (*api.Team, error) {
+	if m.CreateFunc == nil {
+		return nil, notSet("TeamAPI.Create")
+	}
+	return m.CreateFunc(ctx, name)
+}
+
+func (m *TeamAPI) Get(ctx context.Context, id string) (*api.Team, error) {
+	if m.GetFunc == nil {
+		return nil, notSet("TeamAPI.Get")
+	}
+	return m.GetFunc(ctx, id)
+}
+
+func (m *TeamAPI) Update(ctx context.Context, id string, name string) (*api.Team, error) {
+	if m.UpdateFunc == nil {
+		return nil, notSet("TeamAPI.Update")
+	}
+	return m.UpdateFunc(ctx, id, name)
+}
+
+func (m *TeamAPI) Delete(ctx context.Context, id string) error {
+	if m.DeleteFunc == nil {
+		return notSet("TeamAPI.Delete")
+	}
+	return m.DeleteFunc(ctx, id)
+}
+
+// TeamMemberAPI mocks api.TeamMemberAPI.
+type TeamMemberAPI struct {
+	ListFunc   func(ctx context.Context, teamId string) (*[]api.TeamMembership, error)
+	CreateFunc func(ctx context.Context, teamId, personId, personEmail string, isModerator bool) (*api.TeamMembership, error)
+	UpdateFunc func(ctx context.Context, id string, isModerator bool) (*api.TeamMembership, error)
+	DeleteFunc func(ctx context.Context, id string) error
+}
+
+func (m *TeamMemberAPI) List(ctx context.Context, teamId string) (*[]api.TeamMembership, error) {
+	if m.ListFunc == nil {
+		return nil, notSet("TeamMemberAPI.List")
+	}
+	return m.ListFunc(ctx, teamId)
+}
+
+func (m *TeamMemberAPI) Create(ctx context.Context, teamId, personId, personEmail string, isModerator bool) (*api.TeamMembership, error) {
+	if m.CreateFunc == nil {
+		return nil, notSet("TeamMemberAPI.Create")
+	}
+	return m.CreateFunc(ctx, teamId, personId, personEmail, isModerator)
+}
+
+func (m *TeamMemberAPI) Update(ctx context.Context, id string, isModerator bool) (*api.TeamMembership, error) {
+	if m.UpdateFunc == nil {
+		return nil, notSet("TeamMemberAPI.Update")
+	}
+	return m.UpdateFunc(ctx, id, isModerator)
+}
+
+func (m *TeamMemberAPI) Delete(ctx context.Context, id string) error {
+	if m.DeleteFunc == nil {
+		return notSet("TeamMemberAPI.Delete")
+	}
+	return m.DeleteFunc(ctx, id)
+}
+
+// WebhookAPI mocks api.WebhookAPI.
+type WebhookAPI struct {
+	ListFunc   func(ctx context.Context) (*[]api.Webhook, error)
+	CreateFunc func(ctx context.Context, hook api.Webhook) (*api.Webhook, error)
+	GetFunc    func(ctx context.Context, id string) (*api.Webhook, error)
+	UpdateFunc func(ctx context.Context, id string, name string, targetUrl string) (*api.Webhook, error)
+	DeleteFunc func(ctx context.Context, id string) error
+}
+
+func (m *WebhookAPI) List(ctx context.Context) (*[]api.Webhook, error) {
+	if m.ListFunc == nil {
+		return nil, notSet("WebhookAPI.List")
+	}
+	return m.ListFunc(ctx)
+}
+
+func (m *WebhookAPI) Create(ctx context.Context, hook api.Webhook) (*api.Webhook, error) {
+	if m.CreateFunc == nil {
+		return nil, notSet("WebhookAPI.Create")
+	}
+	return m.CreateFunc(ctx, hook)
+}
+
+func (m *WebhookAPI) Get(ctx context.Context, id string) (*api.Webhook, error) {
+	if m.GetFunc == nil {
+		return nil, notSet("WebhookAPI.Get")
+	}
+	return m.GetFunc(ctx, id)
+}
+
+func (m *WebhookAPI) Update(ctx context.Context, id string, name string, targetUrl string) (*api.Webhook, error) {
+	if m.UpdateFunc == nil {
+		return nil, notSet("WebhookAPI.Update")
+	}
+	return m.UpdateFunc(ctx, id, name, targetUrl)
+}
+
+func (m *WebhookAPI) Delete(ctx context.Context, id string) error {
+	if m.DeleteFunc == nil {
+		return notSet("WebhookAPI.Delete")
+	}
+	return m.DeleteFunc(ctx, id)
+}
+
+// FileAPI mocks api.FileAPI.
+type FileAPI struct {
+	InfoFunc     func(ctx context.Context, fileUrl string) (*api.FileInfo, error)
+	DownloadFunc func(ctx context.Context, fileUrl string) (*api.FileInfo, io.ReadCloser, error)
+}
+
+func (m *FileAPI) Info(ctx context.Context, fileUrl string) (*api.FileInfo, error) {
+	if m.InfoFunc == nil {
+		return nil, notSet("FileAPI.Info")
+	}
+	return m.InfoFunc(ctx, fileUrl)
+}
+
+func (m *FileAPI) Download(ctx context.Context, fileUrl string) (*api.FileInfo, io.ReadCloser, error) {
+	if m.DownloadFunc == nil {
+		return nil, nil, notSet("FileAPI.Download")
+	}
+	return m.DownloadFunc(ctx, fileUrl)
+}
+
+// EventAPI mocks api.EventAPI.
+type EventAPI struct {
+	ListFunc      func(ctx context.Context, filter api.EventFilter, max int) (*[]api.Event, error)
+	ListAllFunc   func(ctx context.Context, filter api.EventFilter) (*[]api.Event, error)
+	ListPagesFunc func(ctx context.Context, filter api.EventFilter, max int, page func([]api.Event) bool) error
+	GetFunc       func(ctx context.Context, id string) (*api.Event, error)
+}
+
+func (m *EventAPI) List(ctx context.Context, filter api.EventFilter, max int) (*[]api.Event, error) {
+	if m.ListFunc == nil {
+		return nil, notSet("EventAPI.List")
+	}
+	return m.ListFunc(ctx, filter, max)
+}
+
+func (m *EventAPI) ListAll(ctx context.Context, filter api.EventFilter) (*[]api.Event, error) {
+	if m.ListAllFunc == nil {
+		return nil, notSet("EventAPI.ListAll")
+	}
+	return m.ListAllFunc(ctx, filter)
+}
+
+func (m *EventAPI) ListPages(ctx context.Context, filter api.EventFilter, max int, page func([]api.Event) bool) error {
+	if m.ListPagesFunc == nil {
+		return notSet("EventAPI.ListPages")
+	}
+	return m.ListPagesFunc(ctx, filter, max, page)
+}
+
+func (m *EventAPI) Get(ctx context.Context, id string) (*api.Event, error) {
+	if m.GetFunc == nil {
+		return nil, notSet("EventAPI.Get")
+	}
+	return m.GetFunc(ctx, id)
+}
+
+// AttachmentActionAPI mocks api.AttachmentActionAPI.
+type AttachmentActionAPI struct {
+	GetFunc func(ctx context.Context, id string) (*api.AttachmentAction, error)
+}
+
+func (m *AttachmentActionAPI) Get(ctx context.Context, id string) (*api.AttachmentAction, error) {
+	if m.GetFunc == nil {
+		return nil, notSet("AttachmentActionAPI.Get")
+	}
+	return m.GetFunc(ctx, id)
+}
+
+// OrganizationAPI mocks api.OrganizationAPI.
+type OrganizationAPI struct {
+	ListFunc func(ctx context.Context) (*[]api.Organization, error)
+	GetFunc  func(ctx context.Context, id string) (*api.Organization, error)
+}
+
+func (m *OrganizationAPI) List(ctx context.Context) (*[]api.Organization, error) {
+	if m.ListFunc == nil {
+		return nil, notSet("OrganizationAPI.List")
+	}
+	return m.ListFunc(ctx)
+}
+
+func (m *OrganizationAPI) Get(ctx context.Context, id string) (*api.Organization, error) {
+	if m.GetFunc == nil {
+		return nil, notSet("OrganizationAPI.Get")
+	}
+	return m.GetFunc(ctx, id)
+}
+
+// LicenseAPI mocks api.LicenseAPI.
+type LicenseAPI struct {
+	ListFunc func(ctx context.Context, orgId string) (*[]api.License, error)
+	GetFunc  func(ctx context.Context, id string) (*api.License, error)
+}
+
+func (m *LicenseAPI) List(ctx context.Context, orgId string) (*[]api.License, error) {
+	if m.ListFunc == nil {
+		return nil, notSet("LicenseAPI.List")
+	}
+	return m.ListFunc(ctx, orgId)
+}
+
+func (m *LicenseAPI) Get(ctx context.Context, id string) (*api.License, error) {
+	if m.GetFunc == nil {
+		return nil, notSet("LicenseAPI.Get")
+	}
+	return m.GetFunc(ctx, id)
+}
+
+// RoleAPI mocks api.RoleAPI.
+type RoleAPI struct {
+	ListFunc func(ctx context.Context) (*[]api.Role, error)
+	GetFunc  func(ctx context.Context, id string) (*api.Role, error)
+}
+
+func (m *RoleAPI) List(ctx context.Context) (*[]api.Role, error) {
+	if m.ListFunc == nil {
+		return nil, notSet("RoleAPI.List")
+	}
+	return m.ListFunc(ctx)
+}
+
+func (m *RoleAPI) Get(ctx context.Context, id string) (*api.Role, error) {
+	if m.GetFunc == nil {
+		return nil, notSet("RoleAPI.Get")
+	}
+	return m.GetFunc(ctx, id)
+}
+
+var (
+	_ api.RoomAPI             = &RoomAPI{}
+	_ api.MessageAPI          = &MessageAPI{}
+	_ api.MemberAPI           = &MemberAPI{}
+	_ api.PeopleAPI           = &PeopleAPI{}
+	_ api.TeamAPI             = &TeamAPI{}
+	_ api.TeamMemberAPI       = &TeamMemberAPI{}
+	_ api.WebhookAPI          = &WebhookAPI{}
+	_ api.FileAPI             = &FileAPI{}
+	_ api.EventAPI            = &EventAPI{}
+	_ api.AttachmentActionAPI = &AttachmentActionAPI{}
+	_ api.OrganizationAPI     = &OrganizationAPI{}
+	_ api.LicenseAPI          = &LicenseAPI{}
+	_ api.RoleAPI             = &RoleAPI{}
+)
@@ -0,0 +1,53 @@
+package apimock
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/tdeckers/sparkcli/api"
+)
+
+func TestMock(t *testing.T) {
+	ctx := context.Background()
+	rooms := &RoomAPI{
+		GetFunc: func(ctx context.Context, id string) (*api.Room, error) {
+			return &api.Room{Id: id, Title: "ops"}, nil
+		},
+	}
+	spark := &api.Spark{Rooms: rooms}
+	if room, err := spark.Rooms.Get(ctx, "1"); err != nil || room.Title != "ops" {
+		t.Errorf("Get() = %+v, %v, want the mocked room", room, err)
+	}
+	if err := spark.Rooms.Delete(ctx, "1"); err == nil {
+		t.Errorf("Delete() without DeleteFunc: error = nil, want an error")
+	}
+}
+
+// TestNotSet calls every method of the mocks in NewSpark, without setting
+// any function, and checks they all return their not set error.
+func TestNotSet(t *testing.T) {
+	spark := reflect.ValueOf(NewSpark()).Elem()
+	ctx := reflect.ValueOf(context.Background())
+	for i := 0; i < spark.NumField(); i++ {
+		mock := spark.Field(i).Elem()
+		if !mock.IsValid() {
+			t.Errorf("NewSpark() has no mock for %s", spark.Type().Field(i).Name)
+			continue
+		}
+		name := mock.Elem().Type().Name()
+		for j := 0; j < mock.NumMethod(); j++ {
+			method := mock.Type().Method(j)
+			args := []reflect.Value{ctx}
+			for k := 1; k < method.Type.NumIn()-1; k++ {
+				args = append(args, reflect.Zero(method.Type.In(k+1)))
+			}
+			results := mock.Method(j).Call(args)
+			err, _ := results[len(results)-1].Interface().(error)
+			want := "apimock: " + name + "." + method.Name + "Func not set"
+			if err == nil || err.Error() != want {
+				t.Errorf("%s.%s() error = %v, want %q", name, method.Name, err, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"context"
+	"errors"
+
+	"github.com/tdeckers/sparkcli/util"
+)
+
+// AttachmentActionService gives access to attachment actions: what people
+// submitted with the buttons of a card.  A webhook for the attachmentActions
+// resource gives the id of every new action.
+type AttachmentActionService struct {
+	Client *util.Client
+}
+
+type AttachmentAction struct {
+	Id        string `json:"id,omitempty"`
+	Type      string `json:"type,omitempty"`
+	MessageId string `json:"messageId,omitempty"`
+	// Inputs holds the values of the inputs of the card, by input id, and
+	// the data of the submit action.
+	Inputs   map[string]interface{} `json:"inputs,omitempty"`
+	PersonId string                 `json:"personId,omitempty"`
+	RoomId   string                 `json:"roomId,omitempty"`
+	Created  string                 `json:"created,omitempty"`
+}
+
+func (a AttachmentActionService) Get(ctx context.Context, id string) (*AttachmentAction, error) {
+	if id == "" {
+		return nil, errors.New("id can't be empty when getting an attachment action")
+	}
+	req, err := a.Client.NewGetRequest("/attachment/actions/" + id)
+	if err != nil {
+		return nil, err
+	}
+	var result AttachmentAction
+	_, err = a.Client.Do(ctx, req, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
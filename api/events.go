@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+
+	"github.com/tdeckers/sparkcli/util"
+)
+
+// EventService gives access to events: what happened to messages and
+// memberships (created, updated, deleted) across the organization.  Reading
+// events needs a compliance officer account.
+type EventService struct {
+	Client *util.Client
+}
+
+type Event struct {
+	Id       string `json:"id,omitempty"`
+	Resource string `json:"resource,omitempty"`
+	Type     string `json:"type,omitempty"`
+	AppId    string `json:"appId,omitempty"`
+	ActorId  string `json:"actorId,omitempty"`
+	OrgId    string `json:"orgId,omitempty"`
+	Created  string `json:"created,omitempty"`
+	// Data holds the message or membership the event is about, as it was
+	// after the event.
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+type EventItems struct {
+	Items []Event `json:"items"`
+}
+
+// EventFilter selects events.  Empty fields match all events.
+type EventFilter struct {
+	// Resource is messages or memberships.
+	Resource string
+	// Type is created, updated or deleted.
+	Type    string
+	ActorId string
+	// From and To (ISO 8601, e.g. 2016-06-01T09:00:00.000Z) limit the time
+	// of the events.
+	From string
+	To   string
+}
+
+// values returns the query parameters for filter.
+func (f EventFilter) values() url.Values {
+	v := url.Values{}
+	if f.Resource != "" {
+		v.Add("resource", f.Resource)
+	}
+	if f.Type != "" {
+		v.Add("type", f.Type)
+	}
+	if f.ActorId != "" {
+		v.Add("actorId", f.ActorId)
+	}
+	if f.From != "" {
+		v.Add("from", f.From)
+	}
+	if f.To != "" {
+		v.Add("to", f.To)
+	}
+	return v
+}
+
+// List returns the most recent events matching filter, newest first.  When
+// max is larger than 0, at most max events are returned.
+func (e EventService) List(ctx context.Context, filter EventFilter, max int) (*[]Event, error) {
+	var events []Event
+	err := e.ListPages(ctx, filter, max, func(page []Event) bool {
+		events = page
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &events, nil
+}
+
+// ListAll requests all events matching filter, newest first, following the
+// pages of the response.
+func (e EventService) ListAll(ctx context.Context, filter EventFilter) (*[]Event, error) {
+	var all []Event
+	err := e.ListPages(ctx, filter, 1000, func(page []Event) bool {
+		all = append(all, page...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &all, nil
+}
+
+// ListPages requests the events matching filter, newest first, in pages of
+// max events.  Every page is passed to page, until it returns false or there
+// are no more events.
+func (e EventService) ListPages(ctx context.Context, filter EventFilter, max int, page func([]Event) bool) error {
+	v := filter.values()
+	if max > 0 {
+		v.Add("max", strconv.Itoa(max))
+	}
+	path := "/events?" + v.Encode()
+	for path != "" {
+		req, err := e.Client.NewGetRequest(path)
+		if err != nil {
+			return err
+		}
+		var result EventItems
+		res, err := e.Client.Do(ctx, req, &result)
+		if err != nil {
+			return err
+		}
+		if !page(result.Items) {
+			return nil
+		}
+		path = util.NextLink(res)
+	}
+	return nil
+}
+
+func (e EventService) Get(ctx context.Context, id string) (*Event, error) {
+	if id == "" {
+		return nil, errors.New("id can't be empty when getting an event")
+	}
+	req, err := e.Client.NewGetRequest("/events/" + id)
+	if err != nil {
+		return nil, err
+	}
+	var result Event
+	_, err = e.Client.Do(ctx, req, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
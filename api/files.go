@@ -0,0 +1,80 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+
+	"github.com/tdeckers/sparkcli/util"
+)
+
+// FileService gives access to the files attached to messages.  Files are
+// identified by the urls in Message.Files.
+type FileService struct {
+	Client *util.Client
+}
+
+// FileInfo describes an attached file.
+type FileInfo struct {
+	Url         string `json:"url"`
+	Name        string `json:"name"`
+	ContentType string `json:"contentType,omitempty"`
+	Size        int64  `json:"size"`
+}
+
+// Info returns the details of the file at fileUrl, without downloading it.
+func (f FileService) Info(ctx context.Context, fileUrl string) (*FileInfo, error) {
+	if fileUrl == "" {
+		return nil, errors.New("url can't be empty when getting a file")
+	}
+	req, err := f.Client.NewHeadRequest(fileUrl)
+	if err != nil {
+		return nil, err
+	}
+	res, err := f.Client.DoStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+	return newFileInfo(fileUrl, res), nil
+}
+
+// Download returns the details and the content of the file at fileUrl.  The
+// caller must close the content.
+func (f FileService) Download(ctx context.Context, fileUrl string) (*FileInfo, io.ReadCloser, error) {
+	if fileUrl == "" {
+		return nil, nil, errors.New("url can't be empty when downloading a file")
+	}
+	req, err := f.Client.NewGetRequest(fileUrl)
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := f.Client.DoStream(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newFileInfo(fileUrl, res), res.Body, nil
+}
+
+// newFileInfo takes the file details from the response headers.  The name
+// comes from Content-Disposition, or the url if that's missing.
+func newFileInfo(fileUrl string, res *http.Response) *FileInfo {
+	info := &FileInfo{Url: fileUrl, ContentType: res.Header.Get("Content-Type")}
+	info.Size, _ = strconv.ParseInt(res.Header.Get("Content-Length"), 10, 64)
+	if _, params, err := mime.ParseMediaType(res.Header.Get("Content-Disposition")); err == nil {
+		info.Name = params["filename"]
+	}
+	if info.Name == "" {
+		if u, err := url.Parse(fileUrl); err == nil {
+			info.Name = path.Base(u.Path)
+		}
+	}
+	// Never trust a name to be a plain file name.
+	info.Name = path.Base("/" + info.Name)
+	return info
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/url"
+
+	"github.com/tdeckers/sparkcli/util"
+)
+
+// LicenseService gives access to the licenses of an organization, and how
+// many of them are assigned.
+type LicenseService struct {
+	Client *util.Client
+}
+
+type License struct {
+	Id   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+	// TotalUnits is the number of licenses bought, ConsumedUnits the number
+	// assigned to people.
+	TotalUnits     int    `json:"totalUnits"`
+	ConsumedUnits  int    `json:"consumedUnits"`
+	SubscriptionId string `json:"subscriptionId,omitempty"`
+	SiteUrl        string `json:"siteUrl,omitempty"`
+}
+
+type LicenseItems struct {
+	Items []License `json:"items"`
+}
+
+// List requests all licenses of the organization with id orgId, or of your
+// own organization if orgId is empty, following the pages of the response.
+func (l LicenseService) List(ctx context.Context, orgId string) (*[]License, error) {
+	v := url.Values{}
+	if orgId != "" {
+		v.Add("orgId", orgId)
+	}
+	v.Add("max", "1000")
+	path := "/licenses?" + v.Encode()
+	var all []License
+	for path != "" {
+		req, err := l.Client.NewGetRequest(path)
+		if err != nil {
+			return nil, err
+		}
+		var result LicenseItems
+		res, err := l.Client.Do(ctx, req, &result)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, result.Items...)
+		path = util.NextLink(res)
+	}
+	return &all, nil
+}
+
+func (l LicenseService) Get(ctx context.Context, id string) (*License, error) {
+	if id == "" {
+		return nil, errors.New("id can't be empty when getting a license")
+	}
+	req, err := l.Client.NewGetRequest("/licenses/" + id)
+	if err != nil {
+		return nil, err
+	}
+	var result License
+	_, err = l.Client.Do(ctx, req, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -1,7 +1,7 @@
 package api
 
 import (
-	"errors"
+	"context"
 	"github.com/tdeckers/sparkcli/util"
 	"net/url"
 )
@@ -25,7 +25,7 @@ type MembershipItems struct {
 	Items []Membership `json:"items"`
 }
 
-func (m MemberService) List(roomId string, personId string, personEmail string) (*[]Membership, error) {
+func (m MemberService) List(ctx context.Context, roomId string, personId string, personEmail string) (*[]Membership, error) {
 	v := url.Values{}
 	if roomId != "" {
 		v.Add("roomId", roomId)
@@ -41,69 +41,85 @@ func (m MemberService) List(roomId string, personId string, personEmail string)
 		return nil, err
 	}
 	var result MembershipItems
-	_, err = m.Client.Do(req, &result)
+	_, err = m.Client.Do(ctx, req, &result)
 	if err != nil {
 		return nil, err
 	}
 	return &result.Items, nil
 }
 
-func (m MemberService) Create(roomId, personId, personEmail string) (*Membership, error) {
-	// check default room id
-	config := util.GetConfiguration()
-	if roomId == "-" {
-		if config.DefaultRoomId != "" {
-			roomId = config.DefaultRoomId
-		} else {
-			return nil, errors.New("No DefaultRoomId configured.")
+// ListAll requests all memberships of a room, following the pages of the
+// response.
+func (m MemberService) ListAll(ctx context.Context, roomId string) (*[]Membership, error) {
+	v := url.Values{}
+	v.Add("roomId", roomId)
+	v.Add("max", "1000")
+	path := "/memberships?" + v.Encode()
+	var all []Membership
+	for path != "" {
+		req, err := m.Client.NewGetRequest(path)
+		if err != nil {
+			return nil, err
+		}
+		var result MembershipItems
+		res, err := m.Client.Do(ctx, req, &result)
+		if err != nil {
+			return nil, err
 		}
+		all = append(all, result.Items...)
+		path = util.NextLink(res)
 	}
-	ms := Membership{RoomId: roomId, PersonId: personId, PersonEmail: personEmail}
+	return &all, nil
+}
+
+func (m MemberService) Create(ctx context.Context, roomId, personId, personEmail string, isModerator bool) (*Membership, error) {
+	ms := Membership{RoomId: roomId, PersonId: personId, PersonEmail: personEmail, IsModerator: isModerator}
 	req, err := m.Client.NewPostRequest("/memberships", ms)
 	if err != nil {
 		return nil, err
 	}
 	var result Membership
-	_, err = m.Client.Do(req, &result)
+	_, err = m.Client.Do(ctx, req, &result)
 	if err != nil {
 		return nil, err
 	}
 	return &result, nil
 }
 
-func (m MemberService) Get(id string) (*Membership, error) {
+func (m MemberService) Get(ctx context.Context, id string) (*Membership, error) {
 	req, err := m.Client.NewGetRequest("/memberships/" + id)
 	if err != nil {
 		return nil, err
 	}
 	var result Membership
-	_, err = m.Client.Do(req, &result)
+	_, err = m.Client.Do(ctx, req, &result)
 	if err != nil {
 		return nil, err
 	}
 	return &result, nil
 }
 
-func (m MemberService) Update(id string, isModerator bool) (*Membership, error) {
-	ms := Membership{IsModerator: isModerator}
-	req, err := m.Client.NewPutRequest("/memberships/"+id, ms)
+func (m MemberService) Update(ctx context.Context, id string, isModerator bool) (*Membership, error) {
+	// isModerator is sent explicitly, as false is a valid update.
+	body := map[string]bool{"isModerator": isModerator}
+	req, err := m.Client.NewPutRequest("/memberships/"+id, body)
 	if err != nil {
 		return nil, err
 	}
 	var result Membership
-	_, err = m.Client.Do(req, &result)
+	_, err = m.Client.Do(ctx, req, &result)
 	if err != nil {
 		return nil, err
 	}
 	return &result, nil
 }
 
-func (m MemberService) Delete(id string) error {
+func (m MemberService) Delete(ctx context.Context, id string) error {
 	req, err := m.Client.NewDeleteRequest("/memberships/" + id)
 	if err != nil {
 		return err
 	}
-	_, err = m.Client.Do(req, nil)
+	_, err = m.Client.Do(ctx, req, nil)
 	if err != nil {
 		return err
 	}
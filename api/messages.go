@@ -1,98 +1,177 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"github.com/tdeckers/sparkcli/util"
+	"io"
 	"log"
+	"net/url"
+	"strconv"
 )
 
+// MaxMessageSize is the maximum size, in bytes, of the text or markdown of a
+// message.
+const MaxMessageSize = 7439
+
+// CardContentType is the content type of an Adaptive Card attachment.
+const CardContentType = "application/vnd.microsoft.card.adaptive"
+
 type MessageService struct {
 	Client *util.Client
 }
 
 type Message struct {
-	Id            string `json:"id,omitempty"`
-	RoomId        string `json:"roomId,omitempty"`
-	Text          string `json:"text,omitempty"`
-	Files         string `json:"files,omitempty"`
-	ToPersonId    string `json:"toPersonId,omitempty"`
-	ToPersonEmail string `json:"toPersonEmail,omitempty"`
-	PersonId      string `json:"personId,omitempty"`
-	PersonEmail   string `json:"personEmail,omitempty"`
-	Created       string `json:"created,omitempty"`
+	Id            string       `json:"id,omitempty"`
+	RoomId        string       `json:"roomId,omitempty"`
+	Text          string       `json:"text,omitempty"`
+	Markdown      string       `json:"markdown,omitempty"`
+	Files         []string     `json:"files,omitempty"`
+	Attachments   []Attachment `json:"attachments,omitempty"`
+	ToPersonId    string       `json:"toPersonId,omitempty"`
+	ToPersonEmail string       `json:"toPersonEmail,omitempty"`
+	PersonId      string       `json:"personId,omitempty"`
+	PersonEmail   string       `json:"personEmail,omitempty"`
+	Created       string       `json:"created,omitempty"`
+}
+
+// Attachment is a card shown with a message.  A message has at most one.
+type Attachment struct {
+	ContentType string          `json:"contentType"`
+	Content     json.RawMessage `json:"content"`
 }
 
 type MessageItems struct {
 	Items []Message `json:"items"`
 }
 
-func (m MessageService) list() (*[]Message, error) {
+func (m MessageService) list(ctx context.Context) (*[]Message, error) {
 	log.Fatal("Not implemented")
 	return nil, nil
 }
 
-func (m MessageService) List(roomId string) (*[]Message, error) {
-	req, err := m.Client.NewGetRequest("/messages?roomId=" + roomId)
+// List returns the most recent messages in a room, newest first.  When max is
+// larger than 0, at most max messages are returned.
+func (m MessageService) List(ctx context.Context, roomId string, max int) (*[]Message, error) {
+	v := url.Values{}
+	v.Add("roomId", roomId)
+	if max > 0 {
+		v.Add("max", strconv.Itoa(max))
+	}
+	req, err := m.Client.NewGetRequest("/messages?" + v.Encode())
 	if err != nil {
 		return nil, err
 	}
 	var result MessageItems
-	_, err = m.Client.Do(req, &result)
+	_, err = m.Client.Do(ctx, req, &result)
 	if err != nil {
 		return nil, err
 	}
 	return &result.Items, nil
 }
 
-// TODO: create different version, or update, to support direct msgs.
-func (m MessageService) Create(roomId string, txt string) (*Message, error) {
-	// Check for default roomId
-	config := util.GetConfiguration()
-	if roomId == "-" {
-		if config.DefaultRoomId != "" {
-			roomId = config.DefaultRoomId
-		} else {
-			return nil, errors.New("No DefaultRoomId configured.")
+// ListPages requests all messages in a room, newest first, in pages of max
+// messages.  Every page is passed to page, until it returns false or there
+// are no more messages.
+func (m MessageService) ListPages(ctx context.Context, roomId string, max int, page func([]Message) bool) error {
+	v := url.Values{}
+	v.Add("roomId", roomId)
+	if max > 0 {
+		v.Add("max", strconv.Itoa(max))
+	}
+	path := "/messages?" + v.Encode()
+	for path != "" {
+		req, err := m.Client.NewGetRequest(path)
+		if err != nil {
+			return err
 		}
+		var result MessageItems
+		res, err := m.Client.Do(ctx, req, &result)
+		if err != nil {
+			return err
+		}
+		if !page(result.Items) {
+			return nil
+		}
+		path = util.NextLink(res)
+	}
+	return nil
+}
+
+func (m MessageService) Create(ctx context.Context, roomId string, txt string) (*Message, error) {
+	return m.create(ctx, Message{RoomId: roomId, Text: txt})
+}
+
+// CreateDirect posts a message to the person with email toPersonEmail, in
+// your direct room with that person.  The room is created if needed.
+func (m MessageService) CreateDirect(ctx context.Context, toPersonEmail string, txt string) (*Message, error) {
+	if toPersonEmail == "" {
+		return nil, errors.New("email can't be empty when sending a direct message")
+	}
+	return m.create(ctx, Message{ToPersonEmail: toPersonEmail, Text: txt})
+}
+
+// CreateMarkdown posts a message formatted with markdown.
+func (m MessageService) CreateMarkdown(ctx context.Context, roomId string, markdown string) (*Message, error) {
+	return m.create(ctx, Message{RoomId: roomId, Markdown: markdown})
+}
+
+// CreateCard posts a message with an Adaptive Card.  Clients that can't show
+// cards show txt instead.
+func (m MessageService) CreateCard(ctx context.Context, roomId string, txt string, card json.RawMessage) (*Message, error) {
+	if len(card) == 0 {
+		return nil, errors.New("card can't be empty")
 	}
+	return m.create(ctx, Message{RoomId: roomId, Text: txt,
+		Attachments: []Attachment{{ContentType: CardContentType, Content: card}}})
+}
 
-	msg := Message{RoomId: roomId, Text: txt}
+func (m MessageService) create(ctx context.Context, msg Message) (*Message, error) {
 	req, err := m.Client.NewPostRequest("/messages", msg)
 	if err != nil {
 		return nil, err
 	}
 	var result Message
-	_, err = m.Client.Do(req, &result)
+	_, err = m.Client.Do(ctx, req, &result)
 	if err != nil {
 		return nil, err
 	}
 	return &result, nil
 }
 
-func (m MessageService) CreateFile(roomId string, file string) (*Message, error) {
-	// Check for default roomId
-	config := util.GetConfiguration()
-	if roomId == "-" {
-		if config.DefaultRoomId != "" {
-			roomId = config.DefaultRoomId
-		} else {
-			return nil, errors.New("No DefaultRoomId configured.")
-		}
+func (m MessageService) CreateFile(ctx context.Context, roomId string, file string) (*Message, error) {
+	return m.CreateFiles(ctx, roomId, "", "", []string{file}, nil)
+}
+
+// CreateFiles posts a message with the files at paths attached, and an
+// optional text or markdown.  Files are streamed from disk, and their content
+// is written to progress (if not nil) as it's sent.
+func (m MessageService) CreateFiles(ctx context.Context, roomId string, txt string, markdown string, paths []string, progress io.Writer) (*Message, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("no files to send")
 	}
 
-	req, err := m.Client.NewFilePostRequest("/messages", roomId, file)
+	fields := map[string]string{"roomId": roomId}
+	if txt != "" {
+		fields["text"] = txt
+	}
+	if markdown != "" {
+		fields["markdown"] = markdown
+	}
+	req, err := m.Client.NewMultipartRequest("/messages", fields, paths, progress)
 	if err != nil {
 		return nil, err
 	}
 	var result Message
-	_, err = m.Client.Do(req, &result)
+	_, err = m.Client.Do(ctx, req, &result)
 	if err != nil {
 		return nil, err
 	}
 	return &result, nil
 }
 
-func (m MessageService) Get(id string) (*Message, error) {
+func (m MessageService) Get(ctx context.Context, id string) (*Message, error) {
 	if id == "" {
 		return nil, errors.New("id can't be empty when getting message")
 	}
@@ -101,14 +180,14 @@ func (m MessageService) Get(id string) (*Message, error) {
 		return nil, err
 	}
 	var result Message
-	_, err = m.Client.Do(req, &result)
+	_, err = m.Client.Do(ctx, req, &result)
 	if err != nil {
 		return nil, err
 	}
 	return &result, nil
 }
 
-func (m MessageService) Delete(id string) error {
+func (m MessageService) Delete(ctx context.Context, id string) error {
 	if id == "" {
 		return errors.New("id can't be empty when deleting a message")
 	}
@@ -116,7 +195,7 @@ func (m MessageService) Delete(id string) error {
 	if err != nil {
 		return err
 	}
-	_, err = m.Client.Do(req, nil)
+	_, err = m.Client.Do(ctx, req, nil)
 	if err != nil {
 		return err
 	}
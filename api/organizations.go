@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"errors"
+
+	"github.com/tdeckers/sparkcli/util"
+)
+
+// OrganizationService gives access to the organizations you can manage.
+type OrganizationService struct {
+	Client *util.Client
+}
+
+type Organization struct {
+	Id          string `json:"id,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+	Created     string `json:"created,omitempty"`
+}
+
+type OrganizationItems struct {
+	Items []Organization `json:"items"`
+}
+
+// List requests all organizations, following the pages of the response.
+func (o OrganizationService) List(ctx context.Context) (*[]Organization, error) {
+	path := "/organizations?max=1000"
+	var all []Organization
+	for path != "" {
+		req, err := o.Client.NewGetRequest(path)
+		if err != nil {
+			return nil, err
+		}
+		var result OrganizationItems
+		res, err := o.Client.Do(ctx, req, &result)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, result.Items...)
+		path = util.NextLink(res)
+	}
+	return &all, nil
+}
+
+func (o OrganizationService) Get(ctx context.Context, id string) (*Organization, error) {
+	if id == "" {
+		return nil, errors.New("id can't be empty when getting an organization")
+	}
+	req, err := o.Client.NewGetRequest("/organizations/" + id)
+	if err != nil {
+		return nil, err
+	}
+	var result Organization
+	_, err = o.Client.Do(ctx, req, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -1,9 +1,11 @@
 package api
 
 import (
+	"context"
 	"errors"
 	"github.com/tdeckers/sparkcli/util"
 	"net/url"
+	"strings"
 )
 
 type PeopleService struct {
@@ -12,18 +14,71 @@ type PeopleService struct {
 
 // People data structure
 type People struct {
-	Id          string   `json:"id,omitempty"`
-	Emails      []string `json:"emails,omitempty"`
-	DisplayName string   `json:"displayName,omitempty"`
-	Avatar      string   `json:"avatar,omitempty"`
-	Created     string   `json:"created,omitempty"`
+	Id           string        `json:"id,omitempty"`
+	Emails       []string      `json:"emails,omitempty"`
+	PhoneNumbers []PhoneNumber `json:"phoneNumbers,omitempty"`
+	DisplayName  string        `json:"displayName,omitempty"`
+	NickName     string        `json:"nickName,omitempty"`
+	FirstName    string        `json:"firstName,omitempty"`
+	LastName     string        `json:"lastName,omitempty"`
+	Avatar       string        `json:"avatar,omitempty"`
+	OrgId        string        `json:"orgId,omitempty"`
+	// Roles and Licenses hold ids, see RoleService and LicenseService.
+	Roles    []string `json:"roles,omitempty"`
+	Licenses []string `json:"licenses,omitempty"`
+	Created  string   `json:"created,omitempty"`
+	// LastActivity and Status tell if the person is around.  Status is
+	// active, inactive, DoNotDisturb, OutOfOffice, meeting, call, presenting
+	// or unknown.
+	LastActivity string `json:"lastActivity,omitempty"`
+	Status       string `json:"status,omitempty"`
+}
+
+type PhoneNumber struct {
+	// Type is work, mobile or fax.
+	Type  string `json:"type,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+// MaxPeopleIds is the number of person ids the API takes in a single list
+// request.
+const MaxPeopleIds = 85
+
+// PeopleFilter selects people.  Empty fields match all people (of your
+// organization).
+type PeopleFilter struct {
+	Email string
+	// DisplayName matches the start of names.
+	DisplayName string
+	// Ids is a list of person ids.  ListAll requests them MaxPeopleIds at a
+	// time.
+	Ids   []string
+	OrgId string
+}
+
+// values returns the query parameters for filter.
+func (f PeopleFilter) values() url.Values {
+	v := url.Values{}
+	if f.Email != "" {
+		v.Add("email", f.Email)
+	}
+	if f.DisplayName != "" {
+		v.Add("displayName", f.DisplayName)
+	}
+	if len(f.Ids) > 0 {
+		v.Add("id", strings.Join(f.Ids, ","))
+	}
+	if f.OrgId != "" {
+		v.Add("orgId", f.OrgId)
+	}
+	return v
 }
 
 type PeopleItems struct {
 	Items []People `json:"items"`
 }
 
-func (p PeopleService) List(email string, displayName string) (*[]People, error) {
+func (p PeopleService) List(ctx context.Context, email string, displayName string) (*[]People, error) {
 	if email == "" && displayName == "" {
 		// TODO: don't need to create this message.  Just return what service returns.
 		//{
@@ -50,26 +105,113 @@ func (p PeopleService) List(email string, displayName string) (*[]People, error)
 		return nil, err
 	}
 	var result PeopleItems
-	_, err = p.Client.Do(req, &result)
+	_, err = p.Client.Do(ctx, req, &result)
 	if err != nil {
 		return nil, err
 	}
 	return &result.Items, nil
 }
 
-func (p PeopleService) Get(id string) (*People, error) {
+// ListAll requests all people matching filter, following the pages of the
+// response.  Listing people without email, name or ids needs an admin account.
+func (p PeopleService) ListAll(ctx context.Context, filter PeopleFilter) (*[]People, error) {
+	if len(filter.Ids) > MaxPeopleIds {
+		var all []People
+		ids := filter.Ids
+		for len(ids) > 0 {
+			n := min(len(ids), MaxPeopleIds)
+			filter.Ids = ids[:n]
+			people, err := p.ListAll(ctx, filter)
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, *people...)
+			ids = ids[n:]
+		}
+		return &all, nil
+	}
+	v := filter.values()
+	v.Add("max", "1000")
+	path := "/people?" + v.Encode()
+	var all []People
+	for path != "" {
+		req, err := p.Client.NewGetRequest(path)
+		if err != nil {
+			return nil, err
+		}
+		var result PeopleItems
+		res, err := p.Client.Do(ctx, req, &result)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, result.Items...)
+		path = util.NextLink(res)
+	}
+	return &all, nil
+}
+
+func (p PeopleService) Get(ctx context.Context, id string) (*People, error) {
 	req, err := p.Client.NewGetRequest("/people/" + id)
 	if err != nil {
 		return nil, err
 	}
 	var result People
-	_, err = p.Client.Do(req, &result)
+	_, err = p.Client.Do(ctx, req, &result)
 	if err != nil {
 		return nil, err
 	}
 	return &result, nil
 }
 
-func (p PeopleService) GetMe() (*People, error) {
-	return p.Get("me")
+func (p PeopleService) GetMe(ctx context.Context) (*People, error) {
+	return p.Get(ctx, "me")
+}
+
+// Create adds a person to your organization (admins only).  Emails is
+// required, other details are optional.
+func (p PeopleService) Create(ctx context.Context, person People) (*People, error) {
+	if len(person.Emails) == 0 {
+		return nil, errors.New("email can't be empty when creating a person")
+	}
+	req, err := p.Client.NewPostRequest("/people", person)
+	if err != nil {
+		return nil, err
+	}
+	var result People
+	_, err = p.Client.Do(ctx, req, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Update replaces the details of a person (admins only).  Details missing
+// from person are cleared, so change a person returned by Get.
+func (p PeopleService) Update(ctx context.Context, id string, person People) (*People, error) {
+	if id == "" {
+		return nil, errors.New("id can't be empty when updating a person")
+	}
+	req, err := p.Client.NewPutRequest("/people/"+id, person)
+	if err != nil {
+		return nil, err
+	}
+	var result People
+	_, err = p.Client.Do(ctx, req, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Delete removes a person from your organization (admins only).
+func (p PeopleService) Delete(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.New("id can't be empty when deleting a person")
+	}
+	req, err := p.Client.NewDeleteRequest("/people/" + id)
+	if err != nil {
+		return err
+	}
+	_, err = p.Client.Do(ctx, req, nil)
+	return err
 }
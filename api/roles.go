@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"errors"
+
+	"github.com/tdeckers/sparkcli/util"
+)
+
+// RoleService gives access to the admin roles that can be given to people.
+type RoleService struct {
+	Client *util.Client
+}
+
+type Role struct {
+	Id   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+type RoleItems struct {
+	Items []Role `json:"items"`
+}
+
+// List requests all roles, following the pages of the response.
+func (r RoleService) List(ctx context.Context) (*[]Role, error) {
+	path := "/roles?max=1000"
+	var all []Role
+	for path != "" {
+		req, err := r.Client.NewGetRequest(path)
+		if err != nil {
+			return nil, err
+		}
+		var result RoleItems
+		res, err := r.Client.Do(ctx, req, &result)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, result.Items...)
+		path = util.NextLink(res)
+	}
+	return &all, nil
+}
+
+func (r RoleService) Get(ctx context.Context, id string) (*Role, error) {
+	if id == "" {
+		return nil, errors.New("id can't be empty when getting a role")
+	}
+	req, err := r.Client.NewGetRequest("/roles/" + id)
+	if err != nil {
+		return nil, err
+	}
+	var result Role
+	_, err = r.Client.Do(ctx, req, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -1,6 +1,9 @@
 package api
 
 import (
+	"context"
+	"net/url"
+
 	"github.com/tdeckers/sparkcli/util"
 )
 
@@ -15,73 +18,148 @@ type Room struct {
 	Created      string `json:"created,omitempty"`
 	LastActivity string `json:"lastActivity,omitempty"`
 	IsLocked     bool   `json:"isLocked,omitempty"`
+	TeamId       string `json:"teamId,omitempty"`
+	// Type is direct (a conversation of two people) or group.
+	Type        string `json:"type,omitempty"`
+	CreatorId   string `json:"creatorId,omitempty"`
+	IsPublic    bool   `json:"isPublic,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// RoomFilter selects rooms.  Empty fields match all rooms.
+type RoomFilter struct {
+	TeamId string
+	// Type is direct or group.
+	Type string
+	// SortBy is id, lastactivity or created.  Rooms are sorted by id if
+	// empty, newest first otherwise.
+	SortBy string
+}
+
+// values returns the query parameters for filter.
+func (f RoomFilter) values() url.Values {
+	v := url.Values{}
+	if f.TeamId != "" {
+		v.Add("teamId", f.TeamId)
+	}
+	if f.Type != "" {
+		v.Add("type", f.Type)
+	}
+	if f.SortBy != "" {
+		v.Add("sortBy", f.SortBy)
+	}
+	return v
+}
+
+// RoomUpdate holds the changes to a room.  Fields left empty (or nil) aren't
+// changed.
+type RoomUpdate struct {
+	Title    string `json:"title,omitempty"`
+	IsLocked *bool  `json:"isLocked,omitempty"`
 }
 
 type RoomItems struct {
 	Items []Room `json:"items"`
 }
 
-func (r RoomService) List() (*[]Room, error) {
+func (r RoomService) List(ctx context.Context) (*[]Room, error) {
 	req, err := r.Client.NewGetRequest("/rooms")
 	if err != nil {
 		return nil, err
 	}
 	var result RoomItems
-	_, err = r.Client.Do(req, &result)
+	_, err = r.Client.Do(ctx, req, &result)
 	if err != nil {
 		return nil, err
 	}
 	return &result.Items, nil
 }
 
-func (r RoomService) Create(name string) (*Room, error) {
-	room := Room{Title: name}
+// ListAll requests all rooms matching filter, following the pages of the
+// response.
+func (r RoomService) ListAll(ctx context.Context, filter RoomFilter) (*[]Room, error) {
+	v := filter.values()
+	v.Add("max", "1000")
+	path := "/rooms?" + v.Encode()
+	var all []Room
+	for path != "" {
+		req, err := r.Client.NewGetRequest(path)
+		if err != nil {
+			return nil, err
+		}
+		var result RoomItems
+		res, err := r.Client.Do(ctx, req, &result)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, result.Items...)
+		path = util.NextLink(res)
+	}
+	return &all, nil
+}
+
+func (r RoomService) Create(ctx context.Context, name string) (*Room, error) {
+	return r.CreateInTeam(ctx, name, "")
+}
+
+// CreateInTeam creates a room in the team with id teamId.
+func (r RoomService) CreateInTeam(ctx context.Context, name string, teamId string) (*Room, error) {
+	room := Room{Title: name, TeamId: teamId}
 	req, err := r.Client.NewPostRequest("/rooms", room)
 	if err != nil {
 		return nil, err
 	}
 	var result Room
-	_, err = r.Client.Do(req, &result)
+	_, err = r.Client.Do(ctx, req, &result)
 	if err != nil {
 		return nil, err
 	}
 	return &result, nil
 }
 
-func (r RoomService) Get(id string) (*Room, error) {
+func (r RoomService) Get(ctx context.Context, id string) (*Room, error) {
 	// for now, we're always returning the SIP address.
 	req, err := r.Client.NewGetRequest("/rooms/" + id + "?showSipAddress=true")
 	if err != nil {
 		return nil, err
 	}
 	var result Room
-	_, err = r.Client.Do(req, &result)
+	_, err = r.Client.Do(ctx, req, &result)
 	if err != nil {
 		return nil, err
 	}
 	return &result, nil
 }
 
-func (r RoomService) Update(id string, name string) (*Room, error) {
-	room := Room{Title: name}
-	req, err := r.Client.NewPutRequest("/rooms/"+id, room)
+// Update changes the title of a room, or locks or unlocks it.  Only
+// moderators can post in a locked room.  As the API needs the title, it's
+// requested first if update doesn't change it.
+func (r RoomService) Update(ctx context.Context, id string, update RoomUpdate) (*Room, error) {
+	if update.Title == "" {
+		room, err := r.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		update.Title = room.Title
+	}
+	req, err := r.Client.NewPutRequest("/rooms/"+id, update)
 	if err != nil {
 		return nil, err
 	}
 	var result Room
-	_, err = r.Client.Do(req, &result)
+	_, err = r.Client.Do(ctx, req, &result)
 	if err != nil {
 		return nil, err
 	}
 	return &result, nil
 }
 
-func (r RoomService) Delete(id string) error {
+func (r RoomService) Delete(ctx context.Context, id string) error {
 	req, err := r.Client.NewDeleteRequest("/rooms/" + id)
 	if err != nil {
 		return err
 	}
-	_, err = r.Client.Do(req, nil)
+	_, err = r.Client.Do(ctx, req, nil)
 	if err != nil {
 		return err
 	}
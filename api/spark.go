@@ -0,0 +1,157 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/tdeckers/sparkcli/util"
+)
+
+// RoomAPI is implemented by RoomService.
+type RoomAPI interface {
+	List(ctx context.Context) (*[]Room, error)
+	ListAll(ctx context.Context, filter RoomFilter) (*[]Room, error)
+	Create(ctx context.Context, name string) (*Room, error)
+	CreateInTeam(ctx context.Context, name string, teamId string) (*Room, error)
+	Get(ctx context.Context, id string) (*Room, error)
+	Update(ctx context.Context, id string, update RoomUpdate) (*Room, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// MessageAPI is implemented by MessageService.
+type MessageAPI interface {
+	List(ctx context.Context, roomId string, max int) (*[]Message, error)
+	ListPages(ctx context.Context, roomId string, max int, page func([]Message) bool) error
+	Create(ctx context.Context, roomId string, txt string) (*Message, error)
+	CreateMarkdown(ctx context.Context, roomId string, markdown string) (*Message, error)
+	CreateDirect(ctx context.Context, toPersonEmail string, txt string) (*Message, error)
+	CreateFile(ctx context.Context, roomId string, file string) (*Message, error)
+	CreateFiles(ctx context.Context, roomId string, txt string, markdown string, paths []string, progress io.Writer) (*Message, error)
+	CreateCard(ctx context.Context, roomId string, txt string, card json.RawMessage) (*Message, error)
+	Get(ctx context.Context, id string) (*Message, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// MemberAPI is implemented by MemberService.
+type MemberAPI interface {
+	List(ctx context.Context, roomId string, personId string, personEmail string) (*[]Membership, error)
+	ListAll(ctx context.Context, roomId string) (*[]Membership, error)
+	Create(ctx context.Context, roomId, personId, personEmail string, isModerator bool) (*Membership, error)
+	Get(ctx context.Context, id string) (*Membership, error)
+	Update(ctx context.Context, id string, isModerator bool) (*Membership, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// PeopleAPI is implemented by PeopleService.
+type PeopleAPI interface {
+	List(ctx context.Context, email string, displayName string) (*[]People, error)
+	ListAll(ctx context.Context, filter PeopleFilter) (*[]People, error)
+	Get(ctx context.Context, id string) (*People, error)
+	GetMe(ctx context.Context) (*People, error)
+	Create(ctx context.Context, person People) (*People, error)
+	Update(ctx context.Context, id string, person People) (*People, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// TeamAPI is implemented by TeamService.
+type TeamAPI interface {
+	List(ctx context.Context) (*[]Team, error)
+	Create(ctx context.Context, name string) (*Team, error)
+	Get(ctx context.Context, id string) (*Team, error)
+	Update(ctx context.Context, id string, name string) (*Team, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// TeamMemberAPI is implemented by TeamMemberService.
+type TeamMemberAPI interface {
+	List(ctx context.Context, teamId string) (*[]TeamMembership, error)
+	Create(ctx context.Context, teamId, personId, personEmail string, isModerator bool) (*TeamMembership, error)
+	Update(ctx context.Context, id string, isModerator bool) (*TeamMembership, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// WebhookAPI is implemented by WebhookService.
+type WebhookAPI interface {
+	List(ctx context.Context) (*[]Webhook, error)
+	Create(ctx context.Context, hook Webhook) (*Webhook, error)
+	Get(ctx context.Context, id string) (*Webhook, error)
+	Update(ctx context.Context, id string, name string, targetUrl string) (*Webhook, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// FileAPI is implemented by FileService.
+type FileAPI interface {
+	Info(ctx context.Context, fileUrl string) (*FileInfo, error)
+	Download(ctx context.Context, fileUrl string) (*FileInfo, io.ReadCloser, error)
+}
+
+// EventAPI is implemented by EventService.
+type EventAPI interface {
+	List(ctx context.Context, filter EventFilter, max int) (*[]Event, error)
+	ListAll(ctx context.Context, filter EventFilter) (*[]Event, error)
+	ListPages(ctx context.Context, filter EventFilter, max int, page func([]Event) bool) error
+	Get(ctx context.Context, id string) (*Event, error)
+}
+
+// AttachmentActionAPI is implemented by AttachmentActionService.
+type AttachmentActionAPI interface {
+	Get(ctx context.Context, id string) (*AttachmentAction, error)
+}
+
+// OrganizationAPI is implemented by OrganizationService.
+type OrganizationAPI interface {
+	List(ctx context.Context) (*[]Organization, error)
+	Get(ctx context.Context, id string) (*Organization, error)
+}
+
+// LicenseAPI is implemented by LicenseService.
+type LicenseAPI interface {
+	List(ctx context.Context, orgId string) (*[]License, error)
+	Get(ctx context.Context, id string) (*License, error)
+}
+
+// RoleAPI is implemented by RoleService.
+type RoleAPI interface {
+	List(ctx context.Context) (*[]Role, error)
+	Get(ctx context.Context, id string) (*Role, error)
+}
+
+// Spark gives access to all services of the Cisco Spark API.  Code that takes
+// a Spark (or one of its interfaces) instead of a util.Client can be tested
+// with the mocks in package apimock, without HTTP.
+type Spark struct {
+	Rooms             RoomAPI
+	Messages          MessageAPI
+	Memberships       MemberAPI
+	People            PeopleAPI
+	Teams             TeamAPI
+	TeamMembers       TeamMemberAPI
+	Webhooks          WebhookAPI
+	Files             FileAPI
+	Events            EventAPI
+	AttachmentActions AttachmentActionAPI
+	Organizations     OrganizationAPI
+	Licenses          LicenseAPI
+	Roles             RoleAPI
+}
+
+// NewSpark creates a Spark with the services talking to the API through
+// client.
+func NewSpark(client *util.Client) *Spark {
+	return &Spark{
+		Rooms:             RoomService{Client: client},
+		Messages:          MessageService{Client: client},
+		Memberships:       MemberService{Client: client},
+		People:            PeopleService{Client: client},
+		Teams:             TeamService{Client: client},
+		TeamMembers:       TeamMemberService{Client: client},
+		Webhooks:          WebhookService{Client: client},
+		Files:             FileService{Client: client},
+		Events:            EventService{Client: client},
+		AttachmentActions: AttachmentActionService{Client: client},
+		Organizations:     OrganizationService{Client: client},
+		Licenses:          LicenseService{Client: client},
+		Roles:             RoleService{Client: client},
+	}
+}
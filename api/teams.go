@@ -0,0 +1,179 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/url"
+
+	"github.com/tdeckers/sparkcli/util"
+)
+
+// TeamService gives access to teams, groups of people with a set of rooms
+// visible to all members of the team.
+type TeamService struct {
+	Client *util.Client
+}
+
+type Team struct {
+	Id        string `json:"id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	CreatorId string `json:"creatorId,omitempty"`
+	Created   string `json:"created,omitempty"`
+}
+
+type TeamItems struct {
+	Items []Team `json:"items"`
+}
+
+// List requests all teams the user belongs to, following the pages of the
+// response.
+func (t TeamService) List(ctx context.Context) (*[]Team, error) {
+	path := "/teams?max=1000"
+	var all []Team
+	for path != "" {
+		req, err := t.Client.NewGetRequest(path)
+		if err != nil {
+			return nil, err
+		}
+		var result TeamItems
+		res, err := t.Client.Do(ctx, req, &result)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, result.Items...)
+		path = util.NextLink(res)
+	}
+	return &all, nil
+}
+
+func (t TeamService) Create(ctx context.Context, name string) (*Team, error) {
+	req, err := t.Client.NewPostRequest("/teams", Team{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	var result Team
+	_, err = t.Client.Do(ctx, req, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (t TeamService) Get(ctx context.Context, id string) (*Team, error) {
+	req, err := t.Client.NewGetRequest("/teams/" + id)
+	if err != nil {
+		return nil, err
+	}
+	var result Team
+	_, err = t.Client.Do(ctx, req, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (t TeamService) Update(ctx context.Context, id string, name string) (*Team, error) {
+	req, err := t.Client.NewPutRequest("/teams/"+id, Team{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	var result Team
+	_, err = t.Client.Do(ctx, req, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (t TeamService) Delete(ctx context.Context, id string) error {
+	req, err := t.Client.NewDeleteRequest("/teams/" + id)
+	if err != nil {
+		return err
+	}
+	_, err = t.Client.Do(ctx, req, nil)
+	return err
+}
+
+// TeamMemberService gives access to the memberships of teams.
+type TeamMemberService struct {
+	Client *util.Client
+}
+
+type TeamMembership struct {
+	Id                string `json:"id,omitempty"`
+	TeamId            string `json:"teamId,omitempty"`
+	PersonId          string `json:"personId,omitempty"`
+	PersonEmail       string `json:"personEmail,omitempty"`
+	PersonDisplayName string `json:"personDisplayName,omitempty"`
+	IsModerator       bool   `json:"isModerator,omitempty"`
+	Created           string `json:"created,omitempty"`
+}
+
+type TeamMembershipItems struct {
+	Items []TeamMembership `json:"items"`
+}
+
+// List requests all memberships of a team, following the pages of the
+// response.
+func (t TeamMemberService) List(ctx context.Context, teamId string) (*[]TeamMembership, error) {
+	if teamId == "" {
+		return nil, errors.New("teamId can't be empty when listing team memberships")
+	}
+	v := url.Values{}
+	v.Add("teamId", teamId)
+	v.Add("max", "1000")
+	path := "/team/memberships?" + v.Encode()
+	var all []TeamMembership
+	for path != "" {
+		req, err := t.Client.NewGetRequest(path)
+		if err != nil {
+			return nil, err
+		}
+		var result TeamMembershipItems
+		res, err := t.Client.Do(ctx, req, &result)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, result.Items...)
+		path = util.NextLink(res)
+	}
+	return &all, nil
+}
+
+func (t TeamMemberService) Create(ctx context.Context, teamId, personId, personEmail string, isModerator bool) (*TeamMembership, error) {
+	ms := TeamMembership{TeamId: teamId, PersonId: personId, PersonEmail: personEmail, IsModerator: isModerator}
+	req, err := t.Client.NewPostRequest("/team/memberships", ms)
+	if err != nil {
+		return nil, err
+	}
+	var result TeamMembership
+	_, err = t.Client.Do(ctx, req, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (t TeamMemberService) Update(ctx context.Context, id string, isModerator bool) (*TeamMembership, error) {
+	// isModerator is sent explicitly, as false is a valid update.
+	body := map[string]bool{"isModerator": isModerator}
+	req, err := t.Client.NewPutRequest("/team/memberships/"+id, body)
+	if err != nil {
+		return nil, err
+	}
+	var result TeamMembership
+	_, err = t.Client.Do(ctx, req, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (t TeamMemberService) Delete(ctx context.Context, id string) error {
+	req, err := t.Client.NewDeleteRequest("/team/memberships/" + id)
+	if err != nil {
+		return err
+	}
+	_, err = t.Client.Do(ctx, req, nil)
+	return err
+}
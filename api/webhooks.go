@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"github.com/tdeckers/sparkcli/util"
+)
+
+// WebhookService gives access to webhooks, which notify a url of events like
+// new messages or memberships.
+type WebhookService struct {
+	Client *util.Client
+}
+
+type Webhook struct {
+	Id        string `json:"id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	TargetUrl string `json:"targetUrl,omitempty"`
+	Resource  string `json:"resource,omitempty"`
+	Event     string `json:"event,omitempty"`
+	Filter    string `json:"filter,omitempty"`
+	Secret    string `json:"secret,omitempty"`
+	Status    string `json:"status,omitempty"`
+	Created   string `json:"created,omitempty"`
+}
+
+type WebhookItems struct {
+	Items []Webhook `json:"items"`
+}
+
+// List requests all webhooks, following the pages of the response.
+func (w WebhookService) List(ctx context.Context) (*[]Webhook, error) {
+	path := "/webhooks?max=1000"
+	var all []Webhook
+	for path != "" {
+		req, err := w.Client.NewGetRequest(path)
+		if err != nil {
+			return nil, err
+		}
+		var result WebhookItems
+		res, err := w.Client.Do(ctx, req, &result)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, result.Items...)
+		path = util.NextLink(res)
+	}
+	return &all, nil
+}
+
+func (w WebhookService) Create(ctx context.Context, hook Webhook) (*Webhook, error) {
+	req, err := w.Client.NewPostRequest("/webhooks", hook)
+	if err != nil {
+		return nil, err
+	}
+	var result Webhook
+	_, err = w.Client.Do(ctx, req, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (w WebhookService) Get(ctx context.Context, id string) (*Webhook, error) {
+	req, err := w.Client.NewGetRequest("/webhooks/" + id)
+	if err != nil {
+		return nil, err
+	}
+	var result Webhook
+	_, err = w.Client.Do(ctx, req, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Update changes the name and target url of a webhook.  The resource, event
+// and filter can't be changed.
+func (w WebhookService) Update(ctx context.Context, id string, name string, targetUrl string) (*Webhook, error) {
+	hook := Webhook{Name: name, TargetUrl: targetUrl}
+	req, err := w.Client.NewPutRequest("/webhooks/"+id, hook)
+	if err != nil {
+		return nil, err
+	}
+	var result Webhook
+	_, err = w.Client.Do(ctx, req, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (w WebhookService) Delete(ctx context.Context, id string) error {
+	req, err := w.Client.NewDeleteRequest("/webhooks/" + id)
+	if err != nil {
+		return err
+	}
+	_, err = w.Client.Do(ctx, req, nil)
+	return err
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// cardMaxVersion is the newest Adaptive Card version Spark clients render.
+const cardMaxVersion = 3 // 1.3
+
+// cardElements lists the element types of the Adaptive Card schema, with the
+// properties they require.
+var cardElements = map[string][]string{
+	"TextBlock":       {"text"},
+	"Image":           {"url"},
+	"Media":           {"sources"},
+	"RichTextBlock":   {"inlines"},
+	"Container":       {"items"},
+	"ColumnSet":       nil,
+	"FactSet":         {"facts"},
+	"ImageSet":        {"images"},
+	"ActionSet":       {"actions"},
+	"Input.Text":      {"id"},
+	"Input.Number":    {"id"},
+	"Input.Date":      {"id"},
+	"Input.Time":      {"id"},
+	"Input.Toggle":    {"id", "title"},
+	"Input.ChoiceSet": {"id", "choices"},
+}
+
+// cardActions lists the action types of the Adaptive Card schema, with the
+// properties they require.
+var cardActions = map[string][]string{
+	"Action.OpenUrl":          {"url"},
+	"Action.Submit":           nil,
+	"Action.ShowCard":         {"card"},
+	"Action.ToggleVisibility": {"targetElements"},
+}
+
+// readCard reads an Adaptive Card from a file, or from stdin for -, and
+// checks it with validateCard.  It returns the card and its fallbackText.
+func readCard(path string) (json.RawMessage, string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(strings.TrimPrefix(path, "@"))
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if err := validateCard(data); err != nil {
+		return nil, "", fmt.Errorf("Invalid card: %v", err)
+	}
+	var card struct {
+		FallbackText string `json:"fallbackText"`
+	}
+	json.Unmarshal(data, &card)
+	return json.RawMessage(data), card.FallbackText, nil
+}
+
+// validateCard checks that data is an Adaptive Card Spark can show: the
+// required properties are set, element and action types are known, input ids
+// are unique and the version isn't too new.  Errors give the path of the
+// offending element, e.g. body[2].items[0].
+func validateCard(data []byte) error {
+	var card map[string]interface{}
+	if err := json.Unmarshal(data, &card); err != nil {
+		return err
+	}
+	if card["type"] != "AdaptiveCard" {
+		return errors.New("type must be AdaptiveCard")
+	}
+	version, _ := card["version"].(string)
+	if version == "" {
+		return errors.New("version is required")
+	}
+	major, minor, ok := strings.Cut(version, ".")
+	if n, err := strconv.Atoi(minor); major != "1" || !ok || err != nil || n > cardMaxVersion {
+		return fmt.Errorf("version %q isn't supported, Spark shows cards up to version 1.%d", version, cardMaxVersion)
+	}
+	checker := cardChecker{ids: map[string]bool{}}
+	return checker.card("", card)
+}
+
+// cardChecker walks a card, remembering the input ids seen.
+type cardChecker struct {
+	ids map[string]bool
+}
+
+func (c cardChecker) card(path string, card map[string]interface{}) error {
+	if card["type"] != "AdaptiveCard" {
+		return fmt.Errorf("%s must be AdaptiveCard", cardPath(path, "type"))
+	}
+	if err := c.elements(cardPath(path, "body"), card["body"]); err != nil {
+		return err
+	}
+	return c.actions(cardPath(path, "actions"), card["actions"])
+}
+
+func (c cardChecker) elements(path string, v interface{}) error {
+	return c.each(path, v, func(path string, element map[string]interface{}) error {
+		kind, _ := element["type"].(string)
+		required, ok := cardElements[kind]
+		if !ok {
+			return fmt.Errorf("%s: unknown element type %q", path, kind)
+		}
+		if err := cardNeed(path, kind, element, required); err != nil {
+			return err
+		}
+		if strings.HasPrefix(kind, "Input.") {
+			id := fmt.Sprint(element["id"])
+			if c.ids[id] {
+				return fmt.Errorf("%s: input id %q is used more than once", path, id)
+			}
+			c.ids[id] = true
+		}
+		switch kind {
+		case "Container":
+			return c.elements(cardPath(path, "items"), element["items"])
+		case "ImageSet":
+			return c.elements(cardPath(path, "images"), element["images"])
+		case "ActionSet":
+			return c.actions(cardPath(path, "actions"), element["actions"])
+		case "ColumnSet":
+			return c.each(cardPath(path, "columns"), element["columns"], func(path string, column map[string]interface{}) error {
+				return c.elements(cardPath(path, "items"), column["items"])
+			})
+		}
+		return nil
+	})
+}
+
+func (c cardChecker) actions(path string, v interface{}) error {
+	return c.each(path, v, func(path string, action map[string]interface{}) error {
+		kind, _ := action["type"].(string)
+		required, ok := cardActions[kind]
+		if !ok {
+			return fmt.Errorf("%s: unknown action type %q", path, kind)
+		}
+		if err := cardNeed(path, kind, action, required); err != nil {
+			return err
+		}
+		if kind == "Action.ShowCard" {
+			card, ok := action["card"].(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("%s.card must be an object", path)
+			}
+			return c.card(cardPath(path, "card"), card)
+		}
+		return nil
+	})
+}
+
+// each calls fn for every object in v, an optional array at path.
+func (c cardChecker) each(path string, v interface{}, fn func(string, map[string]interface{}) error) error {
+	if v == nil {
+		return nil
+	}
+	items, ok := v.([]interface{})
+	if !ok {
+		return fmt.Errorf("%s must be an array", path)
+	}
+	for i, item := range items {
+		p := fmt.Sprintf("%s[%d]", path, i)
+		object, ok := item.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an object", p)
+		}
+		if err := fn(p, object); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cardNeed checks that object, of type kind, has the required properties.
+func cardNeed(path, kind string, object map[string]interface{}, required []string) error {
+	for _, name := range required {
+		if v, ok := object[name]; !ok || v == nil || v == "" {
+			return fmt.Errorf("%s: %s needs %s", path, kind, name)
+		}
+	}
+	return nil
+}
+
+// cardPath returns the path of property name of the object at path.
+func cardPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
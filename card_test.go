@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tdeckers/sparkcli/api"
+	"github.com/tdeckers/sparkcli/sparktest"
+)
+
+func Test_validateCard(t *testing.T) {
+	tests := []struct {
+		name    string
+		card    string
+		wantErr string
+	}{
+		{"minimal", `{"type": "AdaptiveCard", "version": "1.0"}`, ""},
+		{"form", `{"type": "AdaptiveCard", "version": "1.3", "body": [
+			{"type": "TextBlock", "text": "Restart the service?"},
+			{"type": "ColumnSet", "columns": [{"type": "Column", "items": [{"type": "Input.Text", "id": "reason"}]}]},
+			{"type": "Input.Toggle", "id": "force", "title": "Force"}],
+			"actions": [{"type": "Action.Submit", "title": "Restart", "data": {"action": "restart"}},
+				{"type": "Action.ShowCard", "card": {"type": "AdaptiveCard", "body": [{"type": "Input.Date", "id": "when"}]}}]}`, ""},
+		{"not json", `{"type": "AdaptiveCard"`, "unexpected end"},
+		{"not a card", `{"type": "Message", "version": "1.0"}`, "type must be AdaptiveCard"},
+		{"no version", `{"type": "AdaptiveCard"}`, "version is required"},
+		{"too new", `{"type": "AdaptiveCard", "version": "1.5"}`, `version "1.5" isn't supported`},
+		{"body not an array", `{"type": "AdaptiveCard", "version": "1.2", "body": {}}`, "body must be an array"},
+		{"unknown element", `{"type": "AdaptiveCard", "version": "1.2", "body": [{"type": "Marquee"}]}`,
+			`body[0]: unknown element type "Marquee"`},
+		{"no text", `{"type": "AdaptiveCard", "version": "1.2", "body": [{"type": "TextBlock"}]}`,
+			"body[0]: TextBlock needs text"},
+		{"nested input without id", `{"type": "AdaptiveCard", "version": "1.2", "body": [{"type": "Container",
+			"items": [{"type": "TextBlock", "text": "x"}, {"type": "Input.Number"}]}]}`,
+			"body[0].items[1]: Input.Number needs id"},
+		{"duplicate id", `{"type": "AdaptiveCard", "version": "1.2", "body": [
+			{"type": "Input.Text", "id": "a"}, {"type": "Input.Text", "id": "a"}]}`, `input id "a" is used more than once`},
+		{"url missing", `{"type": "AdaptiveCard", "version": "1.2", "actions": [{"type": "Action.OpenUrl"}]}`,
+			"actions[0]: Action.OpenUrl needs url"},
+		{"show card", `{"type": "AdaptiveCard", "version": "1.2", "actions": [{"type": "Action.ShowCard",
+			"card": {"type": "AdaptiveCard", "body": [{"type": "Image"}]}}]}`, "actions[0].card.body[0]: Image needs url"},
+	}
+	for _, tt := range tests {
+		err := validateCard([]byte(tt.card))
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%q. validateCard() error = %v, want none", tt.name, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%q. validateCard() error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCardCommands(t *testing.T) {
+	server := sparktest.NewServer()
+	defer server.Close()
+	room := server.AddRoom("alerts")
+	dir := t.TempDir()
+	card := filepath.Join(dir, "card.json")
+	os.WriteFile(card, []byte(`{"type": "AdaptiveCard", "version": "1.2", "fallbackText": "Disk full",
+		"body": [{"type": "TextBlock", "text": "Disk full"}], "actions": [{"type": "Action.Submit", "title": "Ack"}]}`), 0600)
+	invalid := filepath.Join(dir, "invalid.json")
+	os.WriteFile(invalid, []byte(`{"type": "AdaptiveCard", "version": "1.2", "body": [{"type": "TextBlock"}]}`), 0600)
+
+	if _, err := runApp(t, server, "messages", "create", "card", "-f", card, room.Id); err != nil {
+		t.Fatalf("messages create card: error = %v", err)
+	}
+	msgs := server.Messages(room.Id)
+	if len(msgs) != 1 || msgs[0].Text != "Disk full" || len(msgs[0].Attachments) != 1 ||
+		msgs[0].Attachments[0].ContentType != api.CardContentType {
+		t.Fatalf("messages create card: room has %+v, want the card with its fallback text", msgs)
+	}
+	if _, err := runApp(t, server, "messages", "create", "card", "-f", invalid, room.Id); err == nil ||
+		!strings.Contains(err.Error(), "TextBlock needs text") {
+		t.Errorf("messages create card with an invalid card: error = %v, want TextBlock needs text", err)
+	}
+
+	action := server.AddAttachmentAction(msgs[0].Id, "alice@example.com", map[string]interface{}{"ack": "yes"})
+	out, err := runApp(t, server, "actions", "get", action.Id)
+	var got api.AttachmentAction
+	if err != nil || json.Unmarshal([]byte(out), &got) != nil || got.MessageId != msgs[0].Id || got.Inputs["ack"] != "yes" {
+		t.Errorf("actions get = %q, %v, want the action as json", out, err)
+	}
+}
@@ -0,0 +1,516 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/tdeckers/sparkcli/api"
+	"github.com/tdeckers/sparkcli/util"
+)
+
+const (
+	// roomsCache is the cache entry holding the rooms you're a member of.
+	roomsCache = "rooms"
+	// completionMaxAge is how long cached rooms and memberships are used for
+	// completion before they're requested again.
+	completionMaxAge = 10 * time.Minute
+)
+
+// completionArgs lists, per command path, what the positional arguments of
+// that command complete to.  "files" as the last kind applies to all further
+// arguments.  For a command with subcommands, the first argument completes to
+// the subcommands as well.
+var completionArgs = map[string][]string{
+	"rooms get":            {"rooms"},
+	"rooms delete":         {"rooms"},
+	"rooms default":        {"rooms"},
+	"rooms update":         {"rooms"},
+	"rooms export":         {"rooms"},
+	"messages list":        {"rooms"},
+	"messages tail":        {"rooms"},
+	"messages create":      {"rooms"},
+	"messages create text": {"rooms"},
+	"messages create file": {"rooms", "files"},
+	"messages create card": {"rooms"},
+	"memberships get":      {"memberships"},
+	"memberships update":   {"memberships"},
+	"memberships delete":   {"memberships"},
+	"memberships sync":     {"rooms"},
+}
+
+// completionFlagValues lists what the values of flags (by long name) complete
+// to.
+var completionFlagValues = map[string]string{
+	"room": "rooms",
+}
+
+// completionNode is a command in the tree used to generate completion scripts.
+type completionNode struct {
+	path     string   // names of the command and its parents, e.g. "rooms list"
+	names    []string // name followed by aliases
+	usage    string
+	flags    []completionFlag
+	children []*completionNode
+}
+
+// completionFlag describes a flag of a command.
+type completionFlag struct {
+	long, short string
+	usage       string
+	takesValue  bool
+}
+
+// completionTree builds the completion tree for app.
+func completionTree(app *cli.App) *completionNode {
+	root := &completionNode{flags: completionFlags(app.Flags)}
+	root.children = completionNodes("", app.Commands)
+	return root
+}
+
+func completionNodes(parent string, commands []cli.Command) []*completionNode {
+	var nodes []*completionNode
+	for _, command := range commands {
+		if command.Hidden {
+			continue
+		}
+		node := &completionNode{
+			path:  strings.TrimSpace(parent + " " + command.Name),
+			names: append([]string{command.Name}, command.Aliases...),
+			usage: command.Usage,
+			flags: completionFlags(command.Flags),
+		}
+		node.children = completionNodes(node.path, command.Subcommands)
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// completionFlags converts flags.  Flag names are given as "long, short".
+func completionFlags(flags []cli.Flag) []completionFlag {
+	var result []completionFlag
+	for _, flag := range flags {
+		var cf completionFlag
+		var name string
+		switch f := flag.(type) {
+		case cli.BoolFlag:
+			name, cf.usage = f.Name, f.Usage
+		case cli.BoolTFlag:
+			name, cf.usage = f.Name, f.Usage
+		case cli.StringFlag:
+			name, cf.usage, cf.takesValue = f.Name, f.Usage, true
+		case cli.IntFlag:
+			name, cf.usage, cf.takesValue = f.Name, f.Usage, true
+		case cli.DurationFlag:
+			name, cf.usage, cf.takesValue = f.Name, f.Usage, true
+		case cli.StringSliceFlag:
+			name, cf.usage, cf.takesValue = f.Name, f.Usage, true
+		default:
+			name, cf.takesValue = flag.GetName(), true
+		}
+		for _, n := range strings.Split(name, ",") {
+			n = strings.TrimSpace(n)
+			if len(n) == 1 {
+				cf.short = n
+			} else {
+				cf.long = n
+			}
+		}
+		result = append(result, cf)
+	}
+	return result
+}
+
+// options returns the command line spellings of the flag.
+func (f completionFlag) options() []string {
+	var opts []string
+	if f.long != "" {
+		opts = append(opts, "--"+f.long)
+	}
+	if f.short != "" {
+		opts = append(opts, "-"+f.short)
+	}
+	return opts
+}
+
+// walk calls fn for n and all its descendants.
+func (n *completionNode) walk(fn func(*completionNode)) {
+	fn(n)
+	for _, child := range n.children {
+		child.walk(fn)
+	}
+}
+
+// writeBashCompletion writes a bash completion script for app to w.
+func writeBashCompletion(w io.Writer, app *cli.App) {
+	root := completionTree(app)
+	fmt.Fprintf(w, "# bash completion for %s\n", app.Name)
+	fmt.Fprintf(w, "# Load with: source <(%s completion bash)\n\n", app.Name)
+	fmt.Fprintf(w, "_%s() {\n", app.Name)
+	fmt.Fprintln(w, `    local cur prev cmdpath word i nargs words dyn`)
+	fmt.Fprintln(w, `    COMPREPLY=()`)
+	fmt.Fprintln(w, `    cur="${COMP_WORDS[COMP_CWORD]}"`)
+	fmt.Fprintln(w, `    prev="${COMP_WORDS[COMP_CWORD-1]}"`)
+	fmt.Fprintln(w, `    cmdpath=""`)
+	fmt.Fprintln(w, `    nargs=0`)
+	fmt.Fprintln(w, `    for ((i=1; i<COMP_CWORD; i++)); do`)
+	fmt.Fprintln(w, `        word="${COMP_WORDS[i]}"`)
+	fmt.Fprintln(w, `        case "$cmdpath:$word" in`)
+	root.walk(func(n *completionNode) {
+		for _, f := range n.flags {
+			if f.takesValue {
+				fmt.Fprintf(w, "            %s) i=$((i+1)) ;;\n", bashPatterns(n.path, f.options()))
+			}
+		}
+		for _, child := range n.children {
+			fmt.Fprintf(w, "            %s) cmdpath=\"%s\" ;;\n", bashPatterns(n.path, child.names), child.path)
+		}
+	})
+	fmt.Fprintln(w, `            *:-*) ;;`)
+	fmt.Fprintln(w, `            *) nargs=$((nargs+1)) ;;`)
+	fmt.Fprintln(w, `        esac`)
+	fmt.Fprintln(w, `    done`)
+	fmt.Fprintln(w, `    case "$cmdpath:$prev" in`)
+	root.walk(func(n *completionNode) {
+		for _, f := range n.flags {
+			if kind, ok := completionFlagValues[f.long]; ok {
+				fmt.Fprintf(w, "        %s) dyn=%s ;;\n", bashPatterns(n.path, f.options()), kind)
+			}
+		}
+	})
+	fmt.Fprintln(w, `    esac`)
+	fmt.Fprintln(w, `    if [ -z "$dyn" ]; then`)
+	fmt.Fprintln(w, `        if [[ "$cur" == -* ]]; then`)
+	fmt.Fprintln(w, `            case "$cmdpath" in`)
+	root.walk(func(n *completionNode) {
+		var opts []string
+		for _, f := range n.flags {
+			opts = append(opts, f.options()...)
+		}
+		if len(opts) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "                \"%s\") words=\"%s\" ;;\n", n.path, strings.Join(opts, " "))
+	})
+	fmt.Fprintln(w, `            esac`)
+	fmt.Fprintln(w, `        else`)
+	fmt.Fprintln(w, `            case "$cmdpath" in`)
+	root.walk(func(n *completionNode) {
+		var names []string
+		for _, child := range n.children {
+			names = append(names, child.names[0])
+		}
+		kinds, ok := completionArgs[n.path]
+		switch {
+		case len(names) > 0 && ok:
+			fmt.Fprintf(w, "                \"%s\") [ $nargs -eq 0 ] && words=\"%s\"; case $nargs in %s esac ;;\n",
+				n.path, strings.Join(names, " "), bashArgCases(kinds))
+		case len(names) > 0:
+			fmt.Fprintf(w, "                \"%s\") words=\"%s\" ;;\n", n.path, strings.Join(names, " "))
+		case ok:
+			fmt.Fprintf(w, "                \"%s\") case $nargs in %s esac ;;\n", n.path, bashArgCases(kinds))
+		}
+	})
+	fmt.Fprintln(w, `            esac`)
+	fmt.Fprintln(w, `        fi`)
+	fmt.Fprintln(w, `    fi`)
+	fmt.Fprintln(w, `    case "$dyn" in`)
+	fmt.Fprintln(w, `        files) COMPREPLY=($(compgen -f -- "$cur")); return ;;`)
+	fmt.Fprintln(w, `        ?*) words="$words $("${COMP_WORDS[0]}" completion "$dyn" 2>/dev/null | cut -f1)" ;;`)
+	fmt.Fprintln(w, `    esac`)
+	fmt.Fprintln(w, `    COMPREPLY=($(compgen -W "$words" -- "$cur"))`)
+	fmt.Fprintln(w, `}`)
+	fmt.Fprintf(w, "complete -F _%s %s\n", app.Name, app.Name)
+}
+
+// bashPatterns returns a case pattern matching "path:name" for all names.
+func bashPatterns(path string, names []string) string {
+	var patterns []string
+	for _, name := range names {
+		patterns = append(patterns, fmt.Sprintf("\"%s:%s\"", path, name))
+	}
+	return strings.Join(patterns, "|")
+}
+
+// bashArgCases returns the cases selecting the completion for positional
+// argument $nargs.
+func bashArgCases(kinds []string) string {
+	var cases []string
+	for i, kind := range kinds {
+		if i == len(kinds)-1 && kind == "files" {
+			cases = append(cases, fmt.Sprintf("*) dyn=%s ;;", kind))
+		} else {
+			cases = append(cases, fmt.Sprintf("%d) dyn=%s ;;", i, kind))
+		}
+	}
+	return strings.Join(cases, " ")
+}
+
+// writeZshCompletion writes a zsh completion script for app to w.
+func writeZshCompletion(w io.Writer, app *cli.App) {
+	root := completionTree(app)
+	fmt.Fprintf(w, "#compdef %s\n", app.Name)
+	fmt.Fprintf(w, "# zsh completion for %s\n", app.Name)
+	fmt.Fprintf(w, "# Load with: source <(%s completion zsh)\n\n", app.Name)
+	fmt.Fprintf(w, "_%s_dynamic() {\n", app.Name)
+	fmt.Fprintln(w, `    local -a cands`)
+	fmt.Fprintln(w, `    local line`)
+	fmt.Fprintln(w, `    for line in ${(f)"$(${words[1]} completion $1 2>/dev/null)"}; do`)
+	fmt.Fprintln(w, `        cands+=("${${line%%$'\t'*}//:/\\:}:${line#*$'\t'}")`)
+	fmt.Fprintln(w, `    done`)
+	fmt.Fprintln(w, `    _describe $1 cands`)
+	fmt.Fprintln(w, `}`)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "_%s() {\n", app.Name)
+	fmt.Fprintln(w, `    local cmdpath="" word i nargs=0 dyn=""`)
+	fmt.Fprintln(w, `    local cur=${words[CURRENT]} prev=${words[CURRENT-1]}`)
+	fmt.Fprintln(w, `    local -a cands`)
+	fmt.Fprintln(w, `    for ((i=2; i<CURRENT; i++)); do`)
+	fmt.Fprintln(w, `        word=${words[i]}`)
+	fmt.Fprintln(w, `        case "$cmdpath:$word" in`)
+	root.walk(func(n *completionNode) {
+		for _, f := range n.flags {
+			if f.takesValue {
+				fmt.Fprintf(w, "            %s) ((i++)) ;;\n", bashPatterns(n.path, f.options()))
+			}
+		}
+		for _, child := range n.children {
+			fmt.Fprintf(w, "            %s) cmdpath=\"%s\" ;;\n", bashPatterns(n.path, child.names), child.path)
+		}
+	})
+	fmt.Fprintln(w, `            *:-*) ;;`)
+	fmt.Fprintln(w, `            *) ((nargs++)) ;;`)
+	fmt.Fprintln(w, `        esac`)
+	fmt.Fprintln(w, `    done`)
+	fmt.Fprintln(w, `    case "$cmdpath:$prev" in`)
+	root.walk(func(n *completionNode) {
+		for _, f := range n.flags {
+			if kind, ok := completionFlagValues[f.long]; ok {
+				fmt.Fprintf(w, "        %s) dyn=%s ;;\n", bashPatterns(n.path, f.options()), kind)
+			}
+		}
+	})
+	fmt.Fprintln(w, `    esac`)
+	fmt.Fprintln(w, `    if [[ -z $dyn ]]; then`)
+	fmt.Fprintln(w, `        if [[ $cur == -* ]]; then`)
+	fmt.Fprintln(w, `            case "$cmdpath" in`)
+	root.walk(func(n *completionNode) {
+		var items []string
+		for _, f := range n.flags {
+			for _, opt := range f.options() {
+				items = append(items, zshItem(opt, f.usage))
+			}
+		}
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "                \"%s\") cands=(%s) ;;\n", n.path, strings.Join(items, " "))
+	})
+	fmt.Fprintln(w, `            esac`)
+	fmt.Fprintln(w, `        else`)
+	fmt.Fprintln(w, `            case "$cmdpath" in`)
+	root.walk(func(n *completionNode) {
+		var items []string
+		for _, child := range n.children {
+			items = append(items, zshItem(child.names[0], child.usage))
+		}
+		kinds, ok := completionArgs[n.path]
+		switch {
+		case len(items) > 0 && ok:
+			fmt.Fprintf(w, "                \"%s\") (( nargs == 0 )) && cands=(%s); case $nargs in %s esac ;;\n",
+				n.path, strings.Join(items, " "), bashArgCases(kinds))
+		case len(items) > 0:
+			fmt.Fprintf(w, "                \"%s\") cands=(%s) ;;\n", n.path, strings.Join(items, " "))
+		case ok:
+			fmt.Fprintf(w, "                \"%s\") case $nargs in %s esac ;;\n", n.path, bashArgCases(kinds))
+		}
+	})
+	fmt.Fprintln(w, `            esac`)
+	fmt.Fprintln(w, `        fi`)
+	fmt.Fprintln(w, `    fi`)
+	fmt.Fprintln(w, `    case "$dyn" in`)
+	fmt.Fprintln(w, `        files) _files ;;`)
+	fmt.Fprintf(w, "        ?*) (( $#cands )) && _describe '%s' cands; _%s_dynamic $dyn ;;\n", app.Name, app.Name)
+	fmt.Fprintf(w, "        *) _describe '%s' cands ;;\n", app.Name)
+	fmt.Fprintln(w, `    esac`)
+	fmt.Fprintln(w, `}`)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "compdef _%s %s\n", app.Name, app.Name)
+}
+
+// zshItem returns a single quoted "name:description" item for _describe.
+func zshItem(name, usage string) string {
+	item := strings.Replace(name, ":", `\:`, -1) + ":" + usage
+	return "'" + strings.Replace(item, "'", `'\''`, -1) + "'"
+}
+
+// writeFishCompletion writes a fish completion script for app to w.
+func writeFishCompletion(w io.Writer, app *cli.App) {
+	root := completionTree(app)
+	name := app.Name
+	fmt.Fprintf(w, "# fish completion for %s\n", name)
+	fmt.Fprintf(w, "# Load with: %s completion fish | source\n\n", name)
+	// __<name>_state prints the command path and the number of positional
+	// arguments given so far, separated by a colon.
+	fmt.Fprintf(w, "function __%s_state\n", name)
+	fmt.Fprintln(w, `    set -l tokens (commandline -opc)`)
+	fmt.Fprintln(w, `    set -l cmdpath ""`)
+	fmt.Fprintln(w, `    set -l nargs 0`)
+	fmt.Fprintln(w, `    set -l skip 0`)
+	fmt.Fprintln(w, `    for word in $tokens[2..-1]`)
+	fmt.Fprintln(w, `        if test $skip -eq 1`)
+	fmt.Fprintln(w, `            set skip 0`)
+	fmt.Fprintln(w, `            continue`)
+	fmt.Fprintln(w, `        end`)
+	fmt.Fprintln(w, `        switch "$cmdpath:$word"`)
+	root.walk(func(n *completionNode) {
+		var valueFlags []string
+		for _, f := range n.flags {
+			if f.takesValue {
+				valueFlags = append(valueFlags, f.options()...)
+			}
+		}
+		if len(valueFlags) > 0 {
+			fmt.Fprintf(w, "            case %s\n", fishPatterns(n.path, valueFlags))
+			fmt.Fprintln(w, `                set skip 1`)
+		}
+		for _, child := range n.children {
+			fmt.Fprintf(w, "            case %s\n", fishPatterns(n.path, child.names))
+			fmt.Fprintf(w, "                set cmdpath '%s'\n", child.path)
+		}
+	})
+	fmt.Fprintln(w, `            case '*:-*'`)
+	fmt.Fprintln(w, `            case '*'`)
+	fmt.Fprintln(w, `                set nargs (math $nargs + 1)`)
+	fmt.Fprintln(w, `        end`)
+	fmt.Fprintln(w, `    end`)
+	fmt.Fprintln(w, `    echo "$cmdpath:$nargs"`)
+	fmt.Fprintln(w, `end`)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "function __%s_at\n", name)
+	fmt.Fprintf(w, "    string match -q -- \"$argv[1]:*\" (__%s_state)\n", name)
+	fmt.Fprintln(w, `end`)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "function __%s_arg\n", name)
+	fmt.Fprintf(w, "    set -l state (__%s_state)\n", name)
+	fmt.Fprintln(w, `    if test (count $argv) -gt 1`)
+	fmt.Fprintln(w, `        string match -q -- "$argv[1]:$argv[2]" $state`)
+	fmt.Fprintln(w, `    else`)
+	fmt.Fprintln(w, `        string match -q -- "$argv[1]:*" $state`)
+	fmt.Fprintln(w, `    end`)
+	fmt.Fprintln(w, `end`)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "function __%s_dynamic\n", name)
+	fmt.Fprintf(w, "    %s completion $argv[1] 2>/dev/null\n", name)
+	fmt.Fprintln(w, `end`)
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "complete -c %s -f\n", name)
+	root.walk(func(n *completionNode) {
+		cond := fmt.Sprintf("__%s_at '%s'", name, n.path)
+		childCond := cond
+		if _, ok := completionArgs[n.path]; ok {
+			childCond = fmt.Sprintf("__%s_arg '%s' 0", name, n.path)
+		}
+		for _, child := range n.children {
+			fmt.Fprintf(w, "complete -c %s -n \"%s\" -a %s -d %s\n", name, childCond, child.names[0], fishQuote(child.usage))
+		}
+		for _, f := range n.flags {
+			fmt.Fprintf(w, "complete -c %s -n \"%s\"", name, cond)
+			if f.short != "" {
+				fmt.Fprintf(w, " -s %s", f.short)
+			}
+			if f.long != "" {
+				fmt.Fprintf(w, " -l %s", f.long)
+			}
+			if kind, ok := completionFlagValues[f.long]; ok {
+				fmt.Fprintf(w, " -x -a '(__%s_dynamic %s)'", name, kind)
+			} else if f.takesValue {
+				fmt.Fprint(w, " -r")
+			}
+			fmt.Fprintf(w, " -d %s\n", fishQuote(f.usage))
+		}
+		for i, kind := range completionArgs[n.path] {
+			cond := fmt.Sprintf("__%s_arg '%s' %d", name, n.path, i)
+			if kind == "files" {
+				if i == len(completionArgs[n.path])-1 {
+					cond = fmt.Sprintf("not __%s_arg '%s' %d", name, n.path, i-1)
+					cond += fmt.Sprintf("; and __%s_at '%s'", name, n.path)
+				}
+				fmt.Fprintf(w, "complete -c %s -n \"%s\" -F\n", name, cond)
+			} else {
+				fmt.Fprintf(w, "complete -c %s -n \"%s\" -a '(__%s_dynamic %s)'\n", name, cond, name, kind)
+			}
+		}
+	})
+}
+
+// fishPatterns returns a case pattern list matching "path:name" for all names.
+func fishPatterns(path string, names []string) string {
+	var patterns []string
+	for _, name := range names {
+		patterns = append(patterns, fmt.Sprintf("'%s:%s'", path, name))
+	}
+	return strings.Join(patterns, " ")
+}
+
+// fishQuote returns s as a single quoted fish string.
+func fishQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return "'" + strings.Replace(s, "'", `\'`, -1) + "'"
+}
+
+// printRoomCompletions prints the rooms you're a member of, one per line as
+// "id<TAB>title".  Rooms come from the local cache when it's recent enough.
+func printRoomCompletions(ctx context.Context, w io.Writer, config *util.Configuration, spark *api.Spark) error {
+	var rooms []api.Room
+	if !util.LoadCache(accountCache(config, roomsCache), completionMaxAge, &rooms) {
+		roomService := spark.Rooms
+		list, err := roomService.List(ctx)
+		if err != nil {
+			return err
+		}
+		rooms = *list
+		util.SaveCache(accountCache(config, roomsCache), rooms)
+	}
+	if defaultRoomId(config) != "" {
+		fmt.Fprint(w, "-\tdefault room\n")
+	}
+	for _, room := range rooms {
+		fmt.Fprintf(w, "%s\t%s\n", room.Id, room.Title)
+	}
+	return nil
+}
+
+// printMembershipCompletions prints memberships of the default room (or your
+// own memberships without a default room), one per line as "id<TAB>email".
+func printMembershipCompletions(ctx context.Context, w io.Writer, config *util.Configuration, spark *api.Spark) error {
+	roomId := defaultRoomId(config)
+	cache := accountCache(config, "memberships-"+roomId)
+	var mss []api.Membership
+	if !util.LoadCache(cache, completionMaxAge, &mss) {
+		memberService := spark.Memberships
+		list, err := memberService.List(ctx, roomId, "", "")
+		if err != nil {
+			return err
+		}
+		mss = *list
+		util.SaveCache(cache, mss)
+	}
+	for _, ms := range mss {
+		fmt.Fprintf(w, "%s\t%s\n", ms.Id, ms.PersonEmail)
+	}
+	return nil
+}
+
+// accountCache returns the cache entry for name of the account configured in
+// config, so accounts with their own config file don't share cached rooms and
+// memberships.
+func accountCache(config *util.Configuration, name string) string {
+	h := fnv.New32a()
+	io.WriteString(h, config.Path()+"\n"+config.BaseUrl)
+	return fmt.Sprintf("%s-%08x", name, h.Sum32())
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/codegangsta/cli"
+	"github.com/tdeckers/sparkcli/api"
+	"github.com/tdeckers/sparkcli/sparktest"
+	"github.com/tdeckers/sparkcli/util"
+)
+
+func TestWriteCompletion(t *testing.T) {
+	server := sparktest.NewServer()
+	defer server.Close()
+	client, err := util.NewClient(server.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := newApp(server.Config(), client)
+
+	tests := []struct {
+		name  string
+		write func(w io.Writer, app *cli.App)
+		want  []string
+	}{
+		{"bash", writeBashCompletion, []string{
+			"complete -F _sparkcli sparkcli",
+			`"messages:tail"|"messages:t") cmdpath="messages tail" ;;`,
+			`"messages tail:--lines"|"messages tail:-n") i=$((i+1)) ;;`,
+			`"messages tail") words="--follow -f --lines -n" ;;`,
+			`"messages create file") case $nargs in 0) dyn=rooms ;; *) dyn=files ;; esac ;;`,
+			`"messages create") [ $nargs -eq 0 ] && words="text file card"; case $nargs in 0) dyn=rooms ;; esac ;;`,
+		}},
+		{"zsh", writeZshCompletion, []string{
+			"#compdef sparkcli",
+			"compdef _sparkcli sparkcli",
+			`"messages tail") cands=('--follow:keep polling for new messages' '-f:keep polling for new messages'`,
+			`"messages create file") case $nargs in 0) dyn=rooms ;; *) dyn=files ;; esac ;;`,
+			`"messages create") (( nargs == 0 )) && cands=('text:create a new text message'`,
+		}},
+		{"fish", writeFishCompletion, []string{
+			"complete -c sparkcli -n \"__sparkcli_at ''\" -a rooms -d 'operations on rooms'",
+			"complete -c sparkcli -n \"__sparkcli_at 'messages tail'\" -s n -l lines -r -d 'number of messages to show on start'",
+			"complete -c sparkcli -n \"__sparkcli_arg 'messages tail' 0\" -a '(__sparkcli_dynamic rooms)'",
+			"complete -c sparkcli -n \"not __sparkcli_arg 'messages create file' 0; and __sparkcli_at 'messages create file'\" -F",
+			"complete -c sparkcli -n \"__sparkcli_arg 'messages create' 0\" -a text -d 'create a new text message'",
+			"complete -c sparkcli -n \"__sparkcli_arg 'messages create' 0\" -a '(__sparkcli_dynamic rooms)'",
+		}},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		tt.write(&buf, app)
+		for _, want := range tt.want {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("%q. completion script doesn't contain %q", tt.name, want)
+			}
+		}
+	}
+}
+
+func TestBashCompletion(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not found")
+	}
+	server := sparktest.NewServer()
+	defer server.Close()
+	client, err := util.NewClient(server.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	writeBashCompletion(&buf, newApp(server.Config(), client))
+	dir := t.TempDir()
+	script := filepath.Join(dir, "sparkcli.bash")
+	os.WriteFile(script, buf.Bytes(), 0600)
+	// Stands in for sparkcli when the script asks for rooms.
+	stub := filepath.Join(dir, "sparkcli")
+	os.WriteFile(stub, []byte("#!/bin/sh\nprintf 'room-1\\tops\\n'\n"), 0700)
+
+	tests := []struct {
+		name  string
+		words []string
+		want  []string
+	}{
+		{"aliases", []string{"m", "c", ""}, []string{"text", "file", "card", "room-1"}},
+		{"full names", []string{"messages", "create", ""}, []string{"text", "file", "card", "room-1"}},
+		{"subcommand", []string{"m", "c", "text", ""}, []string{"room-1"}},
+		{"message", []string{"m", "c", "room-1", ""}, nil},
+		{"flag value", []string{"memberships", "list", "-r", ""}, []string{"room-1"}},
+	}
+	for _, tt := range tests {
+		cmd := exec.Command(bash, "-c", `source "$1"; shift; COMP_WORDS=("$@"); COMP_CWORD=$(($#-1)); _sparkcli; printf '%s\n' "${COMPREPLY[@]}"`,
+			"bash", script, stub)
+		cmd.Args = append(cmd.Args, tt.words...)
+		out, err := cmd.Output()
+		if err != nil {
+			t.Errorf("%q. completion failed: %v", tt.name, err)
+			continue
+		}
+		if got := strings.Fields(string(out)); strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%q. completion of %q = %q, want %q", tt.name, tt.words, got, tt.want)
+		}
+	}
+}
+
+func TestPrintCompletions(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ctx := context.Background()
+	server := sparktest.NewServer()
+	defer server.Close()
+	room := server.AddRoom("ops", "alice@example.com")
+	config := server.Config()
+	client, err := util.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spark := api.NewSpark(client)
+
+	var buf bytes.Buffer
+	if err := printRoomCompletions(ctx, &buf, config, spark); err != nil {
+		t.Fatalf("printRoomCompletions() error = %v", err)
+	}
+	if want := room.Id + "\tops\n"; buf.String() != want {
+		t.Errorf("printRoomCompletions() = %q, want %q", buf.String(), want)
+	}
+	// Rooms come from the cache now.
+	server.AddRoom("dev")
+	buf.Reset()
+	printRoomCompletions(ctx, &buf, config, spark)
+	if strings.Contains(buf.String(), "dev") {
+		t.Errorf("printRoomCompletions() = %q, want the cached rooms", buf.String())
+	}
+
+	config.DefaultRoomId = room.Id
+	buf.Reset()
+	if err := printMembershipCompletions(ctx, &buf, config, spark); err != nil {
+		t.Fatalf("printMembershipCompletions() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "\talice@example.com\n") {
+		t.Errorf("printMembershipCompletions() = %q, want alice's membership", buf.String())
+	}
+
+	// Another account doesn't see the rooms cached for the first.
+	other := sparktest.NewServer()
+	defer other.Close()
+	other.AddRoom("elsewhere")
+	otherClient, err := util.NewClient(other.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := printRoomCompletions(ctx, &buf, other.Config(), api.NewSpark(otherClient)); err != nil {
+		t.Fatalf("printRoomCompletions() for another account: error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "\telsewhere\n") || strings.Contains(buf.String(), "ops") {
+		t.Errorf("printRoomCompletions() for another account = %q, want only its own rooms", buf.String())
+	}
+}
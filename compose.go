@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/codegangsta/cli"
+)
+
+// readMessage returns the message for 'messages create text' and whether it's
+// markdown.  The message comes from the file given with --file, from stdin
+// (--stdin, or - as the message) or from the arguments after the room.
+func readMessage(c *cli.Context) (string, bool, error) {
+	var data []byte
+	var err error
+	markdown := c.Bool("markdown")
+	args := c.Args().Tail()
+	switch {
+	case c.String("file") != "":
+		if len(args) > 0 {
+			return "", false, errors.New("Can't combine --file with a message")
+		}
+		data, err = ioutil.ReadFile(strings.TrimPrefix(c.String("file"), "@"))
+		markdown = true
+	case c.Bool("stdin") || (len(args) == 1 && args[0] == "-"):
+		if c.Bool("stdin") && len(args) > 0 {
+			return "", false, errors.New("Can't combine --stdin with a message")
+		}
+		data, err = ioutil.ReadAll(os.Stdin)
+	default:
+		data = []byte(strings.Join(args, " "))
+	}
+	if err != nil {
+		return "", false, err
+	}
+	msg := strings.TrimRight(string(data), "\r\n")
+	if strings.TrimSpace(msg) == "" {
+		return "", false, errors.New("Message is empty")
+	}
+	return msg, markdown, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func Test_confirm(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"y\n", true},
+		{"YES\n", true},
+		{" yes ", true},
+		{"n\n", false},
+		{"\n", false},
+		{"", false},
+		{"sure\n", false},
+	}
+	for _, tt := range tests {
+		if got := confirm(strings.NewReader(tt.input), ioutil.Discard, "Delete?"); got != tt.want {
+			t.Errorf("%q. confirm() = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func Test_ellipsis(t *testing.T) {
+	tests := []struct {
+		text string
+		max  int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"a  multi\nline text", 40, "a multi line text"},
+		{"this text is too long", 10, "this te..."},
+	}
+	for _, tt := range tests {
+		if got := ellipsis(tt.text, tt.max); got != tt.want {
+			t.Errorf("%q. ellipsis() = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
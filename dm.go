@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/tdeckers/sparkcli/api"
+)
+
+// resolveEmail returns the email of who, an email or (the start of) a display
+// name.  A name has to match one person, or one person exactly.
+func resolveEmail(ctx context.Context, people api.PeopleAPI, who string) (string, error) {
+	if strings.Contains(who, "@") {
+		return who, nil
+	}
+	found, err := people.List(ctx, "", who)
+	if err != nil {
+		return "", err
+	}
+	var matches []api.People
+	for _, person := range *found {
+		if len(person.Emails) == 0 {
+			continue
+		}
+		if strings.EqualFold(person.DisplayName, who) {
+			return person.Emails[0], nil
+		}
+		matches = append(matches, person)
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("No one named %q found", who)
+	case 1:
+		return matches[0].Emails[0], nil
+	}
+	var names []string
+	for _, person := range matches {
+		names = append(names, person.DisplayName+" <"+person.Emails[0]+">")
+	}
+	return "", fmt.Errorf("%q matches several people, use an email: %s", who, strings.Join(names, ", "))
+}
+
+// findDirectRoom returns your direct room with the person with email, or nil
+// if you never had a conversation.  The API can't look it up, so it checks
+// the members of the direct rooms: one request per room until it's found,
+// which adds up (and counts against the rate limit) with many conversations.
+func findDirectRoom(ctx context.Context, spark *api.Spark, email string) (*api.Room, error) {
+	rooms, err := spark.Rooms.ListAll(ctx, api.RoomFilter{Type: "direct"})
+	if err != nil {
+		return nil, err
+	}
+	for _, room := range *rooms {
+		mss, err := spark.Memberships.List(ctx, room.Id, "", email)
+		if err != nil {
+			return nil, err
+		}
+		if len(*mss) > 0 {
+			return &room, nil
+		}
+	}
+	return nil, nil
+}
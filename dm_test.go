@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/tdeckers/sparkcli/api"
+	"github.com/tdeckers/sparkcli/api/apimock"
+	"github.com/tdeckers/sparkcli/sparktest"
+)
+
+func Test_resolveEmail(t *testing.T) {
+	people := &apimock.PeopleAPI{
+		ListFunc: func(ctx context.Context, email string, displayName string) (*[]api.People, error) {
+			var found []api.People
+			for _, person := range []api.People{
+				{DisplayName: "Ann Smith", Emails: []string{"ann@x.com"}},
+				{DisplayName: "Ann", Emails: []string{"ann2@x.com"}},
+				{DisplayName: "Bob Jones", Emails: []string{"bob@x.com"}},
+				{DisplayName: "Bob Jonas", Emails: []string{"bjonas@x.com"}},
+			} {
+				if strings.HasPrefix(person.DisplayName, displayName) {
+					found = append(found, person)
+				}
+			}
+			return &found, nil
+		},
+	}
+	tests := []struct {
+		name    string
+		who     string
+		want    string
+		wantErr string
+	}{
+		{"email", "carol@x.com", "carol@x.com", ""},
+		{"one match", "Ann S", "ann@x.com", ""},
+		{"exact match", "Ann", "ann2@x.com", ""},
+		{"several", "Bob", "", "matches several people"},
+		{"none", "Dave", "", "No one named"},
+	}
+	for _, tt := range tests {
+		got, err := resolveEmail(context.Background(), people, tt.who)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%q. resolveEmail() error = %v, want %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%q. resolveEmail() = %v, %v, want %v", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestDmCommands(t *testing.T) {
+	server := sparktest.NewServer()
+	defer server.Close()
+	server.AddPerson("alice@example.com", "Alice Liddell")
+	server.AddPerson("bob@example.com", "Bob")
+	server.AddRoom("ops", "alice@example.com")
+
+	if _, err := runApp(t, server, "dm", "bob@example.com", "hi", "bob"); err != nil {
+		t.Fatalf("dm bob: error = %v", err)
+	}
+	// Text that starts with a command name is still sent.
+	if _, err := runApp(t, server, "dm", "Alice", "history", "me"); err != nil {
+		t.Fatalf("dm Alice: error = %v", err)
+	}
+	var direct api.Room
+	for _, room := range server.Rooms() {
+		if room.Type == "direct" && room.Title == "Alice Liddell" {
+			direct = room
+		}
+	}
+	if direct.Id == "" {
+		t.Fatalf("dm Alice: no direct room with alice in %+v", server.Rooms())
+	}
+	server.AddMessage(direct.Id, "alice@example.com", "sure")
+
+	out, err := runApp(t, server, "dm", "history", "alice@example.com")
+	var msgs []api.Message
+	if err != nil || json.Unmarshal([]byte(out), &msgs) != nil {
+		t.Fatalf("dm history = %q, %v, want messages as json", out, err)
+	}
+	var got []string
+	for _, msg := range msgs {
+		got = append(got, msg.PersonEmail+": "+msg.Text)
+	}
+	want := []string{sparktest.MeEmail + ": history me", "alice@example.com: sure"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("dm history = %q, want %q", got, want)
+	}
+
+	if _, err := runApp(t, server, "dm", "history", "carol@example.com"); err == nil ||
+		!strings.Contains(err.Error(), "No conversation") {
+		t.Errorf("dm history carol: error = %v, want no conversation", err)
+	}
+}
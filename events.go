@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/tdeckers/sparkcli/api"
+)
+
+// eventTimeFormat is the time format the events API expects for from and to.
+const eventTimeFormat = "2006-01-02T15:04:05.000Z"
+
+// parseEventTime converts a --from or --to value to the format of the events
+// API.  The value is a date (2016-06-01), an RFC3339 time, or a duration
+// (24h) meaning that long before now.
+func parseEventTime(s string, now time.Time) (string, error) {
+	if s == "" {
+		return "", nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d).UTC().Format(eventTimeFormat), nil
+	}
+	t, err := parseSince(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid time %q, want a date, an RFC3339 time or a duration", s)
+	}
+	return t.UTC().Format(eventTimeFormat), nil
+}
+
+// eventFilterFlags are the flags of the events commands selecting events.
+var eventFilterFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "resource, r",
+		Usage: "messages or memberships",
+	},
+	cli.StringFlag{
+		Name:  "type, t",
+		Usage: "created, updated or deleted",
+	},
+	cli.StringFlag{
+		Name:  "actor, a",
+		Usage: "id of the person who caused the events",
+	},
+	cli.StringFlag{
+		Name:  "from",
+		Usage: "events since a date, RFC3339 time or duration ago (24h)",
+	},
+	cli.StringFlag{
+		Name:  "to",
+		Usage: "events before a date, RFC3339 time or duration ago",
+	},
+}
+
+// eventFilter returns the filter set by the eventFilterFlags of c.
+func eventFilter(c *cli.Context) (api.EventFilter, error) {
+	now := time.Now()
+	from, err := parseEventTime(c.String("from"), now)
+	if err != nil {
+		return api.EventFilter{}, err
+	}
+	to, err := parseEventTime(c.String("to"), now)
+	if err != nil {
+		return api.EventFilter{}, err
+	}
+	return api.EventFilter{Resource: c.String("resource"), Type: c.String("type"),
+		ActorId: c.String("actor"), From: from, To: to}, nil
+}
+
+// EventWatcher prints new events as they happen, one json object per line.
+type EventWatcher struct {
+	events api.EventAPI
+	filter api.EventFilter
+	out    io.Writer
+	// last is the creation time of the newest event printed; seen holds the
+	// ids of the events printed with that time, as the next poll returns
+	// them again.
+	last string
+	seen map[string]bool
+}
+
+// newEventWatcher creates an EventWatcher printing the events matching
+// filter to out.  Without filter.From, it starts at the current time.
+func newEventWatcher(events api.EventAPI, filter api.EventFilter, out io.Writer) *EventWatcher {
+	w := &EventWatcher{events: events, filter: filter, out: out, seen: map[string]bool{}}
+	w.last = filter.From
+	if w.last == "" {
+		w.last = time.Now().UTC().Format(eventTimeFormat)
+	}
+	return w
+}
+
+// Run polls for new events every interval until ctx is cancelled.  Like
+// messages tail, it keeps going on server and network errors.
+func (w *EventWatcher) Run(ctx context.Context, interval time.Duration) error {
+	err := pollLoop(ctx, interval, func(time.Duration) (time.Duration, bool, error) {
+		_, err := w.poll(ctx)
+		return interval, false, err
+	})
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// poll requests the events since the last one printed and prints the new
+// ones, oldest first.  It returns the number of events printed.
+func (w *EventWatcher) poll(ctx context.Context) (int, error) {
+	filter := w.filter
+	filter.From = w.last
+	var fresh []api.Event
+	err := w.events.ListPages(ctx, filter, 1000, func(page []api.Event) bool {
+		for _, event := range page {
+			if !w.seen[event.Id] {
+				fresh = append(fresh, event)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	for i := len(fresh) - 1; i >= 0; i-- {
+		event := fresh[i]
+		line, err := json.Marshal(event)
+		if err != nil {
+			return 0, err
+		}
+		fmt.Fprintln(w.out, string(line))
+		if event.Created != w.last {
+			w.last = event.Created
+			w.seen = map[string]bool{}
+		}
+		w.seen[event.Id] = true
+	}
+	return len(fresh), nil
+}
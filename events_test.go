@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tdeckers/sparkcli/api"
+	"github.com/tdeckers/sparkcli/api/apimock"
+	"github.com/tdeckers/sparkcli/sparktest"
+	"github.com/tdeckers/sparkcli/util"
+)
+
+func Test_parseEventTime(t *testing.T) {
+	now := time.Date(2016, 6, 2, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	tests := []struct {
+		name    string
+		s       string
+		want    string
+		wantErr bool
+	}{
+		{"empty", "", "", false},
+		{"duration", "24h", "2016-06-01T10:00:00.000Z", false},
+		{"date", "2016-05-01", "2016-05-01T00:00:00.000Z", false},
+		{"rfc3339", "2016-05-01T09:30:00+02:00", "2016-05-01T07:30:00.000Z", false},
+		{"invalid", "yesterday", "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseEventTime(tt.s, now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. parseEventTime() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q. parseEventTime() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEventWatcherPoll(t *testing.T) {
+	server := sparktest.NewServer()
+	defer server.Close()
+	room := server.AddRoom("ops", "alice@example.com")
+	client, err := util.NewClient(server.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	var out bytes.Buffer
+	filter := api.EventFilter{Resource: "messages", From: "2016-01-01T00:00:00.000Z"}
+	w := newEventWatcher(api.NewSpark(client).Events, filter, &out)
+
+	server.AddMessage(room.Id, "alice@example.com", "one")
+	server.AddMessage(room.Id, "alice@example.com", "two")
+	if n, err := w.poll(ctx); err != nil || n != 2 {
+		t.Fatalf("poll() = %v, %v, want 2 events", n, err)
+	}
+	server.AddMessage(room.Id, "alice@example.com", "three")
+	if n, err := w.poll(ctx); err != nil || n != 1 {
+		t.Fatalf("second poll() = %v, %v, want 1 event", n, err)
+	}
+	if n, err := w.poll(ctx); err != nil || n != 0 {
+		t.Fatalf("third poll() = %v, %v, want no events", n, err)
+	}
+
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var event api.Event
+		var msg api.Message
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("poll() printed %q: %v", line, err)
+		}
+		json.Unmarshal(event.Data, &msg)
+		got = append(got, msg.Text)
+	}
+	if want := "one two three"; strings.Join(got, " ") != want {
+		t.Errorf("poll() printed messages %q, want %q", got, want)
+	}
+}
+
+func TestEventWatcherRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	polls := 0
+	events := &apimock.EventAPI{
+		ListPagesFunc: func(ctx context.Context, filter api.EventFilter, max int, page func([]api.Event) bool) error {
+			polls++
+			switch polls {
+			case 1:
+				return &util.APIError{StatusCode: 503, Status: "503 Service Unavailable"}
+			case 2:
+				page([]api.Event{{Id: "event1", Resource: "messages", Created: "2016-06-02T10:00:00.000Z"}})
+			default:
+				cancel()
+			}
+			return nil
+		},
+	}
+	var out bytes.Buffer
+	w := newEventWatcher(events, api.EventFilter{From: "2016-06-02T00:00:00.000Z"}, &out)
+	if err := w.Run(ctx, time.Millisecond); err != nil {
+		t.Errorf("Run() error = %v, want nil when cancelled", err)
+	}
+	if polls != 3 || !strings.Contains(out.String(), `"id":"event1"`) {
+		t.Errorf("Run() polled %d times and printed %q, want event1 after a failed poll", polls, out.String())
+	}
+
+	events.ListPagesFunc = func(ctx context.Context, filter api.EventFilter, max int, page func([]api.Event) bool) error {
+		return &util.APIError{StatusCode: 403, Status: "403 Forbidden"}
+	}
+	if err := w.Run(context.Background(), time.Millisecond); err == nil {
+		t.Errorf("Run() when forbidden: error = nil, want 403")
+	}
+}
+
+func TestEventsList(t *testing.T) {
+	server := sparktest.NewServer()
+	defer server.Close()
+	room := server.AddRoom("ops")
+	server.AddMessage(room.Id, "alice@example.com", "hi there")
+
+	out, err := runApp(t, server, "-j=false", "events", "list", "-r", "messages", "--from", "2016-01-01")
+	if err != nil {
+		t.Fatalf("events list: error = %v", err)
+	}
+	if !strings.Contains(out, "messages created by") || strings.Count(out, "\n") != 1 {
+		t.Errorf("events list printed %q, want the created message", out)
+	}
+	if _, err := runApp(t, server, "events", "list", "--from", "yesterday"); err == nil {
+		t.Errorf("events list --from yesterday: no error, want an invalid time")
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tdeckers/sparkcli/api"
+)
+
+// exportPageSize is the number of messages requested per page when exporting.
+const exportPageSize = 100
+
+// Archive is the exported history of a room.  It's kept as json, next to the
+// html or markdown rendering of it, so a later export only has to add the
+// messages posted since.
+type Archive struct {
+	Room     api.Room          `json:"room"`
+	Exported string            `json:"exported"`
+	Messages []ArchivedMessage `json:"messages"` // oldest first
+}
+
+// ArchivedMessage is a message in an Archive.
+type ArchivedMessage struct {
+	api.Message
+	PersonName string `json:"personName,omitempty"`
+	// Attachments are the paths of the downloaded files, relative to the
+	// archive.
+	Attachments []string `json:"attachments,omitempty"`
+}
+
+// Exporter exports the history of a room.
+type Exporter struct {
+	spark       *api.Spark
+	format      string // json, html or md
+	output      string
+	since       time.Time
+	attachments bool
+
+	names map[string]string // person id to display name
+}
+
+// newExporter creates an Exporter writing format to output (or a file named
+// after the room if output is empty).  Only messages posted after since are
+// exported, and when attachments is set files are downloaded next to the
+// output.
+func newExporter(spark *api.Spark, format, output string, since time.Time, attachments bool) (*Exporter, error) {
+	switch format {
+	case "json", "html", "md":
+	default:
+		return nil, errors.New("Unknown format '" + format + "', use json, html or md")
+	}
+	return &Exporter{spark: spark, format: format, output: output, since: since,
+		attachments: attachments, names: map[string]string{}}, nil
+}
+
+// Export adds the messages posted since the previous export (if any) to the
+// archive and writes the output.  It returns the number of messages added.
+func (e *Exporter) Export(ctx context.Context, roomId string) (int, error) {
+	roomService := e.spark.Rooms
+	room, err := roomService.Get(ctx, roomId)
+	if err != nil {
+		return 0, err
+	}
+	if e.output == "" {
+		e.output = exportFileName(room.Title) + "." + e.format
+	}
+	archive, err := e.load()
+	if err != nil {
+		return 0, err
+	}
+	if archive.Room.Id != "" && archive.Room.Id != roomId {
+		return 0, errors.New(e.archivePath() + " holds the history of another room")
+	}
+	archive.Room = *room
+
+	var last ArchivedMessage
+	if len(archive.Messages) > 0 {
+		last = archive.Messages[len(archive.Messages)-1]
+	}
+	// Messages come newest first: collect them until reaching the last one
+	// exported before, or the start date.
+	var fresh []api.Message
+	msgService := e.spark.Messages
+	err = msgService.ListPages(ctx, roomId, exportPageSize, func(msgs []api.Message) bool {
+		for _, msg := range msgs {
+			if msg.Id == last.Id || (last.Id != "" && msg.Created < last.Created) {
+				return false
+			}
+			if created, err := time.Parse(time.RFC3339Nano, msg.Created); err == nil && created.Before(e.since) {
+				return false
+			}
+			fresh = append(fresh, msg)
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for i := len(fresh) - 1; i >= 0; i-- {
+		am := ArchivedMessage{Message: fresh[i], PersonName: e.personName(ctx, fresh[i])}
+		if e.attachments && len(am.Files) > 0 {
+			am.Attachments, err = e.download(ctx, am.Message, len(archive.Messages))
+			if err != nil {
+				return 0, err
+			}
+		}
+		archive.Messages = append(archive.Messages, am)
+	}
+	archive.Exported = time.Now().UTC().Format(time.RFC3339)
+
+	if err := e.save(archive); err != nil {
+		return 0, err
+	}
+	return len(fresh), e.render(archive)
+}
+
+// archivePath returns the location of the json archive, which is the output
+// itself for the json format.
+func (e *Exporter) archivePath() string {
+	return strings.TrimSuffix(e.output, filepath.Ext(e.output)) + ".json"
+}
+
+// filesDir returns the directory attachments are downloaded to.
+func (e *Exporter) filesDir() string {
+	return strings.TrimSuffix(e.output, filepath.Ext(e.output)) + "_files"
+}
+
+// load reads the archive of a previous export.  It returns an empty archive if
+// there is none.
+func (e *Exporter) load() (*Archive, error) {
+	archive := &Archive{}
+	data, err := ioutil.ReadFile(e.archivePath())
+	if os.IsNotExist(err) {
+		return archive, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, archive); err != nil {
+		return nil, fmt.Errorf("Failed to read %s: %s", e.archivePath(), err)
+	}
+	return archive, nil
+}
+
+func (e *Exporter) save(archive *Archive) error {
+	data, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(e.archivePath(), data, 0644)
+}
+
+// personName returns the display name of the sender of msg.  Names are looked
+// up once per person; the email is used when the lookup fails.
+func (e *Exporter) personName(ctx context.Context, msg api.Message) string {
+	if name, ok := e.names[msg.PersonId]; ok {
+		return name
+	}
+	name := msg.PersonEmail
+	peopleService := e.spark.People
+	if person, err := peopleService.Get(ctx, msg.PersonId); err == nil && person.DisplayName != "" {
+		name = person.DisplayName
+	} else if err != nil {
+		slog.Warn("Failed to get name", "email", msg.PersonEmail, "err", err)
+	}
+	e.names[msg.PersonId] = name
+	return name
+}
+
+// download stores the files of msg, the index'th message of the archive, in a
+// directory of its own.  It returns the paths relative to the archive.
+func (e *Exporter) download(ctx context.Context, msg api.Message, index int) ([]string, error) {
+	dir := filepath.Join(e.filesDir(), fmt.Sprintf("%05d", index))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	base := filepath.Dir(e.output)
+	fileService := e.spark.Files
+	var paths []string
+	for _, file := range msg.Files {
+		path, err := downloadFile(ctx, fileService, file, dir, true)
+		if err != nil {
+			return nil, err
+		}
+		if rel, err := filepath.Rel(base, path); err == nil {
+			path = rel
+		}
+		paths = append(paths, filepath.ToSlash(path))
+	}
+	return paths, nil
+}
+
+// render writes the archive to the output in the html or markdown format.
+func (e *Exporter) render(archive *Archive) error {
+	if e.format == "json" {
+		return nil // the archive is the output
+	}
+	f, err := os.Create(e.output)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if e.format == "html" {
+		err = exportTemplate.Execute(w, archive)
+	} else {
+		err = renderMarkdown(w, archive)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// renderMarkdown writes archive as a markdown document.
+func renderMarkdown(w io.Writer, archive *Archive) error {
+	fmt.Fprintf(w, "# %s\n\n", archive.Room.Title)
+	fmt.Fprintf(w, "_Exported %s, %d messages._\n", archive.Exported, len(archive.Messages))
+	for _, msg := range archive.Messages {
+		fmt.Fprintf(w, "\n---\n\n**%s** <%s> _%s_\n\n", msg.PersonName, msg.PersonEmail, msg.Created)
+		if msg.Markdown != "" {
+			fmt.Fprintln(w, msg.Markdown)
+		} else if msg.Text != "" {
+			fmt.Fprintln(w, msg.Text)
+		}
+		for _, path := range msg.Attachments {
+			fmt.Fprintf(w, "\n- [%s](%s)\n", filepath.Base(path), path)
+		}
+		if len(msg.Attachments) == 0 {
+			for _, file := range msg.Files {
+				fmt.Fprintf(w, "\n- <%s>\n", file)
+			}
+		}
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// exportTemplate renders an Archive as a single html page, with the styles
+// included so it can be viewed on its own.
+var exportTemplate = template.Must(template.New("export").Funcs(template.FuncMap{
+	"base": filepath.Base,
+	"image": func(path string) bool {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".png", ".jpg", ".jpeg", ".gif", ".svg", ".webp":
+			return true
+		}
+		return false
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Room.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 2em auto; color: #222; }
+.meta { color: #777; font-size: 0.9em; }
+.message { border-top: 1px solid #ddd; padding: 0.8em 0; }
+.sender { font-weight: bold; }
+.text { white-space: pre-wrap; margin-top: 0.3em; }
+.files img { max-width: 100%; display: block; margin-top: 0.5em; }
+</style>
+</head>
+<body>
+<h1>{{.Room.Title}}</h1>
+<p class="meta">Exported {{.Exported}}, {{len .Messages}} messages.</p>
+{{range .Messages}}<div class="message">
+<div><span class="sender">{{.PersonName}}</span> <span class="meta">&lt;{{.PersonEmail}}&gt; {{.Created}}</span></div>
+<div class="text">{{if .Markdown}}{{.Markdown}}{{else}}{{.Text}}{{end}}</div>
+{{if .Attachments}}<div class="files">{{range .Attachments}}{{if image .}}<img src="{{.}}" alt="{{base .}}">{{else}}<a href="{{.}}">{{base .}}</a><br>{{end}}{{end}}</div>
+{{else if .Files}}<div class="files">{{range .Files}}<a href="{{.}}">{{.}}</a><br>{{end}}</div>
+{{end}}</div>
+{{end}}</body>
+</html>
+`))
+
+// exportFileName turns a room title into a file name (without extension).
+func exportFileName(title string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, title)
+	if name == "" {
+		return "room"
+	}
+	return name
+}
+
+// parseSince parses the --since value: a date (2006-01-02) or a date and time
+// (RFC 3339).  An empty value means no limit.
+func parseSince(since string) (time.Time, error) {
+	if since == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", since); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, since)
+}
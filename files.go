@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/tdeckers/sparkcli/api"
+	"github.com/tdeckers/sparkcli/util"
+)
+
+// downloadFile stores the file at fileUrl in dir, under the name the service
+// gives it.  It refuses to overwrite existing files unless force is set.  The
+// file is written to a temporary file first, so an interrupted download
+// doesn't leave a partial file behind.  It returns the path of the file.
+func downloadFile(ctx context.Context, fileService api.FileAPI, fileUrl string, dir string, force bool) (string, error) {
+	info, body, err := fileService.Download(ctx, fileUrl)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	name := filepath.Base(info.Name)
+	if name == "." || name == string(filepath.Separator) {
+		name = "file"
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err == nil && !force {
+		return "", errors.New(path + " already exists, use --force to overwrite")
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+name+".")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name()) // no-op after a successful rename
+
+	progress := util.NewProgress(name, info.Size)
+	_, err = io.Copy(io.MultiWriter(tmp, progress), body)
+	progress.Finish()
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// uploadProgress returns a Progress for uploading the files at paths.
+func uploadProgress(paths []string) *util.Progress {
+	var total int64
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			total += info.Size()
+		}
+	}
+	return util.NewProgress("Uploading", total)
+}
@@ -0,0 +1,13 @@
+module github.com/tdeckers/sparkcli
+
+go 1.21
+
+require (
+	github.com/BurntSushi/toml v0.3.1
+	github.com/codegangsta/cli v1.21.0
+	github.com/peterh/liner v1.2.2
+)
+
+require github.com/mattn/go-runewidth v0.0.3 // indirect
+
+replace github.com/codegangsta/cli => github.com/urfave/cli v1.21.0
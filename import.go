@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tdeckers/sparkcli/api"
+	"github.com/tdeckers/sparkcli/util"
+)
+
+// importRetries is the number of times a rate limited post is retried.
+const importRetries = 5
+
+// Importer posts the messages of an Archive (see rooms export) to a room.
+type Importer struct {
+	roomId string
+	// delay is the pause between posts, to stay clear of rate limits.
+	delay time.Duration
+
+	msgService  api.MessageAPI
+	fileService api.FileAPI
+}
+
+// newImporter creates an Importer posting to roomId.
+func newImporter(spark *api.Spark, roomId string, delay time.Duration) *Importer {
+	return &Importer{
+		roomId:      roomId,
+		delay:       delay,
+		msgService:  spark.Messages,
+		fileService: spark.Files,
+	}
+}
+
+// loadArchive reads an archive written by rooms export.
+func loadArchive(path string) (*Archive, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	archive := &Archive{}
+	if err := json.Unmarshal(data, archive); err != nil {
+		return nil, fmt.Errorf("Failed to read %s: %s", path, err)
+	}
+	return archive, nil
+}
+
+// Import posts the messages of the archive at path, starting at message from
+// (counting from 1).  It returns the number of messages posted.  When ctx is
+// cancelled, it stops after the message being posted.  On a dry run it goes
+// through all messages, and then returns util.ErrDryRun.
+func (i *Importer) Import(ctx context.Context, path string, from int) (int, error) {
+	archive, err := loadArchive(path)
+	if err != nil {
+		return 0, err
+	}
+	dir := filepath.Dir(path)
+	count := 0
+	dryRun := false
+	for n, msg := range archive.Messages {
+		if n+1 < from {
+			continue
+		}
+		if count > 0 {
+			select {
+			case <-time.After(i.delay):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			return count, fmt.Errorf("Interrupted, continue with --from %d", n+1)
+		}
+		err := i.post(ctx, dir, msg)
+		if errors.Is(err, util.ErrDryRun) {
+			dryRun = true
+			continue
+		}
+		if err != nil {
+			return count, fmt.Errorf("Failed to post message %d of %d (continue with --from %d): %w",
+				n+1, len(archive.Messages), n+1, err)
+		}
+		count++
+		slog.Info("Posted message", "number", n+1, "total", len(archive.Messages))
+	}
+	if dryRun {
+		return count, util.ErrDryRun
+	}
+	return count, nil
+}
+
+// post posts a single message, with a header naming the original sender and
+// time.  Attachments are uploaded from the files downloaded with the archive,
+// or else downloaded again from their original location.
+func (i *Importer) post(ctx context.Context, dir string, msg ArchivedMessage) error {
+	body := msg.Markdown
+	if body == "" {
+		body = msg.Text
+	}
+	if body == "" && len(msg.Files) == 0 {
+		return nil // nothing to repost
+	}
+	markdown := importHeader(msg)
+	if body != "" {
+		markdown += "\n\n" + body
+	}
+
+	var files []string
+	for _, attachment := range msg.Attachments {
+		files = append(files, filepath.Join(dir, filepath.FromSlash(attachment)))
+	}
+	if len(files) == 0 && len(msg.Files) > 0 {
+		tmp, err := ioutil.TempDir("", "sparkcli")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+		for _, file := range msg.Files {
+			path, err := downloadFile(ctx, i.fileService, file, tmp, true)
+			if err != nil {
+				return err
+			}
+			files = append(files, path)
+		}
+	}
+
+	parts := util.SplitMessage(markdown, api.MaxMessageSize)
+	dryRun := false
+	for n, part := range parts {
+		err := retryRateLimited(ctx, func() error {
+			var err error
+			if n == len(parts)-1 && len(files) > 0 {
+				progress := uploadProgress(files)
+				_, err = i.msgService.CreateFiles(ctx, i.roomId, "", part, files, progress)
+				progress.Finish()
+			} else {
+				_, err = i.msgService.CreateMarkdown(ctx, i.roomId, part)
+			}
+			return err
+		})
+		if errors.Is(err, util.ErrDryRun) {
+			dryRun = true
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if dryRun {
+		return util.ErrDryRun
+	}
+	return nil
+}
+
+// importHeader returns the line naming the original sender and time of msg.
+func importHeader(msg ArchivedMessage) string {
+	sender := msg.PersonName
+	if sender == "" {
+		sender = msg.PersonEmail
+	}
+	created := msg.Created
+	if t, err := time.Parse(time.RFC3339Nano, msg.Created); err == nil {
+		created = t.UTC().Format("2006-01-02 15:04 MST")
+	}
+	return fmt.Sprintf("_Originally from **%s** at %s_", sender, created)
+}
+
+// retryRateLimited calls fn, and calls it again after the requested delay
+// while the service responds with 429 (Too Many Requests), unless ctx is
+// cancelled while waiting.
+func retryRateLimited(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		apiErr, ok := err.(*util.APIError)
+		if !ok || apiErr.StatusCode != 429 || attempt > importRetries {
+			return err
+		}
+		wait := apiErr.RetryAfter
+		if wait == 0 {
+			wait = time.Duration(attempt) * 10 * time.Second
+		}
+		slog.Warn("Rate limited, retrying", "wait", wait)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
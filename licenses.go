@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/tdeckers/sparkcli/api"
+)
+
+// writeLicenseUsage writes how many units of each license are consumed, out
+// of the total, as a table or as csv.
+func writeLicenseUsage(w io.Writer, licenses []api.License, format string) error {
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "License\tConsumed\tTotal\tAvailable\tUsage")
+		for _, license := range licenses {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\n", license.Name, license.ConsumedUnits, license.TotalUnits,
+				license.TotalUnits-license.ConsumedUnits, usagePercent(license))
+		}
+		return tw.Flush()
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"id", "name", "consumed", "total", "available"})
+		for _, license := range licenses {
+			cw.Write([]string{license.Id, license.Name, strconv.Itoa(license.ConsumedUnits),
+				strconv.Itoa(license.TotalUnits), strconv.Itoa(license.TotalUnits - license.ConsumedUnits)})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return errors.New("Unknown format '" + format + "', use table, csv or json")
+}
+
+// usagePercent returns the part of the units of license that is consumed.
+func usagePercent(license api.License) string {
+	if license.TotalUnits == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d%%", license.ConsumedUnits*100/license.TotalUnits)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/tdeckers/sparkcli/api"
+	"github.com/tdeckers/sparkcli/sparktest"
+)
+
+func Test_writeLicenseUsage(t *testing.T) {
+	licenses := []api.License{
+		{Id: "l1", Name: "Messaging", ConsumedUnits: 15, TotalUnits: 20},
+		{Id: "l2", Name: "Meetings, Premium", ConsumedUnits: 0, TotalUnits: 0},
+	}
+	tests := []struct {
+		name    string
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{"table", "table", "" +
+			"License            Consumed  Total  Available  Usage\n" +
+			"Messaging          15        20     5          75%\n" +
+			"Meetings, Premium  0         0      0          -\n", false},
+		{"csv", "csv", "" +
+			"id,name,consumed,total,available\n" +
+			"l1,Messaging,15,20,5\n" +
+			"l2,\"Meetings, Premium\",0,0,0\n", false},
+		{"unknown", "xml", "", true},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		err := writeLicenseUsage(&buf, licenses, tt.format)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. writeLicenseUsage() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got := buf.String(); got != tt.want {
+			t.Errorf("%q. writeLicenseUsage() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLicensesUsage(t *testing.T) {
+	server := sparktest.NewServer()
+	defer server.Close()
+	license := server.AddLicense("Messaging", 3)
+	if _, err := runApp(t, server, "people", "create", "-e", "alice@example.com", "--license", license.Id); err != nil {
+		t.Fatalf("people create: error = %v", err)
+	}
+
+	out, err := runApp(t, server, "licenses", "usage", "-f", "csv")
+	want := "id,name,consumed,total,available\n" + license.Id + ",Messaging,1,3,2\n"
+	if err != nil || out != want {
+		t.Errorf("licenses usage -f csv = %q, %v, want %q", out, err, want)
+	}
+	out, err = runApp(t, server, "licenses", "usage")
+	if err != nil || !strings.HasPrefix(out, "License ") || !strings.Contains(out, "33%") {
+		t.Errorf("licenses usage = %q, %v, want a table", out, err)
+	}
+	out, err = runApp(t, server, "licenses", "usage", "-f", "json")
+	if err != nil || !strings.HasPrefix(out, "[") || !strings.Contains(out, `"consumedUnits": 1`) {
+		t.Errorf("licenses usage -f json = %q, %v, want the licenses as json", out, err)
+	}
+}
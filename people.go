@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/tdeckers/sparkcli/api"
+)
+
+// personFlags are the flags of 'people create' and 'people update' setting
+// the details of a person.
+var personFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "email, e",
+		Usage: "email address",
+	},
+	cli.StringFlag{
+		Name:  "name, n",
+		Usage: "display name",
+	},
+	cli.StringFlag{
+		Name:  "first-name",
+		Usage: "first name",
+	},
+	cli.StringFlag{
+		Name:  "last-name",
+		Usage: "last name",
+	},
+	cli.StringFlag{
+		Name:  "nick-name",
+		Usage: "nick name",
+	},
+	cli.StringSliceFlag{
+		Name:  "phone",
+		Usage: "phone number as type:number, e.g. work:+3212345678 (repeat for more)",
+	},
+	cli.StringSliceFlag{
+		Name:  "role",
+		Usage: "role id (repeat for more, replaces all roles)",
+	},
+	cli.StringSliceFlag{
+		Name:  "license",
+		Usage: "license id (repeat for more, replaces all licenses)",
+	},
+}
+
+// personFlagSet reports whether any of the personFlags is given.
+func personFlagSet(c *cli.Context) bool {
+	for _, flag := range personFlags {
+		name := strings.Split(flag.GetName(), ",")[0]
+		if c.IsSet(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// setPersonFlags changes the details of person given with personFlags.
+func setPersonFlags(c *cli.Context, person *api.People) error {
+	if email := c.String("email"); email != "" {
+		person.Emails = []string{email}
+	}
+	for flag, field := range map[string]*string{"name": &person.DisplayName, "first-name": &person.FirstName,
+		"last-name": &person.LastName, "nick-name": &person.NickName} {
+		if c.IsSet(flag) {
+			*field = c.String(flag)
+		}
+	}
+	if phones := c.StringSlice("phone"); len(phones) > 0 {
+		person.PhoneNumbers = nil
+		for _, phone := range phones {
+			kind, number, ok := strings.Cut(phone, ":")
+			if !ok || number == "" {
+				return fmt.Errorf("Invalid phone number %q, use type:number", phone)
+			}
+			person.PhoneNumbers = append(person.PhoneNumbers, api.PhoneNumber{Type: kind, Value: number})
+		}
+	}
+	if roles := c.StringSlice("role"); len(roles) > 0 {
+		person.Roles = roles
+	}
+	if licenses := c.StringSlice("license"); len(licenses) > 0 {
+		person.Licenses = licenses
+	}
+	return nil
+}
+
+// printPerson prints the details of a person as text.
+func printPerson(person *api.People) {
+	fmt.Printf("Id:            %s\n", person.Id)
+	fmt.Printf("Name:          %s\n", person.DisplayName)
+	if person.NickName != "" {
+		fmt.Printf("Nick Name:     %s\n", person.NickName)
+	}
+	for _, email := range person.Emails {
+		fmt.Printf("Email:         %s\n", email)
+	}
+	for _, phone := range person.PhoneNumbers {
+		fmt.Printf("Phone:         %s (%s)\n", phone.Value, phone.Type)
+	}
+	fmt.Printf("Avatar:        %s\n", person.Avatar)
+	fmt.Printf("OrgId:         %s\n", person.OrgId)
+	for _, role := range person.Roles {
+		fmt.Printf("Role:          %s\n", role)
+	}
+	for _, license := range person.Licenses {
+		fmt.Printf("License:       %s\n", license)
+	}
+	if person.Status != "" {
+		fmt.Printf("Status:        %s\n", person.Status)
+	}
+	if person.LastActivity != "" {
+		fmt.Printf("Last Activity: %s\n", person.LastActivity)
+	}
+	fmt.Printf("Created:       %s\n", person.Created)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/tdeckers/sparkcli/api"
+)
+
+// personEmailEnv is set to the email of the person who became active for the
+// command run by 'people status --wait-active --exec'.
+const personEmailEnv = "SPARKCLI_PERSON_EMAIL"
+
+// findPeople looks up the people with the given emails.
+func findPeople(ctx context.Context, people api.PeopleAPI, emails []string) ([]api.People, error) {
+	var found []api.People
+	for _, email := range emails {
+		result, err := people.List(ctx, email, "")
+		if err != nil {
+			return nil, err
+		}
+		if len(*result) == 0 {
+			return nil, fmt.Errorf("No one with email %s found", email)
+		}
+		found = append(found, (*result)[0])
+	}
+	return found, nil
+}
+
+// waitActive polls the status of people every interval until one of them is
+// active, and returns that person.  Like messages tail, it keeps going on
+// server and network errors.  It returns ctx.Err() when ctx is cancelled
+// first.
+func waitActive(ctx context.Context, people api.PeopleAPI, ids []string, interval time.Duration) (*api.People, error) {
+	var active *api.People
+	err := pollLoop(ctx, interval, func(time.Duration) (time.Duration, bool, error) {
+		person, err := firstActive(ctx, people, ids)
+		active = person
+		return interval, person != nil, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return active, nil
+}
+
+// firstActive returns the first of the people with the given ids who's
+// active, or nil if no one is.
+func firstActive(ctx context.Context, people api.PeopleAPI, ids []string) (*api.People, error) {
+	for _, id := range ids {
+		person, err := people.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if person.Status == "active" {
+			return person, nil
+		}
+	}
+	return nil, nil
+}
+
+// runForPerson runs command with the shell, with personEmailEnv set to the
+// email of person.
+func runForPerson(command string, person *api.People) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	if len(person.Emails) > 0 {
+		cmd.Env = append(os.Environ(), personEmailEnv+"="+person.Emails[0])
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+// printStatus prints the status of a person as a line of text.
+func printStatus(person api.People) {
+	email := ""
+	if len(person.Emails) > 0 {
+		email = person.Emails[0]
+	}
+	status := person.Status
+	if status == "" {
+		status = "unknown"
+	}
+	fmt.Printf("%-30s %-14s %s\n", email, status, person.LastActivity)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tdeckers/sparkcli/api"
+	"github.com/tdeckers/sparkcli/api/apimock"
+	"github.com/tdeckers/sparkcli/sparktest"
+	"github.com/tdeckers/sparkcli/util"
+)
+
+func TestWaitActive(t *testing.T) {
+	polls := 0
+	people := &apimock.PeopleAPI{
+		GetFunc: func(ctx context.Context, id string) (*api.People, error) {
+			if id == "alice" {
+				polls++
+			}
+			switch {
+			case polls == 2:
+				return nil, &util.APIError{StatusCode: 503, Status: "503 Service Unavailable"}
+			case polls >= 3 && id == "bob":
+				return &api.People{Id: id, Status: "active"}, nil
+			}
+			return &api.People{Id: id, Status: "DoNotDisturb"}, nil
+		},
+	}
+	person, err := waitActive(context.Background(), people, []string{"alice", "bob"}, time.Millisecond)
+	if err != nil || person.Id != "bob" {
+		t.Errorf("waitActive() = %+v, %v, want bob after a failed poll", person, err)
+	}
+
+	people.GetFunc = func(ctx context.Context, id string) (*api.People, error) {
+		return nil, &util.APIError{StatusCode: 404, Status: "404 Not Found"}
+	}
+	if _, err := waitActive(context.Background(), people, []string{"alice"}, time.Millisecond); err == nil {
+		t.Errorf("waitActive() for an unknown person: error = nil, want 404")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	people.GetFunc = func(ctx context.Context, id string) (*api.People, error) {
+		return &api.People{Id: id, Status: "inactive"}, nil
+	}
+	if _, err := waitActive(ctx, people, []string{"alice"}, time.Hour); err != context.Canceled {
+		t.Errorf("waitActive() when cancelled: error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestPeopleStatus(t *testing.T) {
+	server := sparktest.NewServer()
+	defer server.Close()
+	server.SetStatus("alice@example.com", "OutOfOffice")
+	server.SetStatus("bob@example.com", "active")
+
+	out, err := runApp(t, server, "-j=false", "people", "status", "alice@example.com", "bob@example.com")
+	if err != nil {
+		t.Fatalf("people status: error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "OutOfOffice") || !strings.Contains(lines[1], "active") {
+		t.Errorf("people status printed %q, want alice out of office and bob active", out)
+	}
+
+	out, err = runApp(t, server, "-j=false", "people", "status", "--wait-active",
+		"--exec", "echo available: $"+personEmailEnv, "alice@example.com", "bob@example.com")
+	if err != nil || !strings.HasSuffix(out, "available: bob@example.com\n") {
+		t.Errorf("people status --wait-active --exec = %q, %v, want the command run for bob", out, err)
+	}
+
+	if _, err := runApp(t, server, "people", "status", "nobody@example.com"); err == nil {
+		t.Errorf("people status of an unknown email: error = nil, want not found")
+	}
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/peterh/liner"
+	"github.com/tdeckers/sparkcli/api"
+	"github.com/tdeckers/sparkcli/util"
+)
+
+// historyFile is the name of the shell history file in the home directory.
+const historyFile = ".sparkcli_history"
+
+// shellBuiltins are the commands handled by the shell itself rather than
+// passed on to the regular command tree.
+var shellBuiltins = []string{"use", "say", "refresh", "help", "exit", "quit"}
+
+// commandAborted is raised (through fatal) when a command fails while running
+// inside the shell.
+type commandAborted struct {
+	msg string
+}
+
+// abortCommand replaces fatal while the shell runs, so a failing command
+// returns to the prompt instead of exiting.  A request held back by --dry-run
+// aborts the command without an error.
+func abortCommand(v ...interface{}) {
+	if len(v) == 1 {
+		if err, ok := v[0].(error); ok && errors.Is(err, util.ErrDryRun) {
+			panic(commandAborted{})
+		}
+	}
+	panic(commandAborted{fmt.Sprint(v...)})
+}
+
+// Shell is an interactive session (REPL) on top of the regular commands.  All
+// commands in a session share a single client, so configuration is read and
+// authentication is tested only once.
+type Shell struct {
+	app    *cli.App
+	config *util.Configuration
+	spark  *api.Spark
+	line   *liner.State
+
+	me     *api.People
+	room   *api.Room // current room, selected with 'use'
+	rooms  []api.Room
+	emails []string
+}
+
+// newShell creates a Shell that dispatches commands to app.
+func newShell(app *cli.App, config *util.Configuration, spark *api.Spark) *Shell {
+	return &Shell{app: app, config: config, spark: spark}
+}
+
+// Run reads and executes commands until the user exits or input ends.  Every
+// command gets a context of its own, so Ctrl-C only interrupts the running
+// command.
+func (s *Shell) Run(ctx context.Context) error {
+	peopleService := s.spark.People
+	me, err := peopleService.GetMe(ctx)
+	if err != nil {
+		return err
+	}
+	s.me = me
+
+	// Make failing commands return to the prompt instead of exiting.
+	exit := fatal
+	fatal = abortCommand
+	defer func() { fatal = exit }()
+
+	s.refresh(ctx)
+	if s.config.DefaultRoomId != "" {
+		s.exec([]string{"use", s.config.DefaultRoomId})
+	}
+
+	s.line = liner.NewLiner()
+	defer s.line.Close()
+	s.line.SetCtrlCAborts(true)
+	s.line.SetWordCompleter(s.complete)
+
+	history := historyPath()
+	if f, err := os.Open(history); err == nil {
+		s.line.ReadHistory(f)
+		f.Close()
+	}
+	defer func() {
+		if f, err := os.Create(history); err == nil {
+			s.line.WriteHistory(f)
+			f.Close()
+		}
+	}()
+
+	fmt.Printf("Logged in as %s. Type 'help' for commands, 'exit' to leave.\n", s.me.DisplayName)
+	for {
+		input, err := s.line.Prompt(s.prompt())
+		if err == liner.ErrPromptAborted {
+			continue
+		} else if err == io.EOF {
+			fmt.Println()
+			return nil
+		} else if err != nil {
+			return err
+		}
+		args, err := splitArgs(input)
+		if err != nil {
+			slog.Error(err.Error())
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+		s.line.AppendHistory(input)
+		if args[0] == "exit" || args[0] == "quit" {
+			return nil
+		}
+		s.exec(args)
+	}
+}
+
+// exec runs a single command line.
+func (s *Shell) exec(args []string) {
+	ctx, stop := withInterrupt(context.Background())
+	defer stop()
+	defer func() {
+		if r := recover(); r != nil {
+			aborted, ok := r.(commandAborted)
+			if !ok {
+				panic(r)
+			}
+			if aborted.msg != "" {
+				slog.Error(aborted.msg)
+			}
+		}
+	}()
+
+	switch args[0] {
+	case "use":
+		if len(args) == 1 {
+			if s.room == nil {
+				fmt.Println("No current room.")
+			} else {
+				fmt.Printf("%s: %s\n", s.room.Id, s.room.Title)
+			}
+			return
+		}
+		s.use(ctx, strings.Join(args[1:], " "))
+	case "say":
+		if len(args) == 1 {
+			fatal("Usage: say <msg>")
+		}
+		if s.room == nil {
+			fatal("No current room, select one with 'use <room>'.")
+		}
+		msgService := s.spark.Messages
+		_, err := msgService.Create(ctx, s.room.Id, strings.Join(args[1:], " "))
+		if err != nil {
+			fatal(err)
+		}
+	case "refresh":
+		s.refresh(ctx)
+	case "help", "?":
+		s.help()
+	case "shell":
+		fatal("Already in a shell.")
+	default:
+		if s.app.Command(args[0]) == nil {
+			fatal("Unknown command: " + args[0])
+		}
+		if err := s.app.Run(append([]string{s.app.Name}, args...)); err != nil {
+			fatal(err)
+		}
+		fmt.Println()
+	}
+}
+
+// use makes the room matching key (an id or a title) the current room.
+func (s *Shell) use(ctx context.Context, key string) {
+	var match *api.Room
+	for i, room := range s.rooms {
+		if room.Id == key || strings.EqualFold(room.Title, key) {
+			match = &s.rooms[i]
+			break
+		}
+	}
+	if match == nil {
+		fatal("No room found matching '" + key + "'")
+	}
+	s.room = match
+	currentRoomId = match.Id
+
+	// Collect the emails of the room members for completion.
+	memberService := s.spark.Memberships
+	mss, err := memberService.List(ctx, match.Id, "", "")
+	if err != nil {
+		slog.Warn("Failed to list members", "err", err)
+		return
+	}
+	s.emails = s.emails[:0]
+	for _, ms := range *mss {
+		s.emails = append(s.emails, ms.PersonEmail)
+	}
+	sort.Strings(s.emails)
+}
+
+// refresh reloads the rooms used for completion.
+func (s *Shell) refresh(ctx context.Context) {
+	roomService := s.spark.Rooms
+	rooms, err := roomService.List(ctx)
+	if err != nil {
+		slog.Warn("Failed to list rooms", "err", err)
+		return
+	}
+	s.rooms = *rooms
+	util.SaveCache(accountCache(s.config, roomsCache), s.rooms)
+}
+
+// prompt returns the prompt, showing the current room if there is one.
+func (s *Shell) prompt() string {
+	if s.room == nil {
+		return "sparkcli> "
+	}
+	return fmt.Sprintf("sparkcli [%s]> ", s.room.Title)
+}
+
+// help prints the shell builtins followed by the regular commands.
+func (s *Shell) help() {
+	fmt.Println("Shell commands:")
+	fmt.Println("   use [<room>]   show or select the current room (id or title)")
+	fmt.Println("   say <msg>      post a message to the current room")
+	fmt.Println("   refresh        reload rooms for completion")
+	fmt.Println("   exit, quit     leave the shell")
+	fmt.Println()
+	fmt.Println("Commands (use '-' or leave out the room for the current room):")
+	for _, command := range s.app.Commands {
+		if command.Name == "shell" {
+			continue
+		}
+		fmt.Printf("   %-14s %s\n", command.Name, command.Usage)
+	}
+}
+
+// complete implements liner.WordCompleter.  It completes commands and their
+// subcommands, room titles after 'use' and emails after an email flag.
+func (s *Shell) complete(line string, pos int) (string, []string, string) {
+	head, tail := line[:pos], line[pos:]
+	start := strings.LastIndex(head, " ") + 1
+	word := head[start:]
+	words := strings.Fields(head[:start])
+
+	var candidates []string
+	switch {
+	case len(words) == 0:
+		candidates = append(candidates, shellBuiltins...)
+		for _, command := range s.app.Commands {
+			candidates = append(candidates, command.Name)
+		}
+	case words[0] == "use":
+		// Titles can contain spaces, so complete everything after 'use'.
+		start = strings.Index(head, "use") + len("use")
+		for start < len(head) && head[start] == ' ' {
+			start++
+		}
+		word = head[start:]
+		for _, room := range s.rooms {
+			candidates = append(candidates, room.Title)
+		}
+	case isEmailFlag(words[len(words)-1]):
+		candidates = s.emails
+	default:
+		commands := s.app.Commands
+		for _, w := range words {
+			command := findCommand(commands, w)
+			if command == nil {
+				commands = nil
+				break
+			}
+			commands = command.Subcommands
+		}
+		for _, command := range commands {
+			candidates = append(candidates, command.Name)
+		}
+	}
+
+	var completions []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(strings.ToLower(candidate), strings.ToLower(word)) {
+			completions = append(completions, candidate)
+		}
+	}
+	return head[:start], completions, tail
+}
+
+// findCommand returns the command in commands with name (or alias) name.
+func findCommand(commands []cli.Command, name string) *cli.Command {
+	for i, command := range commands {
+		if command.HasName(name) {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// isEmailFlag reports whether arg is a flag that takes an email address.
+func isEmailFlag(arg string) bool {
+	switch strings.TrimLeft(arg, "-") {
+	case "e", "email":
+		return strings.HasPrefix(arg, "-")
+	}
+	return false
+}
+
+// historyPath returns the location of the shell history file.
+func historyPath() string {
+	return filepath.Join(util.HomeDir(), historyFile)
+}
+
+// splitArgs splits a command line into arguments.  Arguments are separated by
+// whitespace, unless quoted with single or double quotes.  A backslash escapes
+// the next character outside single quotes.
+func splitArgs(line string) ([]string, error) {
+	var args []string
+	var current []rune
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			current = append(current, r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current = append(current, r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, string(current))
+				current = current[:0]
+				inArg = false
+			}
+		default:
+			current = append(current, r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if escaped {
+		return nil, errors.New("trailing backslash")
+	}
+	if inArg {
+		args = append(args, string(current))
+	}
+	return args, nil
+}
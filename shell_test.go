@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tdeckers/sparkcli/sparktest"
+	"github.com/tdeckers/sparkcli/util"
+)
+
+func Test_splitArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    []string
+		wantErr bool
+	}{
+		{"empty", "  ", nil, false},
+		{"words", "m c text - hello", []string{"m", "c", "text", "-", "hello"}, false},
+		{"double quotes", `use "Team room"`, []string{"use", "Team room"}, false},
+		{"single quotes", `say 'it\'s'`, nil, true},
+		{"escaped space", `say a\ b`, []string{"say", "a b"}, false},
+		{"empty quotes", `say ""`, []string{"say", ""}, false},
+		{"unterminated", `say "oops`, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := splitArgs(tt.line)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. splitArgs() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. splitArgs() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestShellDryRun(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	server := sparktest.NewServer()
+	defer server.Close()
+	client, err := util.NewClient(server.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var held bytes.Buffer
+	client.SetDryRun(&held)
+	app := newApp(server.Config(), client)
+	exit := fatal
+	fatal = abortCommand
+	defer func() { fatal = exit }()
+
+	var logs bytes.Buffer
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	newShell(app, server.Config(), nil).exec([]string{"rooms", "create", "ops"})
+	if !strings.HasPrefix(held.String(), "POST ") || len(server.Rooms()) != 0 {
+		t.Errorf("rooms create in dry run printed %q, rooms %+v, want only the request", held.String(), server.Rooms())
+	}
+	if strings.Contains(logs.String(), "ERROR") {
+		t.Errorf("rooms create in dry run logged %q, want no error", logs.String())
+	}
+}
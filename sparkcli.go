@@ -2,22 +2,87 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/codegangsta/cli"
 	"github.com/tdeckers/sparkcli/api"
 	"github.com/tdeckers/sparkcli/util"
-	"log" // TODO: change to https://github.com/Sirupsen/logrus
+	"log/slog"
 	"os"
+	"sort"
 	"strings"
+	"time"
 )
 
+// fatal reports a failed command.  It ends the program, except inside the
+// interactive shell where only the running command is aborted.  A request held
+// back by --dry-run doesn't count as a failure.
+var fatal = func(v ...interface{}) {
+	if len(v) == 1 {
+		if err, ok := v[0].(error); ok && errors.Is(err, util.ErrDryRun) {
+			os.Exit(0)
+		}
+	}
+	slog.Error(fmt.Sprint(v...))
+	os.Exit(1)
+}
+
+// currentRoomId is the room selected with 'use' in the interactive shell.
+var currentRoomId string
+
+// defaultRoomId returns the room to use when none is given on the command
+// line: the shell's current room if set, or the DefaultRoomId from config.
+func defaultRoomId(config *util.Configuration) string {
+	if currentRoomId != "" {
+		return currentRoomId
+	}
+	return config.DefaultRoomId
+}
+
+// printRoom prints the details of a room as text.
+func printRoom(room *api.Room) {
+	fmt.Printf("Id:            %s\n", room.Id)
+	fmt.Printf("Title:         %s\n", room.Title)
+	fmt.Printf("Type:          %s\n", room.Type)
+	if room.Description != "" {
+		fmt.Printf("Description:   %s\n", room.Description)
+	}
+	if room.TeamId != "" {
+		fmt.Printf("TeamId:        %s\n", room.TeamId)
+	}
+	fmt.Printf("Locked:        %v\n", room.IsLocked)
+	fmt.Printf("Public:        %v\n", room.IsPublic)
+	fmt.Printf("CreatorId:     %s\n", room.CreatorId)
+	fmt.Printf("Created:       %s\n", room.Created)
+	fmt.Printf("Last Activity: %s\n", room.LastActivity)
+}
+
 //
 func main() {
-	var jsonFlag bool
-
+	util.SetupLogging(os.Stderr, slog.LevelInfo, false)
 	config := util.GetConfiguration()
 	config.Load()
-	client := util.NewClient(config)
+	client, err := util.NewClient(config)
+	if err != nil {
+		fatal(err)
+	}
+	newApp(config, client).Run(os.Args)
+}
+
+// newApp creates the command tree, with commands working on the service
+// configured in config through client.
+func newApp(config *util.Configuration, client *util.Client) *cli.App {
+	spark := api.NewSpark(client)
+	var jsonFlag bool
+	var dryRun bool
+	var verbose, debug, quiet, logJson bool
+	// ctx is the context of the running command, cancelled on Ctrl-C.  The
+	// shell runs commands within its own command, so contexts are stacked.
+	ctx := context.Background()
+	var contexts []context.Context
+	var stops []func()
+
 	app := cli.NewApp()
 	app.Name = "sparkcli"
 	app.Usage = "Command Line Interface for Cisco Spark"
@@ -28,6 +93,128 @@ func main() {
 			Usage:       "return results as json",
 			Destination: &jsonFlag,
 		},
+		cli.BoolFlag{
+			Name:        "dry-run",
+			Usage:       "print the requests that would change something instead of sending them",
+			Destination: &dryRun,
+		},
+		cli.BoolFlag{
+			Name:        "verbose",
+			Usage:       "log a line per request",
+			Destination: &verbose,
+		},
+		cli.BoolFlag{
+			Name:        "debug",
+			Usage:       "log requests and responses in full (secrets are redacted)",
+			Destination: &debug,
+		},
+		cli.BoolFlag{
+			Name:        "quiet, q",
+			Usage:       "only log warnings and errors",
+			Destination: &quiet,
+		},
+		cli.BoolFlag{
+			Name:        "log-json",
+			Usage:       "log as json, one object per line",
+			Destination: &logJson,
+		},
+	}
+	app.Before = func(c *cli.Context) error {
+		// Only switched on: a shell started with --dry-run stays in dry-run.
+		if dryRun {
+			client.SetDryRun(os.Stdout)
+		}
+		if verbose || debug || quiet || logJson {
+			level := slog.LevelInfo
+			switch {
+			case debug:
+				level = util.LevelTrace
+			case verbose:
+				level = slog.LevelDebug
+			case quiet:
+				level = slog.LevelWarn
+			}
+			util.SetupLogging(os.Stderr, level, logJson)
+		}
+		contexts = append(contexts, ctx)
+		var stop func()
+		ctx, stop = withInterrupt(context.Background())
+		stops = append(stops, stop)
+		return nil
+	}
+	app.After = func(c *cli.Context) error {
+		last := len(stops) - 1
+		stops[last]()
+		ctx, contexts, stops = contexts[last], contexts[:last], stops[:last]
+		return nil
+	}
+	// createTextFlags and createText make up 'messages create text', which is
+	// also what 'messages create' does without a subcommand.
+	createTextFlags := []cli.Flag{
+		cli.BoolFlag{
+			Name:  "stdin",
+			Usage: "read the message from stdin (same as - for <msg>)",
+		},
+		cli.StringFlag{
+			Name:  "file, f",
+			Usage: "read the message as markdown from a file (@path)",
+		},
+		cli.BoolFlag{
+			Name:  "markdown, m",
+			Usage: "send the message as markdown",
+		},
+		cli.BoolFlag{
+			Name:  "split, s",
+			Usage: "send large messages as several messages",
+		},
+	}
+	createText := func(c *cli.Context) {
+		if c.NArg() < 1 {
+			fatal("Usage: sparkcli messages create text <room> <msg>")
+		}
+		id := c.Args().Get(0)
+		if id == "-" {
+			id = defaultRoomId(config)
+			if id == "" {
+				slog.Error("No default room configured.")
+				fatal("Usage: sparkcli messages list <roomId>")
+			}
+		}
+		msgTxt, markdown, err := readMessage(c)
+		if err != nil {
+			fatal(err)
+		}
+		parts := []string{msgTxt}
+		if len(msgTxt) > api.MaxMessageSize {
+			if !c.Bool("split") {
+				fatal(fmt.Sprintf("Message is larger than %d bytes, use --split to send it as several messages.", api.MaxMessageSize))
+			}
+			parts = util.SplitMessage(msgTxt, api.MaxMessageSize)
+		}
+		msgService := spark.Messages
+		for i, part := range parts {
+			var msg *api.Message
+			if markdown {
+				msg, err = msgService.CreateMarkdown(ctx, id, part)
+			} else {
+				msg, err = msgService.Create(ctx, id, part)
+			}
+			if errors.Is(err, util.ErrDryRun) {
+				continue // show the requests for the other parts too
+			}
+			if err != nil {
+				fatal(err)
+			} else {
+				if jsonFlag {
+					util.PrintJson(msg)
+				} else {
+					if i > 0 {
+						fmt.Println()
+					}
+					fmt.Print(msg.Id)
+				}
+			}
+		}
 	}
 	app.Commands = []cli.Command{
 		{
@@ -35,9 +222,9 @@ func main() {
 			Aliases: []string{"l"},
 			Usage:   "login to Cisco Spark",
 			Action: func(c *cli.Context) {
-				log.Println("Logging in")
+				slog.Info("Logging in")
 				login := util.NewLogin(config, client)
-				login.Authorize()
+				login.Authorize(ctx)
 			},
 		},
 		{
@@ -49,12 +236,30 @@ func main() {
 					Name:    "list",
 					Aliases: []string{"l"},
 					Usage:   "list all rooms",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "team, t",
+							Usage: "only rooms of the team with this id",
+						},
+						cli.StringFlag{
+							Name:  "type",
+							Usage: "only direct or group rooms",
+						},
+						cli.StringFlag{
+							Name:  "sort, s",
+							Usage: "sort by id, lastactivity or created (newest first)",
+						},
+					},
 					Action: func(c *cli.Context) {
-						roomService := api.RoomService{Client: client}
-						rooms, err := roomService.List()
+						filter := api.RoomFilter{TeamId: c.String("team"), Type: c.String("type"), SortBy: c.String("sort")}
+						roomService := spark.Rooms
+						rooms, err := roomService.ListAll(ctx, filter)
 						if err != nil {
-							log.Fatalln(err)
+							fatal(err)
 						} else {
+							if filter == (api.RoomFilter{}) {
+								util.SaveCache(accountCache(config, roomsCache), rooms)
+							}
 							if jsonFlag {
 								util.PrintJson(rooms)
 							} else {
@@ -71,15 +276,21 @@ func main() {
 					Name:    "create",
 					Aliases: []string{"c"},
 					Usage:   "create a new room",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "team, t",
+							Usage: "create the room in the team with this id",
+						},
+					},
 					Action: func(c *cli.Context) {
 						if c.NArg() != 1 {
-							log.Fatal("Usage: sparkcli rooms create <name>")
+							fatal("Usage: sparkcli rooms create [--team <teamId>] <name>")
 						}
 						name := c.Args().Get(0)
-						roomService := api.RoomService{Client: client}
-						room, err := roomService.Create(name)
+						roomService := spark.Rooms
+						room, err := roomService.CreateInTeam(ctx, name, c.String("team"))
 						if err != nil {
-							log.Fatalln(err)
+							fatal(err)
 						} else {
 							if jsonFlag {
 								util.PrintJson(room)
@@ -96,45 +307,106 @@ func main() {
 					Usage:   "get room details",
 					Action: func(c *cli.Context) {
 						if c.NArg() > 1 {
-							log.Fatal("Usage: sparkcli rooms get <id>")
+							fatal("Usage: sparkcli rooms get <id>")
 						}
 						id := c.Args().Get(0)
 						if id == "" { // try default room
-							id = config.DefaultRoomId
+							id = defaultRoomId(config)
 							if id == "" {
-								log.Fatal("Usage: sparkcli rooms get <id> (no default room configured)")
+								fatal("Usage: sparkcli rooms get <id> (no default room configured)")
 							}
 						}
-						roomService := api.RoomService{Client: client}
-						room, err := roomService.Get(id)
+						roomService := spark.Rooms
+						room, err := roomService.Get(ctx, id)
 						if err != nil {
-							log.Fatalln(err)
+							fatal(err)
 						} else {
 							if jsonFlag {
 								util.PrintJson(room)
 							} else {
-								fmt.Printf("Id:          %s\n", room.Id)
-								fmt.Printf("Title:       %s\n", room.Title)
-								fmt.Printf("Sip Address: %s\n", room.SipAddress)
-								fmt.Printf("Created:     %s\n", room.Created)
+								printRoom(room)
+								fmt.Printf("Sip Address:   %s\n", room.SipAddress)
+							}
+						}
+					},
+				},
+				{
+					Name:    "update",
+					Aliases: []string{"u"},
+					Usage:   "rename, lock or unlock a room",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "title, t",
+							Usage: "new title",
+						},
+						cli.BoolFlag{
+							Name:  "lock",
+							Usage: "only let moderators post",
+						},
+						cli.BoolFlag{
+							Name:  "unlock",
+							Usage: "let everyone post",
+						},
+					},
+					Action: func(c *cli.Context) {
+						if c.NArg() != 1 || (c.Bool("lock") && c.Bool("unlock")) {
+							fatal("Usage: sparkcli rooms update [--title <title>] [--lock|--unlock] <id>")
+						}
+						update := api.RoomUpdate{Title: c.String("title")}
+						if c.Bool("lock") || c.Bool("unlock") {
+							locked := c.Bool("lock")
+							update.IsLocked = &locked
+						}
+						if update.Title == "" && update.IsLocked == nil {
+							fatal("Nothing to update, use --title, --lock or --unlock.")
+						}
+						id := c.Args().Get(0)
+						if id == "-" {
+							id = defaultRoomId(config)
+							if id == "" {
+								fatal("Usage: sparkcli rooms update <id> (no default room configured)")
 							}
 						}
+						room, err := spark.Rooms.Update(ctx, id, update)
+						if err != nil {
+							fatal(err)
+						}
+						if jsonFlag {
+							util.PrintJson(room)
+						} else {
+							printRoom(room)
+						}
 					},
 				},
 				{
 					Name:    "delete",
 					Aliases: []string{"d"},
 					Usage:   "delete a room",
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "yes, y",
+							Usage: "don't ask for confirmation",
+						},
+					},
 					Action: func(c *cli.Context) {
 						if c.NArg() != 1 {
-							log.Fatal("Usage: sparkcli rooms delete <id>")
+							fatal("Usage: sparkcli rooms delete [--yes] <id>")
 						}
 						id := c.Args().Get(0)
-						roomService := api.RoomService{Client: client}
-						err := roomService.Delete(id)
+						roomService := spark.Rooms
+						if !c.Bool("yes") && !dryRun {
+							what := "room " + id
+							if room, err := roomService.Get(ctx, id); err == nil {
+								what = "room '" + room.Title + "'"
+							}
+							if !confirm(os.Stdin, os.Stderr, "Delete "+what+"?") {
+								fatal("Not deleted, use --yes to delete without confirmation.")
+							}
+						}
+						err := roomService.Delete(ctx, id)
 						//TODO: if error is '400 Bad Request', try deleting by name?
 						if err != nil {
-							log.Fatalln(err)
+							fatal(err)
 						} else {
 							if !jsonFlag {
 								fmt.Println("Room deleted.")
@@ -144,12 +416,102 @@ func main() {
 					},
 				},
 				// Convenience actions (not available in Cisco Spark API)
+				{
+					Name:  "export",
+					Usage: "export the messages of a room",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "format, f",
+							Value: "json",
+							Usage: "export format: json, html or md",
+						},
+						cli.StringFlag{
+							Name:  "output, o",
+							Usage: "file to export to (default: named after the room)",
+						},
+						cli.StringFlag{
+							Name:  "since, s",
+							Usage: "only export messages since date (2006-01-02 or RFC 3339)",
+						},
+						cli.BoolFlag{
+							Name:  "attachments, a",
+							Usage: "download attachments next to the export",
+						},
+					},
+					Action: func(c *cli.Context) {
+						if c.NArg() > 1 {
+							fatal("Usage: sparkcli rooms export [-f json|html|md] [-o <file>] <id>")
+						}
+						id := c.Args().Get(0)
+						if id == "" || id == "-" {
+							id = defaultRoomId(config)
+							if id == "" {
+								fatal("Usage: sparkcli rooms export <id> (no default room configured)")
+							}
+						}
+						since, err := parseSince(c.String("since"))
+						if err != nil {
+							fatal(err)
+						}
+						exporter, err := newExporter(spark, c.String("format"), c.String("output"), since, c.Bool("attachments"))
+						if err != nil {
+							fatal(err)
+						}
+						count, err := exporter.Export(ctx, id)
+						if err != nil {
+							fatal(err)
+						}
+						if !jsonFlag {
+							fmt.Printf("Exported %d new messages to %s\n", count, exporter.output)
+						}
+					},
+				},
+				{
+					Name:  "import",
+					Usage: "post the messages of an exported room to a room",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "into, i",
+							Usage: "room to post the messages to",
+						},
+						cli.IntFlag{
+							Name:  "from",
+							Value: 1,
+							Usage: "number of the first message to post (to continue an import)",
+						},
+						cli.DurationFlag{
+							Name:  "delay",
+							Value: time.Second,
+							Usage: "pause between messages",
+						},
+					},
+					Action: func(c *cli.Context) {
+						if c.NArg() != 1 {
+							fatal("Usage: sparkcli rooms import --into <id> <archive.json>")
+						}
+						id := c.String("into")
+						if id == "" || id == "-" {
+							id = defaultRoomId(config)
+							if id == "" {
+								fatal("Usage: sparkcli rooms import --into <id> <archive.json> (no default room configured)")
+							}
+						}
+						importer := newImporter(spark, id, c.Duration("delay"))
+						count, err := importer.Import(ctx, c.Args().Get(0), c.Int("from"))
+						if err != nil {
+							fatal(err)
+						}
+						if !jsonFlag {
+							fmt.Printf("Imported %d messages.\n", count)
+						}
+					},
+				},
 				{
 					Name:  "default",
 					Usage: "save default room in config",
 					Action: func(c *cli.Context) {
 						if c.NArg() > 1 {
-							log.Fatal("Usage: sparkcli rooms default (<id>)")
+							fatal("Usage: sparkcli rooms default (<id>)")
 						}
 						if c.NArg() == 1 {
 							id := c.Args().Get(0)
@@ -172,24 +534,30 @@ func main() {
 					Name:    "list",
 					Aliases: []string{"l"},
 					Usage:   "list all messages",
+					Flags: []cli.Flag{
+						cli.IntFlag{
+							Name:  "max, n",
+							Usage: "maximum number of messages to list",
+						},
+					},
 					Action: func(c *cli.Context) {
-						// TODO: add limiters (num, before, beforeMessage)
+						// TODO: add limiters (before, beforeMessage)
 						// If no arg provided, also use default room.
 						if c.NArg() > 1 {
-							log.Fatal("Usage: sparkcli messages list <roomid>")
+							fatal("Usage: sparkcli messages list <roomid>")
 						}
 						id := c.Args().Get(0)
 						if id == "" {
-							id = config.DefaultRoomId
+							id = defaultRoomId(config)
 							if id == "" {
-								log.Println("No default room configured.")
-								log.Fatal("Usage: sparkcli messages list <roomId>")
+								slog.Error("No default room configured.")
+								fatal("Usage: sparkcli messages list <roomId>")
 							}
 						}
-						msgService := api.MessageService{Client: client}
-						msgs, err := msgService.List(id)
+						msgService := spark.Messages
+						msgs, err := msgService.List(ctx, id, c.Int("max"))
 						if err != nil {
-							log.Fatalln(err)
+							fatal(err)
 						} else {
 							if jsonFlag {
 								util.PrintJson(msgs)
@@ -202,31 +570,85 @@ func main() {
 					},
 				},
 				{
-					Name:    "create",
-					Aliases: []string{"c"},
-					Usage:   "create a new message",
+					Name:    "tail",
+					Aliases: []string{"t"},
+					Usage:   "show the latest messages, and optionally follow new ones",
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "follow, f",
+							Usage: "keep polling for new messages",
+						},
+						cli.IntFlag{
+							Name:  "lines, n",
+							Value: 10,
+							Usage: "number of messages to show on start",
+						},
+					},
+					Action: func(c *cli.Context) {
+						if c.NArg() > 1 {
+							fatal("Usage: sparkcli messages tail [-f] [-n <num>] <roomId>")
+						}
+						id := c.Args().Get(0)
+						if id == "" || id == "-" {
+							id = defaultRoomId(config)
+							if id == "" {
+								slog.Error("No default room configured.")
+								fatal("Usage: sparkcli messages tail [-f] [-n <num>] <roomId>")
+							}
+						}
+						msgService := spark.Messages
+						t := newTail(msgService, id, jsonFlag)
+						if err := t.Run(ctx, c.Int("lines"), c.Bool("follow")); err != nil {
+							fatal(err)
+						}
+					},
+				},
+				{
+					Name:      "create",
+					Aliases:   []string{"c"},
+					Usage:     "create a new message, a text message without subcommand",
+					ArgsUsage: "<room> <msg>",
+					Flags:     createTextFlags,
+					Action:    createText,
 					Subcommands: []cli.Command{
 						{
-							Name:  "text",
-							Usage: "create a new text message",
+							Name:   "text",
+							Usage:  "create a new text message",
+							Flags:  createTextFlags,
+							Action: createText,
+						},
+						{
+							Name:  "file",
+							Usage: "send attachments",
+							Flags: []cli.Flag{
+								cli.StringFlag{
+									Name:  "text, t",
+									Usage: "text to send with the files",
+								},
+								cli.StringFlag{
+									Name:  "markdown, m",
+									Usage: "markdown to send with the files",
+								},
+							},
 							Action: func(c *cli.Context) {
-								// TODO: change this to take all args after the second as additional text.
-								if c.NArg() < 1 {
-									log.Fatal("Usage: sparkcli messages create text <room> <msg>")
+								if c.NArg() < 2 {
+									fatal("Usage: sparkcli messages create file <room> <file>...")
 								}
 								id := c.Args().Get(0)
 								if id == "-" {
-									id = config.DefaultRoomId
+									id = defaultRoomId(config)
 									if id == "" {
-										log.Println("No default room configured.")
-										log.Fatal("Usage: sparkcli messages list <roomId>")
+										slog.Error("No default room configured.")
+										fatal("Usage: sparkcli messages list <roomId>")
 									}
 								}
-								msgTxt := strings.Join(c.Args().Tail(), " ")
-								msgService := api.MessageService{Client: client}
-								msg, err := msgService.Create(id, msgTxt)
+								files := c.Args().Tail()
+								progress := uploadProgress(files)
+								msgService := spark.Messages
+								msg, err := msgService.CreateFiles(ctx, id, c.String("text"), c.String("markdown"), files, progress)
+								progress.Finish()
 								if err != nil {
-									log.Fatalln(err)
+									fatal(err)
 								} else {
 									if jsonFlag {
 										util.PrintJson(msg)
@@ -237,31 +659,45 @@ func main() {
 							},
 						},
 						{
-							Name:  "file",
-							Usage: "send a attachment",
+							Name:  "card",
+							Usage: "send an Adaptive Card, e.g. with buttons or a form",
+							Flags: []cli.Flag{
+								cli.StringFlag{
+									Name:  "file, f",
+									Usage: "card json (- for stdin)",
+								},
+								cli.StringFlag{
+									Name:  "text, t",
+									Usage: "text for clients that can't show cards (default the card's fallbackText)",
+								},
+							},
 							Action: func(c *cli.Context) {
-								if c.NArg() < 1 {
-									log.Fatal("Usage: sparkcli messages create file <room> <file>")
+								if c.NArg() != 1 || c.String("file") == "" {
+									fatal("Usage: sparkcli messages create card --file <card.json> <room>")
 								}
 								id := c.Args().Get(0)
 								if id == "-" {
-									id = config.DefaultRoomId
+									id = defaultRoomId(config)
 									if id == "" {
-										log.Println("No default room configured.")
-										log.Fatal("Usage: sparkcli messages list <roomId>")
+										slog.Error("No default room configured.")
+										fatal("Usage: sparkcli messages create card --file <card.json> <room>")
 									}
 								}
-								filePath := strings.Join(c.Args().Tail(), " ")
-								msgService := api.MessageService{Client: client}
-								msg, err := msgService.CreateFile(id, filePath)
+								card, fallback, err := readCard(c.String("file"))
 								if err != nil {
-									log.Fatalln(err)
+									fatal(err)
+								}
+								if c.String("text") != "" {
+									fallback = c.String("text")
+								}
+								msg, err := spark.Messages.CreateCard(ctx, id, fallback, card)
+								if err != nil {
+									fatal(err)
+								}
+								if jsonFlag {
+									util.PrintJson(msg)
 								} else {
-									if jsonFlag {
-										util.PrintJson(msg)
-									} else {
-										fmt.Print(msg.Id)
-									}
+									fmt.Print(msg.Id)
 								}
 							},
 						},
@@ -273,13 +709,13 @@ func main() {
 					Usage:   "get message details",
 					Action: func(c *cli.Context) {
 						if c.NArg() != 1 {
-							log.Fatal("Usage: sparkcli messages get <id>")
+							fatal("Usage: sparkcli messages get <id>")
 						}
 						id := c.Args().Get(0)
-						msgService := api.MessageService{Client: client}
-						msg, err := msgService.Get(id)
+						msgService := spark.Messages
+						msg, err := msgService.Get(ctx, id)
 						if err != nil {
-							log.Fatalln(err)
+							fatal(err)
 						} else {
 							if jsonFlag {
 								util.PrintJson(msg)
@@ -291,24 +727,123 @@ func main() {
 								fmt.Printf("Text:          %s\n", msg.Text)
 								fmt.Printf("ToPersonId:    %s\n", msg.ToPersonId)
 								fmt.Printf("ToPersonEmail: %s\n", msg.ToPersonEmail)
+								for _, file := range msg.Files {
+									fmt.Printf("File:          %s\n", file)
+								}
+								for _, attachment := range msg.Attachments {
+									fmt.Printf("Attachment:    %s\n", attachment.ContentType)
+								}
 								fmt.Printf("Created:       %s\n", msg.Created)
 							}
 						}
 					},
 				},
+				{
+					Name:    "files",
+					Aliases: []string{"f"},
+					Usage:   "operations on message attachments",
+					Subcommands: []cli.Command{
+						{
+							Name:    "get",
+							Aliases: []string{"g"},
+							Usage:   "get details of the attachments (without downloading)",
+							Action: func(c *cli.Context) {
+								if c.NArg() != 1 {
+									fatal("Usage: sparkcli messages files get <messageId>")
+								}
+								msgService := spark.Messages
+								msg, err := msgService.Get(ctx, c.Args().Get(0))
+								if err != nil {
+									fatal(err)
+								}
+								fileService := spark.Files
+								var infos []api.FileInfo
+								for _, file := range msg.Files {
+									info, err := fileService.Info(ctx, file)
+									if err != nil {
+										fatal(err)
+									}
+									infos = append(infos, *info)
+								}
+								if jsonFlag {
+									util.PrintJson(infos)
+								} else {
+									for _, info := range infos {
+										fmt.Printf("%s:\n", info.Name)
+										fmt.Printf("   Type: %s\n", info.ContentType)
+										fmt.Printf("   Size: %s\n", util.FormatSize(info.Size))
+										fmt.Printf("   Url:  %s\n", info.Url)
+									}
+								}
+							},
+						},
+						{
+							Name:    "download",
+							Aliases: []string{"d"},
+							Usage:   "download the attachments",
+							Flags: []cli.Flag{
+								cli.StringFlag{
+									Name:  "dir, d",
+									Value: ".",
+									Usage: "directory to save the files in",
+								},
+								cli.BoolFlag{
+									Name:  "force, f",
+									Usage: "overwrite existing files",
+								},
+							},
+							Action: func(c *cli.Context) {
+								if c.NArg() != 1 {
+									fatal("Usage: sparkcli messages files download [-d <dir>] <messageId>")
+								}
+								msgService := spark.Messages
+								msg, err := msgService.Get(ctx, c.Args().Get(0))
+								if err != nil {
+									fatal(err)
+								}
+								if len(msg.Files) == 0 {
+									fatal("Message has no attachments.")
+								}
+								fileService := spark.Files
+								for _, file := range msg.Files {
+									path, err := downloadFile(ctx, fileService, file, c.String("dir"), c.Bool("force"))
+									if err != nil {
+										fatal(err)
+									}
+									fmt.Println(path)
+								}
+							},
+						},
+					},
+				},
 				{
 					Name:    "delete",
 					Aliases: []string{"d"},
 					Usage:   "delete a message",
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "yes, y",
+							Usage: "don't ask for confirmation",
+						},
+					},
 					Action: func(c *cli.Context) {
 						if c.NArg() != 1 {
-							log.Fatal("Usage: sparkcli messages delete <id>")
+							fatal("Usage: sparkcli messages delete [--yes] <id>")
 						}
 						id := c.Args().Get(0)
-						msgService := api.MessageService{Client: client}
-						err := msgService.Delete(id)
+						msgService := spark.Messages
+						if !c.Bool("yes") && !dryRun {
+							what := "message " + id
+							if msg, err := msgService.Get(ctx, id); err == nil && msg.Text != "" {
+								what = "message '" + ellipsis(msg.Text, 40) + "'"
+							}
+							if !confirm(os.Stdin, os.Stderr, "Delete "+what+"?") {
+								fatal("Not deleted, use --yes to delete without confirmation.")
+							}
+						}
+						err := msgService.Delete(ctx, id)
 						if err != nil {
-							log.Fatalln(err)
+							fatal(err)
 						} else {
 							if !jsonFlag {
 								fmt.Print("Message deleted.")
@@ -318,6 +853,75 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:      "dm",
+			Usage:     "send a direct message to a person",
+			ArgsUsage: "<email|name> <msg>",
+			Action: func(c *cli.Context) {
+				if c.NArg() < 2 {
+					fatal("Usage: sparkcli dm <email|name> <msg>")
+				}
+				email, err := resolveEmail(ctx, spark.People, c.Args().Get(0))
+				if err != nil {
+					fatal(err)
+				}
+				msg, err := spark.Messages.CreateDirect(ctx, email, strings.Join(c.Args().Tail(), " "))
+				if err != nil {
+					fatal(err)
+				}
+				if jsonFlag {
+					util.PrintJson(msg)
+				} else {
+					fmt.Print(msg.Id)
+				}
+			},
+			Subcommands: []cli.Command{
+				{
+					Name:      "history",
+					Aliases:   []string{"h"},
+					Usage:     "show the conversation with a person, oldest first",
+					ArgsUsage: "<email|name>",
+					Flags: []cli.Flag{
+						cli.IntFlag{
+							Name:  "max, n",
+							Value: 50,
+							Usage: "number of messages to show",
+						},
+					},
+					Action: func(c *cli.Context) {
+						if c.NArg() != 1 {
+							fatal("Usage: sparkcli dm history [-n <num>] <email|name>")
+						}
+						email, err := resolveEmail(ctx, spark.People, c.Args().Get(0))
+						if err != nil {
+							fatal(err)
+						}
+						room, err := findDirectRoom(ctx, spark, email)
+						if err != nil {
+							fatal(err)
+						}
+						if room == nil {
+							fatal("No conversation with " + email + " yet.")
+						}
+						msgs, err := spark.Messages.List(ctx, room.Id, c.Int("max"))
+						if err != nil {
+							fatal(err)
+						}
+						conversation := make([]api.Message, 0, len(*msgs))
+						for i := len(*msgs) - 1; i >= 0; i-- {
+							conversation = append(conversation, (*msgs)[i])
+						}
+						if jsonFlag {
+							util.PrintJson(conversation)
+						} else {
+							for _, msg := range conversation {
+								fmt.Printf("[%v] %v: %v\n", msg.Created, msg.PersonEmail, msg.Text)
+							}
+						}
+					},
+				},
+			},
+		},
 		{
 			Name:    "people",
 			Aliases: []string{"p"},
@@ -332,21 +936,15 @@ func main() {
 						if c.NArg() == 1 { // if argument, use that as id
 							id = c.Args().Get(0)
 						}
-						peopleService := api.PeopleService{Client: client}
-						person, err := peopleService.Get(id)
+						peopleService := spark.People
+						person, err := peopleService.Get(ctx, id)
 						if err != nil {
-							log.Fatalln(err)
+							fatal(err)
 						} else {
 							if jsonFlag {
 								util.PrintJson(person)
 							} else {
-								fmt.Printf("Id:      %s\n", person.Id)
-								fmt.Printf("Name:    %s\n", person.DisplayName)
-								for _, email := range person.Emails {
-									fmt.Printf("Email:   %s\n", email)
-								}
-								fmt.Printf("Avatar:  %s\n", person.Avatar)
-								fmt.Printf("Created: %s\n", person.Created)
+								printPerson(person)
 							}
 						}
 
@@ -365,14 +963,24 @@ func main() {
 							Name:  "name, n",
 							Usage: "name to search for (startWith function)",
 						},
+						cli.StringSliceFlag{
+							Name:  "id, i",
+							Usage: "person id (repeat or separate with commas for more)",
+						},
+						cli.StringFlag{
+							Name:  "org, o",
+							Usage: "everyone in the organization with this id (admins only)",
+						},
 					},
 					Action: func(c *cli.Context) {
-						email := c.String("email")
-						name := c.String("name")
-						peopleService := api.PeopleService{Client: client}
-						people, err := peopleService.List(email, name)
+						filter := api.PeopleFilter{Email: c.String("email"), DisplayName: c.String("name"), OrgId: c.String("org")}
+						for _, ids := range c.StringSlice("id") {
+							filter.Ids = append(filter.Ids, strings.Split(ids, ",")...)
+						}
+						peopleService := spark.People
+						people, err := peopleService.ListAll(ctx, filter)
 						if err != nil {
-							log.Fatalln(err)
+							fatal(err)
 						} else {
 							if jsonFlag {
 								util.PrintJson(people)
@@ -397,53 +1005,203 @@ func main() {
 						}
 					},
 				},
-			},
-		},
-		{
-			Name:    "memberships",
-			Aliases: []string{"ms"},
-			Usage:   "operations on memberships",
-			Subcommands: []cli.Command{
 				{
-					Name:    "list",
-					Aliases: []string{"l"},
-					Usage:   "list memberships",
+					Name:      "status",
+					Aliases:   []string{"s"},
+					Usage:     "show whether people are active, or wait until one of them is",
+					ArgsUsage: "<email>...",
 					Flags: []cli.Flag{
-						cli.StringFlag{
-							Name:  "room, r",
-							Usage: "search by room id",
+						cli.BoolFlag{
+							Name:  "wait-active, w",
+							Usage: "poll until one of the people is active",
 						},
-						cli.StringFlag{
-							Name:  "personid, p",
-							Usage: "filter by person id",
+						cli.DurationFlag{
+							Name:  "interval, i",
+							Value: 30 * time.Second,
+							Usage: "time between polls with --wait-active",
 						},
 						cli.StringFlag{
-							Name:  "email, e",
-							Usage: "filter by email",
+							Name:  "exec, x",
+							Usage: "command to run when someone is active (with $" + personEmailEnv + " set)",
 						},
 					},
 					Action: func(c *cli.Context) {
-						roomId := c.String("room")
-						if roomId == "-" {
-							roomId = config.DefaultRoomId
-							if roomId == "" {
-								log.Println("No default room configured.")
-								log.Fatal("Usage: sparkcli memberships list -r <roomId>")
-							}
+						if c.NArg() < 1 || (c.String("exec") != "" && !c.Bool("wait-active")) {
+							fatal("Usage: sparkcli people status [--wait-active [--exec <command>]] <email>...")
 						}
-						personId := c.String("personid")
-						personEmail := c.String("email")
-						memberService := api.MemberService{Client: client}
-						mss, err := memberService.List(roomId, personId, personEmail)
+						people, err := findPeople(ctx, spark.People, c.Args())
 						if err != nil {
-							log.Fatalln(err)
-						} else {
+							fatal(err)
+						}
+						if !c.Bool("wait-active") {
 							if jsonFlag {
-								util.PrintJson(mss)
+								util.PrintJson(people)
 							} else {
-								for _, ms := range *mss {
-									fmt.Printf("%s:\n", ms.Id)
-									fmt.Printf("   Name: %s\n", ms.PersonDisplayName)
+								for _, person := range people {
+									printStatus(person)
+								}
+							}
+							return
+						}
+						var ids []string
+						for _, person := range people {
+							ids = append(ids, person.Id)
+						}
+						person, err := waitActive(ctx, spark.People, ids, c.Duration("interval"))
+						if err != nil {
+							if ctx.Err() != nil {
+								fatal("Interrupted, no one became active.")
+							}
+							fatal(err)
+						}
+						if jsonFlag {
+							util.PrintJson(person)
+						} else {
+							printStatus(*person)
+						}
+						if command := c.String("exec"); command != "" {
+							if err := runForPerson(command, person); err != nil {
+								fatal(err)
+							}
+						}
+					},
+				},
+				{
+					Name:      "create",
+					Aliases:   []string{"c"},
+					Usage:     "add a person to your organization (admins only)",
+					ArgsUsage: "--email <email> [--name <name>] ...",
+					Flags:     personFlags,
+					Action: func(c *cli.Context) {
+						if c.NArg() != 0 || c.String("email") == "" {
+							fatal("Usage: sparkcli people create --email <email> [--name <name>] ...")
+						}
+						var person api.People
+						if err := setPersonFlags(c, &person); err != nil {
+							fatal(err)
+						}
+						created, err := spark.People.Create(ctx, person)
+						if err != nil {
+							fatal(err)
+						}
+						if jsonFlag {
+							util.PrintJson(created)
+						} else {
+							fmt.Print(created.Id)
+						}
+					},
+				},
+				{
+					Name:      "update",
+					Aliases:   []string{"u"},
+					Usage:     "change the details of a person (admins only)",
+					ArgsUsage: "<id>",
+					Flags:     personFlags,
+					Action: func(c *cli.Context) {
+						if c.NArg() != 1 {
+							fatal("Usage: sparkcli people update [--name <name>] ... <id>")
+						}
+						if !personFlagSet(c) {
+							fatal("Nothing to update, use --email, --name, --role, --license, ...")
+						}
+						id := c.Args().Get(0)
+						person, err := spark.People.Get(ctx, id)
+						if err != nil {
+							fatal(err)
+						}
+						if err := setPersonFlags(c, person); err != nil {
+							fatal(err)
+						}
+						updated, err := spark.People.Update(ctx, id, *person)
+						if err != nil {
+							fatal(err)
+						}
+						if jsonFlag {
+							util.PrintJson(updated)
+						} else {
+							printPerson(updated)
+						}
+					},
+				},
+				{
+					Name:    "delete",
+					Aliases: []string{"d"},
+					Usage:   "remove a person from your organization (admins only)",
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "yes, y",
+							Usage: "don't ask for confirmation",
+						},
+					},
+					Action: func(c *cli.Context) {
+						if c.NArg() != 1 {
+							fatal("Usage: sparkcli people delete [--yes] <id>")
+						}
+						id := c.Args().Get(0)
+						if !c.Bool("yes") && !dryRun {
+							what := "person " + id
+							if person, err := spark.People.Get(ctx, id); err == nil {
+								what = person.DisplayName
+							}
+							if !confirm(os.Stdin, os.Stderr, "Delete "+what+" from your organization?") {
+								fatal("Not deleted, use --yes to delete without confirmation.")
+							}
+						}
+						if err := spark.People.Delete(ctx, id); err != nil {
+							fatal(err)
+						}
+						if !jsonFlag {
+							fmt.Println("Person deleted.")
+						}
+					},
+				},
+			},
+		},
+		{
+			Name:    "memberships",
+			Aliases: []string{"ms"},
+			Usage:   "operations on memberships",
+			Subcommands: []cli.Command{
+				{
+					Name:    "list",
+					Aliases: []string{"l"},
+					Usage:   "list memberships",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "room, r",
+							Usage: "search by room id",
+						},
+						cli.StringFlag{
+							Name:  "personid, p",
+							Usage: "filter by person id",
+						},
+						cli.StringFlag{
+							Name:  "email, e",
+							Usage: "filter by email",
+						},
+					},
+					Action: func(c *cli.Context) {
+						roomId := c.String("room")
+						if roomId == "-" {
+							roomId = defaultRoomId(config)
+							if roomId == "" {
+								slog.Error("No default room configured.")
+								fatal("Usage: sparkcli memberships list -r <roomId>")
+							}
+						}
+						personId := c.String("personid")
+						personEmail := c.String("email")
+						memberService := spark.Memberships
+						mss, err := memberService.List(ctx, roomId, personId, personEmail)
+						if err != nil {
+							fatal(err)
+						} else {
+							if jsonFlag {
+								util.PrintJson(mss)
+							} else {
+								for _, ms := range *mss {
+									fmt.Printf("%s:\n", ms.Id)
+									fmt.Printf("   Name: %s\n", ms.PersonDisplayName)
 									fmt.Printf("   Email: %s\n", ms.PersonEmail)
 									fmt.Printf("   Room: %s\n", ms.RoomId)
 									fmt.Printf("   Created: %s\n", ms.Created)
@@ -469,23 +1227,27 @@ func main() {
 							Name:  "email, e",
 							Usage: "email of person to add",
 						},
+						cli.BoolFlag{
+							Name:  "moderator, m",
+							Usage: "make the person a moderator",
+						},
 					},
 					Action: func(c *cli.Context) {
 						roomId := c.String("room")
 						if roomId == "-" {
-							roomId = config.DefaultRoomId
+							roomId = defaultRoomId(config)
 							if roomId == "" {
-								log.Println("No default room configured.")
-								log.Fatal("Usage: sparkcli memberships create -r <roomId> ...")
+								slog.Error("No default room configured.")
+								fatal("Usage: sparkcli memberships create -r <roomId> ...")
 							}
 						}
 
 						personId := c.String("personid")
 						personEmail := c.String("email")
-						memberService := api.MemberService{Client: client}
-						ms, err := memberService.Create(roomId, personId, personEmail)
+						memberService := spark.Memberships
+						ms, err := memberService.Create(ctx, roomId, personId, personEmail, c.Bool("moderator"))
 						if err != nil {
-							log.Fatalln(err)
+							fatal(err)
 						} else {
 							if jsonFlag {
 								util.PrintJson(ms)
@@ -506,13 +1268,13 @@ func main() {
 					Usage:   "get membership details",
 					Action: func(c *cli.Context) {
 						if c.NArg() != 1 {
-							log.Fatal("Usage: sparkcli memberships get <id>")
+							fatal("Usage: sparkcli memberships get <id>")
 						}
 						id := c.Args().Get(0)
-						msService := api.MemberService{Client: client}
-						ms, err := msService.Get(id)
+						msService := spark.Memberships
+						ms, err := msService.Get(ctx, id)
 						if err != nil {
-							log.Fatalln(err)
+							fatal(err)
 						} else {
 							if jsonFlag {
 								util.PrintJson(ms)
@@ -539,15 +1301,15 @@ func main() {
 					},
 					Action: func(c *cli.Context) {
 						if c.NArg() != 1 {
-							log.Fatal("Usage: sparkcli memberships update -moderator <id>")
+							fatal("Usage: sparkcli memberships update -moderator <id>")
 						}
 						id := c.Args().Get(0)
 						// TODO: avoid doing update if flag is not present.
 						moderator := c.Bool("moderator")
-						msService := api.MemberService{Client: client}
-						ms, err := msService.Update(id, moderator)
+						msService := spark.Memberships
+						ms, err := msService.Update(ctx, id, moderator)
 						if err != nil {
-							log.Fatalln(err)
+							fatal(err)
 						} else {
 							if jsonFlag {
 								util.PrintJson(ms)
@@ -565,15 +1327,30 @@ func main() {
 					Name:    "delete",
 					Aliases: []string{"d"},
 					Usage:   "delete membership",
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "yes, y",
+							Usage: "don't ask for confirmation",
+						},
+					},
 					Action: func(c *cli.Context) {
 						if c.NArg() != 1 {
-							log.Fatal("Usage: sparkcli memberships delete <id>")
+							fatal("Usage: sparkcli memberships delete [--yes] <id>")
 						}
 						id := c.Args().Get(0)
-						msService := api.MemberService{Client: client}
-						err := msService.Delete(id)
+						msService := spark.Memberships
+						if !c.Bool("yes") && !dryRun {
+							what := "membership " + id
+							if ms, err := msService.Get(ctx, id); err == nil {
+								what = "membership of " + ms.PersonEmail
+							}
+							if !confirm(os.Stdin, os.Stderr, "Delete "+what+"?") {
+								fatal("Not deleted, use --yes to delete without confirmation.")
+							}
+						}
+						err := msService.Delete(ctx, id)
 						if err != nil {
-							log.Fatalln(err)
+							fatal(err)
 						} else {
 							if !jsonFlag {
 								fmt.Println("Membership deleted.")
@@ -582,8 +1359,433 @@ func main() {
 
 					},
 				},
+				{
+					Name:      "sync",
+					Usage:     "add and remove memberships to match a roster",
+					ArgsUsage: "<roomId> --from roster.csv",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "from, f",
+							Usage: "csv file with an email and optional moderator (true/false) column",
+						},
+						cli.BoolFlag{
+							Name:  "remove",
+							Usage: "remove members who aren't on the roster",
+						},
+						cli.BoolFlag{
+							Name:  "dry-run",
+							Usage: "show the changes without making them (same as the global --dry-run)",
+						},
+						cli.IntFlag{
+							Name:  "concurrency, c",
+							Value: 4,
+							Usage: "number of changes made at the same time",
+						},
+					},
+					Action: func(c *cli.Context) {
+						if c.NArg() != 1 || c.String("from") == "" {
+							fatal("Usage: sparkcli memberships sync <roomId> --from roster.csv")
+						}
+						roomId := c.Args().Get(0)
+						if roomId == "-" {
+							roomId = defaultRoomId(config)
+							if roomId == "" {
+								slog.Error("No default room configured.")
+								fatal("Usage: sparkcli memberships sync <roomId> --from roster.csv")
+							}
+						}
+						roster, err := loadRoster(c.String("from"))
+						if err != nil {
+							fatal(err)
+						}
+						syncer := newSyncer(spark, c.Int("concurrency"))
+						report, err := syncer.Sync(ctx, roomId, roster, c.Bool("remove"), dryRun || c.Bool("dry-run"))
+						if err != nil {
+							fatal(err)
+						}
+						if jsonFlag {
+							util.PrintJson(report)
+						} else {
+							printSyncReport(report)
+						}
+						for _, action := range report.Actions {
+							if action.Error != "" {
+								fatal("Not all changes were made.")
+							}
+						}
+					},
+				},
+			},
+		},
+		{
+			Name:    "orgs",
+			Aliases: []string{"o"},
+			Usage:   "operations on organizations (admins)",
+			Subcommands: []cli.Command{
+				{
+					Name:    "list",
+					Aliases: []string{"l"},
+					Usage:   "list the organizations you can manage",
+					Action: func(c *cli.Context) {
+						orgs, err := spark.Organizations.List(ctx)
+						if err != nil {
+							fatal(err)
+						}
+						if jsonFlag {
+							util.PrintJson(orgs)
+						} else {
+							for _, org := range *orgs {
+								fmt.Printf("%s: %s\n", org.Id, org.DisplayName)
+							}
+						}
+					},
+				},
+				{
+					Name:    "get",
+					Aliases: []string{"g"},
+					Usage:   "get organization details",
+					Action: func(c *cli.Context) {
+						if c.NArg() != 1 {
+							fatal("Usage: sparkcli orgs get <id>")
+						}
+						org, err := spark.Organizations.Get(ctx, c.Args().Get(0))
+						if err != nil {
+							fatal(err)
+						}
+						if jsonFlag {
+							util.PrintJson(org)
+						} else {
+							fmt.Printf("Id:      %s\n", org.Id)
+							fmt.Printf("Name:    %s\n", org.DisplayName)
+							fmt.Printf("Created: %s\n", org.Created)
+						}
+					},
+				},
+			},
+		},
+		{
+			Name:    "licenses",
+			Aliases: []string{"lic"},
+			Usage:   "operations on licenses (admins)",
+			Subcommands: []cli.Command{
+				{
+					Name:    "list",
+					Aliases: []string{"l"},
+					Usage:   "list the licenses of your organization",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "org, o",
+							Usage: "list the licenses of the organization with this id",
+						},
+					},
+					Action: func(c *cli.Context) {
+						licenses, err := spark.Licenses.List(ctx, c.String("org"))
+						if err != nil {
+							fatal(err)
+						}
+						if jsonFlag {
+							util.PrintJson(licenses)
+						} else {
+							for _, license := range *licenses {
+								fmt.Printf("%s: %s\n", license.Id, license.Name)
+							}
+						}
+					},
+				},
+				{
+					Name:    "get",
+					Aliases: []string{"g"},
+					Usage:   "get license details",
+					Action: func(c *cli.Context) {
+						if c.NArg() != 1 {
+							fatal("Usage: sparkcli licenses get <id>")
+						}
+						license, err := spark.Licenses.Get(ctx, c.Args().Get(0))
+						if err != nil {
+							fatal(err)
+						}
+						if jsonFlag {
+							util.PrintJson(license)
+						} else {
+							fmt.Printf("Id:       %s\n", license.Id)
+							fmt.Printf("Name:     %s\n", license.Name)
+							fmt.Printf("Consumed: %d\n", license.ConsumedUnits)
+							fmt.Printf("Total:    %d\n", license.TotalUnits)
+							if license.SiteUrl != "" {
+								fmt.Printf("Site:     %s\n", license.SiteUrl)
+							}
+						}
+					},
+				},
+				{
+					Name:    "usage",
+					Aliases: []string{"u"},
+					Usage:   "report consumed vs total units of all licenses",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "org, o",
+							Usage: "report on the organization with this id",
+						},
+						cli.StringFlag{
+							Name:  "format, f",
+							Value: "table",
+							Usage: "report format: table, csv or json",
+						},
+					},
+					Action: func(c *cli.Context) {
+						format := c.String("format")
+						licenses, err := spark.Licenses.List(ctx, c.String("org"))
+						if err != nil {
+							fatal(err)
+						}
+						if format == "json" {
+							util.PrintJson(licenses)
+						} else if err := writeLicenseUsage(os.Stdout, *licenses, format); err != nil {
+							fatal(err)
+						}
+					},
+				},
+			},
+		},
+		{
+			Name:  "roles",
+			Usage: "list the admin roles that can be given to people",
+			Subcommands: []cli.Command{
+				{
+					Name:    "list",
+					Aliases: []string{"l"},
+					Usage:   "list all roles",
+					Action: func(c *cli.Context) {
+						roles, err := spark.Roles.List(ctx)
+						if err != nil {
+							fatal(err)
+						}
+						if jsonFlag {
+							util.PrintJson(roles)
+						} else {
+							for _, role := range *roles {
+								fmt.Printf("%s: %s\n", role.Id, role.Name)
+							}
+						}
+					},
+				},
+				{
+					Name:    "get",
+					Aliases: []string{"g"},
+					Usage:   "get role details",
+					Action: func(c *cli.Context) {
+						if c.NArg() != 1 {
+							fatal("Usage: sparkcli roles get <id>")
+						}
+						role, err := spark.Roles.Get(ctx, c.Args().Get(0))
+						if err != nil {
+							fatal(err)
+						}
+						if jsonFlag {
+							util.PrintJson(role)
+						} else {
+							fmt.Printf("Id:   %s\n", role.Id)
+							fmt.Printf("Name: %s\n", role.Name)
+						}
+					},
+				},
+			},
+		},
+		{
+			Name:    "actions",
+			Aliases: []string{"a"},
+			Usage:   "operations on attachment actions (card submissions)",
+			Subcommands: []cli.Command{
+				{
+					Name:    "get",
+					Aliases: []string{"g"},
+					Usage:   "get what was submitted with a card",
+					Action: func(c *cli.Context) {
+						if c.NArg() != 1 {
+							fatal("Usage: sparkcli actions get <id>")
+						}
+						action, err := spark.AttachmentActions.Get(ctx, c.Args().Get(0))
+						if err != nil {
+							fatal(err)
+						}
+						if jsonFlag {
+							util.PrintJson(action)
+						} else {
+							fmt.Printf("Id:        %s\n", action.Id)
+							fmt.Printf("Type:      %s\n", action.Type)
+							fmt.Printf("MessageId: %s\n", action.MessageId)
+							fmt.Printf("PersonId:  %s\n", action.PersonId)
+							fmt.Printf("RoomId:    %s\n", action.RoomId)
+							names := make([]string, 0, len(action.Inputs))
+							for name := range action.Inputs {
+								names = append(names, name)
+							}
+							sort.Strings(names)
+							for _, name := range names {
+								fmt.Printf("Input:     %s = %v\n", name, action.Inputs[name])
+							}
+							fmt.Printf("Created:   %s\n", action.Created)
+						}
+					},
+				},
+			},
+		},
+		{
+			Name:    "events",
+			Aliases: []string{"e"},
+			Usage:   "audit what happened to messages and memberships (compliance officers)",
+			Subcommands: []cli.Command{
+				{
+					Name:    "list",
+					Aliases: []string{"l"},
+					Usage:   "list events, newest first",
+					Flags: append(eventFilterFlags,
+						cli.IntFlag{
+							Name:  "max, n",
+							Usage: "maximum number of events (default all)",
+						},
+					),
+					Action: func(c *cli.Context) {
+						filter, err := eventFilter(c)
+						if err != nil {
+							fatal(err)
+						}
+						var events *[]api.Event
+						if max := c.Int("max"); max > 0 {
+							events, err = spark.Events.List(ctx, filter, max)
+						} else {
+							events, err = spark.Events.ListAll(ctx, filter)
+						}
+						if err != nil {
+							fatal(err)
+						}
+						if jsonFlag {
+							util.PrintJson(events)
+						} else {
+							for _, event := range *events {
+								fmt.Printf("[%v] %v %v by %v\n", event.Created, event.Resource, event.Type, event.ActorId)
+							}
+						}
+					},
+				},
+				{
+					Name:  "watch",
+					Usage: "print new events as they happen, one json object per line",
+					Flags: append(eventFilterFlags,
+						cli.DurationFlag{
+							Name:  "interval, i",
+							Value: 10 * time.Second,
+							Usage: "time between polls",
+						},
+					),
+					Action: func(c *cli.Context) {
+						filter, err := eventFilter(c)
+						if err != nil {
+							fatal(err)
+						}
+						w := newEventWatcher(spark.Events, filter, os.Stdout)
+						if err := w.Run(ctx, c.Duration("interval")); err != nil {
+							fatal(err)
+						}
+					},
+				},
+			},
+		},
+		{
+			Name:      "apply",
+			Usage:     "bring teams, rooms, members and webhooks in line with a workspace file",
+			ArgsUsage: "-f workspace.toml",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "file, f",
+					Usage: "workspace file (toml)",
+				},
+				cli.BoolFlag{
+					Name:  "allow-delete",
+					Usage: "allow deleting rooms, members and webhooks",
+				},
+			},
+			Action: func(c *cli.Context) {
+				if c.String("file") == "" {
+					fatal("Usage: sparkcli apply -f workspace.toml")
+				}
+				ws, err := loadWorkspace(c.String("file"))
+				if err != nil {
+					fatal(err)
+				}
+				plan, err := planWorkspace(ctx, spark, ws)
+				if err != nil {
+					fatal(err)
+				}
+				plan.Print(os.Stdout)
+				if dryRun || len(plan.steps) == 0 {
+					return
+				}
+				if _, _, deletes := plan.count(); deletes > 0 && !c.Bool("allow-delete") {
+					fatal("The plan deletes resources, run with --allow-delete to apply it.")
+				}
+				fmt.Println()
+				if err := plan.Apply(ctx, os.Stdout); err != nil {
+					fatal(err)
+				}
+			},
+		},
+		{
+			Name:  "completion",
+			Usage: "print a shell completion script",
+			Subcommands: []cli.Command{
+				{
+					Name:  "bash",
+					Usage: "print bash completion script",
+					Action: func(c *cli.Context) {
+						writeBashCompletion(os.Stdout, app)
+					},
+				},
+				{
+					Name:  "zsh",
+					Usage: "print zsh completion script",
+					Action: func(c *cli.Context) {
+						writeZshCompletion(os.Stdout, app)
+					},
+				},
+				{
+					Name:  "fish",
+					Usage: "print fish completion script",
+					Action: func(c *cli.Context) {
+						writeFishCompletion(os.Stdout, app)
+					},
+				},
+				// Used by the completion scripts for dynamic values.
+				{
+					Name:   "rooms",
+					Hidden: true,
+					Action: func(c *cli.Context) {
+						if err := printRoomCompletions(ctx, os.Stdout, config, spark); err != nil {
+							fatal(err)
+						}
+					},
+				},
+				{
+					Name:   "memberships",
+					Hidden: true,
+					Action: func(c *cli.Context) {
+						if err := printMembershipCompletions(ctx, os.Stdout, config, spark); err != nil {
+							fatal(err)
+						}
+					},
+				},
+			},
+		},
+		{
+			Name:  "shell",
+			Usage: "start an interactive session",
+			Action: func(c *cli.Context) {
+				sh := newShell(app, config, spark)
+				if err := sh.Run(ctx); err != nil {
+					fatal(err)
+				}
 			},
 		},
 	}
-	app.Run(os.Args)
+	return app
 }
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tdeckers/sparkcli/api"
+	"github.com/tdeckers/sparkcli/sparktest"
+	"github.com/tdeckers/sparkcli/util"
+)
+
+// runApp runs sparkcli with args against server, and returns what it printed
+// on stdout.  A failing command returns an error instead of exiting.  The
+// cache is kept in a temporary home directory, shared by the runs of a test.
+func runApp(t *testing.T, server *sparktest.Server, args ...string) (out string, err error) {
+	if _, ok := testHomes[t]; !ok {
+		testHomes[t] = t.TempDir()
+		t.Setenv("HOME", testHomes[t])
+		t.Cleanup(func() { delete(testHomes, t) })
+	}
+	config := server.Config()
+	client, err := util.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	output := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		output <- buf.String()
+	}()
+
+	exit := fatal
+	fatal = abortCommand
+	defer func() {
+		fatal = exit
+		os.Stdout = stdout
+		w.Close()
+		out = <-output
+		if r := recover(); r != nil {
+			aborted, ok := r.(commandAborted)
+			if !ok {
+				panic(r)
+			}
+			if aborted.msg != "" {
+				err = errors.New(aborted.msg)
+			}
+		}
+	}()
+	return "", newApp(config, client).Run(append([]string{"sparkcli"}, args...))
+}
+
+// testHomes holds the home directory of each running test.
+var testHomes = map[*testing.T]string{}
+
+func TestRoomsCommands(t *testing.T) {
+	server := sparktest.NewServer()
+	defer server.Close()
+
+	out, err := runApp(t, server, "-j=false", "rooms", "create", "ops")
+	if err != nil {
+		t.Fatalf("rooms create: error = %v", err)
+	}
+	rooms := server.Rooms()
+	if len(rooms) != 1 || out != rooms[0].Id {
+		t.Fatalf("rooms create printed %q, want the id of the new room in %+v", out, rooms)
+	}
+
+	out, err = runApp(t, server, "rooms", "get", rooms[0].Id)
+	var room api.Room
+	if err != nil || json.Unmarshal([]byte(out), &room) != nil || room.Title != "ops" {
+		t.Errorf("rooms get = %q, %v, want room ops as json", out, err)
+	}
+
+	out, err = runApp(t, server, "-j=false", "rooms", "delete", "--yes", rooms[0].Id)
+	if err != nil || out != "Room deleted.\n" {
+		t.Errorf("rooms delete = %q, %v, want Room deleted.", out, err)
+	}
+	if got := len(server.Rooms()); got != 0 {
+		t.Errorf("rooms delete left %d rooms, want 0", got)
+	}
+}
+
+func TestRoomsInTeam(t *testing.T) {
+	server := sparktest.NewServer()
+	defer server.Close()
+	team := server.AddTeam("platform")
+	server.AddRoom("elsewhere")
+
+	out, err := runApp(t, server, "-j=false", "rooms", "create", "--team", team.Id, "oncall")
+	if err != nil {
+		t.Fatalf("rooms create --team: error = %v", err)
+	}
+	if _, err := runApp(t, server, "rooms", "update", "--lock", out); err != nil {
+		t.Fatalf("rooms update --lock: error = %v", err)
+	}
+
+	out, err = runApp(t, server, "rooms", "list", "--team", team.Id, "--sort", "created")
+	var rooms []api.Room
+	if err != nil || json.Unmarshal([]byte(out), &rooms) != nil {
+		t.Fatalf("rooms list --team = %q, %v, want rooms as json", out, err)
+	}
+	if len(rooms) != 2 || rooms[0].Title != "oncall" || !rooms[0].IsLocked || rooms[1].Title != "platform" {
+		t.Errorf("rooms list --team = %+v, want locked room oncall and the general room of the team", rooms)
+	}
+	if _, err := runApp(t, server, "rooms", "update", rooms[0].Id); err == nil {
+		t.Errorf("rooms update without changes: no error, want nothing to update")
+	}
+}
+
+func TestMessagesCommands(t *testing.T) {
+	server := sparktest.NewServer()
+	defer server.Close()
+	room := server.AddRoom("ops", "alice@example.com")
+	server.AddMessage(room.Id, "alice@example.com", "hi there")
+
+	if _, err := runApp(t, server, "messages", "create", "text", room.Id, "hello"); err != nil {
+		t.Fatalf("messages create text: error = %v", err)
+	}
+	out, err := runApp(t, server, "messages", "list", room.Id)
+	if err != nil {
+		t.Fatalf("messages list: error = %v", err)
+	}
+	var msgs []api.Message
+	if err := json.Unmarshal([]byte(out), &msgs); err != nil {
+		t.Fatalf("messages list printed %q: %v", out, err)
+	}
+	var got []string
+	for _, msg := range msgs {
+		got = append(got, msg.PersonEmail+": "+msg.Text)
+	}
+	want := []string{sparktest.MeEmail + ": hello", "alice@example.com: hi there"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("messages list = %q, want %q", got, want)
+	}
+
+	// Without -f, tail doesn't continue from the previous run.
+	for run := 1; run <= 2; run++ {
+		out, err := runApp(t, server, "-j=false", "messages", "tail", "-n", "1", room.Id)
+		if err != nil || !strings.HasSuffix(out, sparktest.MeEmail+": hello\n") {
+			t.Errorf("messages tail run %d = %q, %v, want the last message", run, out, err)
+		}
+	}
+	if util.LoadCache("tail-"+room.Id, 0, &tailCursor{}) {
+		t.Errorf("messages tail saved its position, want that only with -f")
+	}
+
+	// More lines than fit in a page.
+	server.SetPageSize(1)
+	out, err = runApp(t, server, "-j=false", "messages", "tail", "-n", "3", room.Id)
+	if err != nil || strings.Count(out, "\n") != 2 || !strings.Contains(out, "alice@example.com: hi there\n") {
+		t.Errorf("messages tail -n 3 = %q, %v, want both messages", out, err)
+	}
+
+	// Without subcommand, create sends text.
+	if _, err := runApp(t, server, "m", "c", "-m", room.Id, "**bye**"); err != nil {
+		t.Fatalf("m c: error = %v", err)
+	}
+	msgs = server.Messages(room.Id)
+	if last := msgs[len(msgs)-1]; last.Markdown != "**bye**" {
+		t.Errorf("m c sent %+v, want markdown **bye**", last)
+	}
+}
+
+func TestMembershipsCreate(t *testing.T) {
+	server := sparktest.NewServer()
+	defer server.Close()
+	room := server.AddRoom("ops")
+
+	_, err := runApp(t, server, "memberships", "create", "-r", room.Id, "-e", "alice@example.com", "-m")
+	if err != nil {
+		t.Fatalf("memberships create: error = %v", err)
+	}
+	mss := server.Memberships(room.Id)
+	if len(mss) != 2 || mss[1].PersonEmail != "alice@example.com" || !mss[1].IsModerator {
+		t.Errorf("memberships create: room has %+v, want alice as moderator", mss)
+	}
+}
+
+func TestPeopleAdminCommands(t *testing.T) {
+	server := sparktest.NewServer()
+	defer server.Close()
+
+	id, err := runApp(t, server, "-j=false", "people", "create", "-e", "carol@example.com", "-n", "Carol",
+		"--license", "messaging", "--license", "meetings")
+	if err != nil {
+		t.Fatalf("people create: error = %v", err)
+	}
+	if _, err := runApp(t, server, "people", "update", "--nick-name", "CJ", "--phone", "mobile:+32475000000", id); err != nil {
+		t.Fatalf("people update: error = %v", err)
+	}
+	out, err := runApp(t, server, "people", "list", "--id", id)
+	var people []api.People
+	if err != nil || json.Unmarshal([]byte(out), &people) != nil || len(people) != 1 {
+		t.Fatalf("people list --id = %q, %v, want carol as json", out, err)
+	}
+	carol := people[0]
+	if carol.DisplayName != "Carol" || carol.NickName != "CJ" || len(carol.Licenses) != 2 ||
+		len(carol.PhoneNumbers) != 1 || carol.PhoneNumbers[0].Type != "mobile" {
+		t.Errorf("people list --id = %+v, want carol with licenses, nick name and phone", carol)
+	}
+	if _, err := runApp(t, server, "people", "update", "--phone", "+32475000000", id); err == nil {
+		t.Errorf("people update with a phone without type: no error")
+	}
+	requests := len(server.Requests())
+	if _, err := runApp(t, server, "people", "update", id); err == nil || !strings.Contains(err.Error(), "Nothing to update") {
+		t.Errorf("people update without flags: error = %v, want Nothing to update", err)
+	}
+	if got := len(server.Requests()); got != requests {
+		t.Errorf("people update without flags sent %d requests, want none", got-requests)
+	}
+
+	out, err = runApp(t, server, "-j=false", "people", "delete", "--yes", id)
+	if err != nil || out != "Person deleted.\n" {
+		t.Errorf("people delete = %q, %v, want Person deleted.", out, err)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	server := sparktest.NewServer()
+	defer server.Close()
+
+	out, err := runApp(t, server, "--dry-run", "rooms", "create", "ops")
+	if err != nil {
+		t.Fatalf("--dry-run rooms create: error = %v", err)
+	}
+	if !strings.HasPrefix(out, "POST "+server.URL+"/rooms") {
+		t.Errorf("--dry-run rooms create printed %q, want the request", out)
+	}
+	if got := len(server.Requests()); got != 0 {
+		t.Errorf("--dry-run rooms create sent %d requests, want none", got)
+	}
+
+	room := server.AddRoom("ops")
+	long := strings.Repeat("word ", api.MaxMessageSize/5+1)
+	out, err = runApp(t, server, "--dry-run", "messages", "create", "text", "--split", room.Id, long)
+	if err != nil || strings.Count(out, "POST ") != 2 {
+		t.Errorf("--dry-run messages create --split = %q, %v, want both parts", out, err)
+	}
+
+	archive := filepath.Join(t.TempDir(), "archive.json")
+	os.WriteFile(archive, []byte(`{"messages": [{"text": "one"}, {"text": "two"}, {"text": "three"}]}`), 0600)
+	out, err = runApp(t, server, "--dry-run", "rooms", "import", "--delay", "0", "--into", room.Id, archive)
+	if err != nil || strings.Count(out, "POST ") != 3 {
+		t.Errorf("--dry-run rooms import = %q, %v, want all three messages", out, err)
+	}
+	if got := len(server.Messages(room.Id)); got != 0 {
+		t.Errorf("--dry-run posted %d messages, want none", got)
+	}
+
+	roster := filepath.Join(t.TempDir(), "roster.csv")
+	os.WriteFile(roster, []byte("alice@example.com\n"), 0600)
+	for _, args := range [][]string{
+		{"--dry-run", "memberships", "sync", room.Id, "--from", roster},
+		{"memberships", "sync", room.Id, "--from", roster, "--dry-run"},
+		{"memberships", "sync", "--dry-run", "-f", roster, room.Id},
+	} {
+		out, err = runApp(t, server, append([]string{"-j=false"}, args...)...)
+		if err != nil || !strings.Contains(out, "alice@example.com (moderator: false)  planned") {
+			t.Errorf("%q = %q, %v, want alice's membership planned", args, out, err)
+		}
+	}
+	for _, request := range server.Requests() {
+		if strings.HasPrefix(request, "POST /memberships") {
+			t.Errorf("memberships sync --dry-run sent %q, want no changes", request)
+		}
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	server := sparktest.NewServer()
+	defer server.Close()
+	room := server.AddRoom("ops")
+
+	tests := []struct {
+		name    string
+		fail    int
+		args    []string
+		wantErr string
+	}{
+		{"usage", 0, []string{"rooms", "create"}, "Usage:"},
+		{"not found", 0, []string{"rooms", "get", "nope"}, "404"},
+		{"server error", 500, []string{"rooms", "get", room.Id}, "500"},
+		{"unavailable", 503, []string{"messages", "list", room.Id}, "503"},
+	}
+	for _, tt := range tests {
+		if tt.fail != 0 {
+			server.Fail("", "/", tt.fail, 1)
+		}
+		_, err := runApp(t, server, tt.args...)
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%q. %v: error = %v, want %q", tt.name, tt.args, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	server := sparktest.NewServer()
+	room := server.AddRoom("ops", "alice@example.com")
+	server.AddMessage(room.Id, "alice@example.com", "hi there")
+	cassette := filepath.Join(t.TempDir(), "messages-list.json")
+
+	t.Setenv(util.RecordEnv, cassette)
+	recorded, err := runApp(t, server, "messages", "list", room.Id)
+	if err != nil {
+		t.Fatalf("messages list while recording: error = %v", err)
+	}
+	server.Close()
+
+	t.Setenv(util.RecordEnv, "")
+	t.Setenv(util.ReplayEnv, cassette)
+	replayed, err := runApp(t, server, "messages", "list", room.Id)
+	if err != nil || replayed != recorded {
+		t.Errorf("messages list while replaying = %q, %v, want %q", replayed, err, recorded)
+	}
+}
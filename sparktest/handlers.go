@@ -0,0 +1,805 @@
+package sparktest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/tdeckers/sparkcli/api"
+)
+
+// maxUploadMemory is the part of an upload kept in memory while parsing it.
+const maxUploadMemory = 32 << 20
+
+// servePeople handles /people: searching by email, name, ids or
+// organization, getting a person (or "me") by id, and managing people as an
+// admin.
+func (s *Server) servePeople(w http.ResponseWriter, r *http.Request, id string) {
+	switch {
+	case id == "" && r.Method == "GET":
+		query := r.URL.Query()
+		email, name, ids := query.Get("email"), query.Get("displayName"), query.Get("id")
+		if email == "" && name == "" && ids == "" && query.Get("orgId") == "" {
+			writeError(w, http.StatusBadRequest, "Email, displayName, id or orgId should be specified.")
+			return
+		}
+		if ids != "" && len(strings.Split(ids, ",")) > api.MaxPeopleIds {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("At most %d ids can be specified.", api.MaxPeopleIds))
+			return
+		}
+		var people []api.People
+		for _, p := range s.people {
+			if email != "" && !strings.EqualFold(p.Emails[0], email) {
+				continue
+			}
+			if name != "" && !strings.HasPrefix(strings.ToLower(p.DisplayName), strings.ToLower(name)) {
+				continue
+			}
+			if ids != "" && !contains(strings.Split(ids, ","), p.Id) {
+				continue
+			}
+			if filter(query, "orgId", p.OrgId) {
+				people = append(people, p)
+			}
+		}
+		s.writeItems(w, r, len(people), func(from, to int) interface{} { return people[from:to] })
+	case id == "" && r.Method == "POST":
+		var body api.People
+		if !decode(w, r, &body) {
+			return
+		}
+		if len(body.Emails) == 0 {
+			writeError(w, http.StatusBadRequest, "Emails is required.")
+			return
+		}
+		for _, p := range s.people {
+			if strings.EqualFold(p.Emails[0], body.Emails[0]) {
+				writeError(w, http.StatusConflict, "Person already exists.")
+				return
+			}
+		}
+		person := s.personByEmail(body.Emails[0])
+		i := s.personIndex(person.Id)
+		s.people[i] = updatePerson(s.people[i], body)
+		writeJson(w, http.StatusOK, s.people[i])
+	case id == "":
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+	default:
+		if id == "me" {
+			id = s.me.Id
+		}
+		i := s.personIndex(id)
+		if i < 0 {
+			writeError(w, http.StatusNotFound, "Person not found.")
+			return
+		}
+		switch r.Method {
+		case "GET":
+			writeJson(w, http.StatusOK, s.people[i])
+		case "PUT":
+			var body api.People
+			if !decode(w, r, &body) {
+				return
+			}
+			if len(body.Emails) == 0 {
+				writeError(w, http.StatusBadRequest, "Emails is required.")
+				return
+			}
+			s.people[i] = updatePerson(s.people[i], body)
+			writeJson(w, http.StatusOK, s.people[i])
+		case "DELETE":
+			s.people = append(s.people[:i], s.people[i+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+		}
+	}
+}
+
+// updatePerson returns person with the details an admin can set taken from
+// body.
+func updatePerson(person, body api.People) api.People {
+	person.Emails = body.Emails
+	person.PhoneNumbers = body.PhoneNumbers
+	person.FirstName, person.LastName, person.NickName = body.FirstName, body.LastName, body.NickName
+	if body.DisplayName != "" {
+		person.DisplayName = body.DisplayName
+	}
+	person.Avatar = body.Avatar
+	person.Roles, person.Licenses = body.Roles, body.Licenses
+	return person
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// serveRooms handles /rooms.
+func (s *Server) serveRooms(w http.ResponseWriter, r *http.Request, id string) {
+	switch {
+	case id == "" && r.Method == "GET":
+		query := r.URL.Query()
+		var rooms []api.Room
+		for _, room := range s.rooms {
+			if filter(query, "teamId", room.TeamId) && filter(query, "type", room.Type) {
+				rooms = append(rooms, room)
+			}
+		}
+		switch query.Get("sortBy") {
+		case "", "id":
+		case "lastactivity":
+			sort.SliceStable(rooms, func(i, j int) bool { return rooms[i].LastActivity > rooms[j].LastActivity })
+		case "created":
+			sort.SliceStable(rooms, func(i, j int) bool { return rooms[i].Created > rooms[j].Created })
+		default:
+			writeError(w, http.StatusBadRequest, "sortBy must be id, lastactivity or created.")
+			return
+		}
+		s.writeItems(w, r, len(rooms), func(from, to int) interface{} { return rooms[from:to] })
+	case id == "" && r.Method == "POST":
+		var body api.Room
+		if !decode(w, r, &body) {
+			return
+		}
+		if body.Title == "" {
+			writeError(w, http.StatusBadRequest, "Title is required.")
+			return
+		}
+		if body.TeamId != "" && s.teamIndex(body.TeamId) < 0 {
+			writeError(w, http.StatusNotFound, "Team not found.")
+			return
+		}
+		room := s.addRoom(body.Title, "group", body.TeamId)
+		i := s.roomIndex(room.Id)
+		s.rooms[i].IsLocked, s.rooms[i].IsPublic, s.rooms[i].Description = body.IsLocked, body.IsPublic, body.Description
+		writeJson(w, http.StatusOK, s.rooms[i])
+	case id == "":
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+	default:
+		i := s.roomIndex(id)
+		if i < 0 {
+			writeError(w, http.StatusNotFound, "Room not found.")
+			return
+		}
+		switch r.Method {
+		case "GET":
+			writeJson(w, http.StatusOK, s.rooms[i])
+		case "PUT":
+			var body struct {
+				Title    string `json:"title"`
+				IsLocked *bool  `json:"isLocked"`
+			}
+			if !decode(w, r, &body) {
+				return
+			}
+			if body.Title == "" {
+				writeError(w, http.StatusBadRequest, "Title is required.")
+				return
+			}
+			s.rooms[i].Title = body.Title
+			if body.IsLocked != nil {
+				s.rooms[i].IsLocked = *body.IsLocked
+			}
+			writeJson(w, http.StatusOK, s.rooms[i])
+		case "DELETE":
+			s.deleteRoom(id)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+		}
+	}
+}
+
+// serveMessages handles /messages.  Messages are listed newest first, and
+// can be posted as json or, with files, as multipart form.
+func (s *Server) serveMessages(w http.ResponseWriter, r *http.Request, id string) {
+	switch {
+	case id == "" && r.Method == "GET":
+		roomId := r.URL.Query().Get("roomId")
+		if roomId == "" {
+			writeError(w, http.StatusBadRequest, "roomId is required.")
+			return
+		}
+		if s.roomIndex(roomId) < 0 {
+			writeError(w, http.StatusNotFound, "Room not found.")
+			return
+		}
+		var msgs []api.Message
+		for i := len(s.messages) - 1; i >= 0; i-- {
+			if s.messages[i].RoomId == roomId {
+				msgs = append(msgs, s.messages[i])
+			}
+		}
+		s.writeItems(w, r, len(msgs), func(from, to int) interface{} { return msgs[from:to] })
+	case id == "" && r.Method == "POST":
+		s.createMessage(w, r)
+	case id == "":
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+	default:
+		i := s.messageIndex(id)
+		if i < 0 {
+			writeError(w, http.StatusNotFound, "Message not found.")
+			return
+		}
+		switch r.Method {
+		case "GET":
+			writeJson(w, http.StatusOK, s.messages[i])
+		case "DELETE":
+			s.addEvent("messages", "deleted", s.me.Id, s.messages[i])
+			s.messages = append(s.messages[:i], s.messages[i+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+		}
+	}
+}
+
+// createMessage posts a message to a room, or to a person in a direct room.
+func (s *Server) createMessage(w http.ResponseWriter, r *http.Request) {
+	var msg api.Message
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid form: "+err.Error())
+			return
+		}
+		msg.RoomId = r.FormValue("roomId")
+		msg.Text = r.FormValue("text")
+		msg.Markdown = r.FormValue("markdown")
+		msg.ToPersonEmail = r.FormValue("toPersonEmail")
+		msg.ToPersonId = r.FormValue("toPersonId")
+		for _, header := range r.MultipartForm.File["files"] {
+			file, err := header.Open()
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			data, err := ioutil.ReadAll(file)
+			file.Close()
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			contentId := s.newId("content")
+			s.contents[contentId] = content{name: header.Filename,
+				contentType: header.Header.Get("Content-Type"), data: data}
+			msg.Files = append(msg.Files, s.URL+"/contents/"+contentId)
+		}
+	} else if !decode(w, r, &msg) {
+		return
+	}
+	if msg.Text == "" && msg.Markdown == "" && len(msg.Files) == 0 && len(msg.Attachments) == 0 {
+		writeError(w, http.StatusBadRequest, "Text, markdown, files or attachments are required.")
+		return
+	}
+	if len(msg.Attachments) > 1 {
+		writeError(w, http.StatusBadRequest, "Only one attachment per message is supported.")
+		return
+	}
+
+	switch {
+	case msg.RoomId != "":
+		if s.roomIndex(msg.RoomId) < 0 {
+			writeError(w, http.StatusNotFound, "Room not found.")
+			return
+		}
+	case msg.ToPersonId != "":
+		i := s.personIndex(msg.ToPersonId)
+		if i < 0 {
+			writeError(w, http.StatusNotFound, "Person not found.")
+			return
+		}
+		msg.RoomId = s.directRoom(s.people[i]).Id
+	case msg.ToPersonEmail != "":
+		msg.RoomId = s.directRoom(s.personByEmail(msg.ToPersonEmail)).Id
+	default:
+		writeError(w, http.StatusBadRequest, "roomId, toPersonId or toPersonEmail is required.")
+		return
+	}
+	msg.PersonId = s.me.Id
+	msg.PersonEmail = s.me.Emails[0]
+	writeJson(w, http.StatusOK, s.addMessage(msg))
+}
+
+// serveMemberships handles /memberships.
+func (s *Server) serveMemberships(w http.ResponseWriter, r *http.Request, id string) {
+	switch {
+	case id == "" && r.Method == "GET":
+		query := r.URL.Query()
+		var mss []api.Membership
+		for _, ms := range s.memberships {
+			if filter(query, "roomId", ms.RoomId) && filter(query, "personId", ms.PersonId) &&
+				filter(query, "personEmail", ms.PersonEmail) {
+				mss = append(mss, ms)
+			}
+		}
+		s.writeItems(w, r, len(mss), func(from, to int) interface{} { return mss[from:to] })
+	case id == "" && r.Method == "POST":
+		var body api.Membership
+		if !decode(w, r, &body) {
+			return
+		}
+		if s.roomIndex(body.RoomId) < 0 {
+			writeError(w, http.StatusNotFound, "Room not found.")
+			return
+		}
+		person, ok := s.findPerson(w, body.PersonId, body.PersonEmail)
+		if !ok {
+			return
+		}
+		for _, ms := range s.memberships {
+			if ms.RoomId == body.RoomId && ms.PersonId == person.Id {
+				writeError(w, http.StatusConflict, "Person is already in the room.")
+				return
+			}
+		}
+		writeJson(w, http.StatusOK, s.addMembership(body.RoomId, person, body.IsModerator))
+	case id == "":
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+	default:
+		i := s.membershipIndex(id)
+		if i < 0 {
+			writeError(w, http.StatusNotFound, "Membership not found.")
+			return
+		}
+		switch r.Method {
+		case "GET":
+			writeJson(w, http.StatusOK, s.memberships[i])
+		case "PUT":
+			var body api.Membership
+			if !decode(w, r, &body) {
+				return
+			}
+			s.memberships[i].IsModerator = body.IsModerator
+			s.addEvent("memberships", "updated", s.me.Id, s.memberships[i])
+			writeJson(w, http.StatusOK, s.memberships[i])
+		case "DELETE":
+			s.addEvent("memberships", "deleted", s.me.Id, s.memberships[i])
+			s.memberships = append(s.memberships[:i], s.memberships[i+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+		}
+	}
+}
+
+// serveTeams handles /teams.
+func (s *Server) serveTeams(w http.ResponseWriter, r *http.Request, id string) {
+	switch {
+	case id == "" && r.Method == "GET":
+		teams := s.teams
+		s.writeItems(w, r, len(teams), func(from, to int) interface{} { return teams[from:to] })
+	case id == "" && r.Method == "POST":
+		var body api.Team
+		if !decode(w, r, &body) {
+			return
+		}
+		if body.Name == "" {
+			writeError(w, http.StatusBadRequest, "Name is required.")
+			return
+		}
+		writeJson(w, http.StatusOK, s.addTeam(body.Name))
+	case id == "":
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+	default:
+		i := s.teamIndex(id)
+		if i < 0 {
+			writeError(w, http.StatusNotFound, "Team not found.")
+			return
+		}
+		switch r.Method {
+		case "GET":
+			writeJson(w, http.StatusOK, s.teams[i])
+		case "PUT":
+			var body api.Team
+			if !decode(w, r, &body) {
+				return
+			}
+			if body.Name != "" {
+				s.teams[i].Name = body.Name
+			}
+			writeJson(w, http.StatusOK, s.teams[i])
+		case "DELETE":
+			s.teams = append(s.teams[:i], s.teams[i+1:]...)
+			for _, room := range append([]api.Room(nil), s.rooms...) {
+				if room.TeamId == id {
+					s.deleteRoom(room.Id)
+				}
+			}
+			var tms []api.TeamMembership
+			for _, tm := range s.teamMemberships {
+				if tm.TeamId != id {
+					tms = append(tms, tm)
+				}
+			}
+			s.teamMemberships = tms
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+		}
+	}
+}
+
+// serveTeamMemberships handles /team/memberships.
+func (s *Server) serveTeamMemberships(w http.ResponseWriter, r *http.Request, id string) {
+	switch {
+	case id == "" && r.Method == "GET":
+		teamId := r.URL.Query().Get("teamId")
+		if teamId == "" {
+			writeError(w, http.StatusBadRequest, "teamId is required.")
+			return
+		}
+		var tms []api.TeamMembership
+		for _, tm := range s.teamMemberships {
+			if tm.TeamId == teamId {
+				tms = append(tms, tm)
+			}
+		}
+		s.writeItems(w, r, len(tms), func(from, to int) interface{} { return tms[from:to] })
+	case id == "" && r.Method == "POST":
+		var body api.TeamMembership
+		if !decode(w, r, &body) {
+			return
+		}
+		if s.teamIndex(body.TeamId) < 0 {
+			writeError(w, http.StatusNotFound, "Team not found.")
+			return
+		}
+		person, ok := s.findPerson(w, body.PersonId, body.PersonEmail)
+		if !ok {
+			return
+		}
+		for _, tm := range s.teamMemberships {
+			if tm.TeamId == body.TeamId && tm.PersonId == person.Id {
+				writeError(w, http.StatusConflict, "Person is already in the team.")
+				return
+			}
+		}
+		writeJson(w, http.StatusOK, s.addTeamMembership(body.TeamId, person, body.IsModerator))
+	case id == "":
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+	default:
+		i := -1
+		for n, tm := range s.teamMemberships {
+			if tm.Id == id {
+				i = n
+			}
+		}
+		if i < 0 {
+			writeError(w, http.StatusNotFound, "Team membership not found.")
+			return
+		}
+		switch r.Method {
+		case "GET":
+			writeJson(w, http.StatusOK, s.teamMemberships[i])
+		case "PUT":
+			var body api.TeamMembership
+			if !decode(w, r, &body) {
+				return
+			}
+			s.teamMemberships[i].IsModerator = body.IsModerator
+			writeJson(w, http.StatusOK, s.teamMemberships[i])
+		case "DELETE":
+			s.teamMemberships = append(s.teamMemberships[:i], s.teamMemberships[i+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+		}
+	}
+}
+
+// serveAttachmentActions handles /attachment/actions/{id}.
+func (s *Server) serveAttachmentActions(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+		return
+	}
+	for _, action := range s.actions {
+		if action.Id == id {
+			writeJson(w, http.StatusOK, action)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Attachment action not found.")
+}
+
+// serveOrganizations handles /organizations.  There's only one, OrgId.
+func (s *Server) serveOrganizations(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+		return
+	}
+	org := api.Organization{Id: OrgId, DisplayName: OrgName, Created: "2016-01-01T00:00:00.000Z"}
+	switch id {
+	case "":
+		s.writeItems(w, r, 1, func(from, to int) interface{} { return []api.Organization{org}[from:to] })
+	case OrgId:
+		writeJson(w, http.StatusOK, org)
+	default:
+		writeError(w, http.StatusNotFound, "Organization not found.")
+	}
+}
+
+// serveLicenses handles /licenses.  The consumed units of a license are the
+// people it's assigned to.
+func (s *Server) serveLicenses(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+		return
+	}
+	licenses := make([]api.License, 0, len(s.licenses))
+	for _, license := range s.licenses {
+		for _, p := range s.people {
+			if contains(p.Licenses, license.Id) {
+				license.ConsumedUnits++
+			}
+		}
+		if id == license.Id {
+			writeJson(w, http.StatusOK, license)
+			return
+		}
+		licenses = append(licenses, license)
+	}
+	if id != "" {
+		writeError(w, http.StatusNotFound, "License not found.")
+		return
+	}
+	if !filter(r.URL.Query(), "orgId", OrgId) {
+		licenses = nil
+	}
+	s.writeItems(w, r, len(licenses), func(from, to int) interface{} { return licenses[from:to] })
+}
+
+// serveRoles handles /roles.
+func (s *Server) serveRoles(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+		return
+	}
+	if id == "" {
+		s.writeItems(w, r, len(s.roles), func(from, to int) interface{} { return s.roles[from:to] })
+		return
+	}
+	for _, role := range s.roles {
+		if role.Id == id {
+			writeJson(w, http.StatusOK, role)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Role not found.")
+}
+
+// serveEvents handles /events.  Events are listed newest first.
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+		return
+	}
+	if id != "" {
+		for _, event := range s.events {
+			if event.Id == id {
+				writeJson(w, http.StatusOK, event)
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, "Event not found.")
+		return
+	}
+	query := r.URL.Query()
+	from, to := query.Get("from"), query.Get("to")
+	var events []api.Event
+	for i := len(s.events) - 1; i >= 0; i-- {
+		event := s.events[i]
+		if filter(query, "resource", event.Resource) && filter(query, "type", event.Type) &&
+			filter(query, "actorId", event.ActorId) &&
+			(from == "" || event.Created >= from) && (to == "" || event.Created < to) {
+			events = append(events, event)
+		}
+	}
+	s.writeItems(w, r, len(events), func(from, to int) interface{} { return events[from:to] })
+}
+
+// serveContents handles /contents, the files attached to messages.
+func (s *Server) serveContents(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+		return
+	}
+	c, ok := s.contents[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "File not found.")
+		return
+	}
+	if c.contentType != "" {
+		w.Header().Set("Content-Type", c.contentType)
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": c.name}))
+	w.Write(c.data)
+}
+
+// findPerson returns the person with personId, or else with personEmail.  A
+// person with an unknown email is added, as the service invites them.  It
+// writes an error response if there's no such person.
+func (s *Server) findPerson(w http.ResponseWriter, personId, personEmail string) (api.People, bool) {
+	if personId != "" {
+		if i := s.personIndex(personId); i >= 0 {
+			return s.people[i], true
+		}
+		writeError(w, http.StatusNotFound, "Person not found.")
+		return api.People{}, false
+	}
+	if personEmail == "" {
+		writeError(w, http.StatusBadRequest, "personId or personEmail is required.")
+		return api.People{}, false
+	}
+	return s.personByEmail(personEmail), true
+}
+
+func (s *Server) addPerson(email, displayName string) api.People {
+	person := api.People{Id: s.newId("person"), Emails: []string{email}, DisplayName: displayName,
+		OrgId: OrgId, Created: s.now()}
+	s.people = append(s.people, person)
+	return person
+}
+
+// personByEmail returns the person with email, who's added if needed.
+func (s *Server) personByEmail(email string) api.People {
+	for _, p := range s.people {
+		if strings.EqualFold(p.Emails[0], email) {
+			return p
+		}
+	}
+	return s.addPerson(email, strings.SplitN(email, "@", 2)[0])
+}
+
+// addRoom adds a room with the authenticated user as its only member.
+func (s *Server) addRoom(title, roomType, teamId string) api.Room {
+	created := s.now()
+	room := api.Room{Id: s.newId("room"), Title: title, Type: roomType, TeamId: teamId,
+		CreatorId: s.me.Id, Created: created, LastActivity: created}
+	s.rooms = append(s.rooms, room)
+	s.addMembership(room.Id, s.me, teamId != "")
+	return room
+}
+
+// directRoom returns the direct room of the authenticated user with person,
+// which is added if needed.
+func (s *Server) directRoom(person api.People) api.Room {
+	for _, room := range s.rooms {
+		if room.Type != "direct" {
+			continue
+		}
+		for _, ms := range s.memberships {
+			if ms.RoomId == room.Id && ms.PersonId == person.Id {
+				return room
+			}
+		}
+	}
+	room := s.addRoom(person.DisplayName, "direct", "")
+	s.addMembership(room.Id, person, false)
+	return room
+}
+
+func (s *Server) deleteRoom(id string) {
+	if i := s.roomIndex(id); i >= 0 {
+		s.rooms = append(s.rooms[:i], s.rooms[i+1:]...)
+	}
+	var msgs []api.Message
+	for _, msg := range s.messages {
+		if msg.RoomId != id {
+			msgs = append(msgs, msg)
+		}
+	}
+	s.messages = msgs
+	var mss []api.Membership
+	for _, ms := range s.memberships {
+		if ms.RoomId != id {
+			mss = append(mss, ms)
+		}
+	}
+	s.memberships = mss
+}
+
+func (s *Server) addMembership(roomId string, person api.People, moderator bool) api.Membership {
+	ms := api.Membership{Id: s.newId("membership"), RoomId: roomId, PersonId: person.Id,
+		PersonEmail: person.Emails[0], PersonDisplayName: person.DisplayName, IsModerator: moderator,
+		Created: s.now()}
+	s.memberships = append(s.memberships, ms)
+	s.addEvent("memberships", "created", s.me.Id, ms)
+	return ms
+}
+
+// addMessage adds msg, setting its id and creation time, and updates the
+// last activity of its room.
+func (s *Server) addMessage(msg api.Message) api.Message {
+	msg.Id = s.newId("message")
+	msg.Created = s.now()
+	if msg.Text == "" {
+		msg.Text = msg.Markdown
+	}
+	s.messages = append(s.messages, msg)
+	s.addEvent("messages", "created", msg.PersonId, msg)
+	if i := s.roomIndex(msg.RoomId); i >= 0 {
+		s.rooms[i].LastActivity = msg.Created
+	}
+	return msg
+}
+
+// addTeam adds a team with a general room of the same name, with the
+// authenticated user as moderator.
+func (s *Server) addTeam(name string) api.Team {
+	team := api.Team{Id: s.newId("team"), Name: name, CreatorId: s.me.Id, Created: s.now()}
+	s.teams = append(s.teams, team)
+	s.addTeamMembership(team.Id, s.me, true)
+	s.addRoom(name, "group", team.Id)
+	return team
+}
+
+func (s *Server) addTeamMembership(teamId string, person api.People, moderator bool) api.TeamMembership {
+	tm := api.TeamMembership{Id: s.newId("teammembership"), TeamId: teamId, PersonId: person.Id,
+		PersonEmail: person.Emails[0], PersonDisplayName: person.DisplayName, IsModerator: moderator,
+		Created: s.now()}
+	s.teamMemberships = append(s.teamMemberships, tm)
+	return tm
+}
+
+// addEvent records an event about data, a message or membership.
+func (s *Server) addEvent(resource, eventType, actorId string, data interface{}) {
+	raw, _ := json.Marshal(data)
+	s.events = append(s.events, api.Event{Id: s.newId("event"), Resource: resource, Type: eventType,
+		ActorId: actorId, OrgId: OrgId, Created: s.now(), Data: raw})
+}
+
+func (s *Server) personIndex(id string) int {
+	for i, p := range s.people {
+		if p.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Server) roomIndex(id string) int {
+	for i, room := range s.rooms {
+		if room.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Server) messageIndex(id string) int {
+	for i, msg := range s.messages {
+		if msg.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Server) membershipIndex(id string) int {
+	for i, ms := range s.memberships {
+		if ms.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Server) teamIndex(id string) int {
+	for i, team := range s.teams {
+		if team.Id == id {
+			return i
+		}
+	}
+	return -1
+}
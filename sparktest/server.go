@@ -0,0 +1,427 @@
+// Package sparktest provides a fake Cisco Spark API for tests and offline
+// development.  A Server runs in process (on an httptest.Server) and keeps
+// rooms, messages, people, memberships, teams and events in memory.  Lists are paged
+// with Link headers like the real service, and errors (401, 429, 5XX) can be
+// injected to test how clients cope with them.
+//
+//	server := sparktest.NewServer()
+//	defer server.Close()
+//	room := server.AddRoom("ops")
+//	client, _ := util.NewClient(server.Config())
+package sparktest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tdeckers/sparkcli/api"
+	"github.com/tdeckers/sparkcli/util"
+)
+
+const (
+	// Token is the access token the server accepts initially.
+	Token = "sparktest-token"
+	// RefreshToken is the refresh token the server accepts for new access
+	// tokens.
+	RefreshToken = "sparktest-refresh-token"
+	// MeEmail is the email of the authenticated user.
+	MeEmail = "me@example.com"
+	// OrgId is the organization of all people, named OrgName.
+	OrgId   = "sparktest-org"
+	OrgName = "Sparktest"
+	// defaultMax is the page size when a request doesn't give max.
+	defaultMax = 100
+)
+
+// Server is a fake Cisco Spark API.  Its methods are safe for concurrent use,
+// also while requests are being served.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	me       api.People
+	token    string
+	seq      int
+	clock    time.Time
+	requests []string
+	failures []*failure
+	// pageSize caps the number of items per page, if it's larger than 0.
+	pageSize int
+
+	people          []api.People
+	rooms           []api.Room
+	messages        []api.Message // oldest first
+	memberships     []api.Membership
+	teams           []api.Team
+	teamMemberships []api.TeamMembership
+	actions         []api.AttachmentAction
+	licenses        []api.License
+	roles           []api.Role
+	events          []api.Event        // oldest first
+	contents        map[string]content // by id
+}
+
+// content is an attached file.
+type content struct {
+	name        string
+	contentType string
+	data        []byte
+}
+
+// failure is an injected error.
+type failure struct {
+	method string
+	path   string
+	status int
+	count  int
+}
+
+// NewServer starts a Server with only the authenticated user (MeEmail), in
+// organization OrgId without licenses or roles.  The
+// caller must Close it.
+func NewServer() *Server {
+	s := &Server{
+		token:    Token,
+		clock:    time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC),
+		contents: map[string]content{},
+	}
+	s.me = s.addPerson(MeEmail, "Me")
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Config returns a configuration for clients of the server.
+func (s *Server) Config() *util.Configuration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &util.Configuration{
+		BaseUrl:      s.URL,
+		AccessToken:  s.token,
+		RefreshToken: RefreshToken,
+		ClientId:     "sparktest",
+		ClientSecret: "sparktest-secret",
+	}
+}
+
+// Me returns the authenticated user.
+func (s *Server) Me() api.People {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.me
+}
+
+// AddPerson adds a person, who can then be found by email or name.
+func (s *Server) AddPerson(email, displayName string) api.People {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addPerson(email, displayName)
+}
+
+// SetStatus sets the presence status of the person with email, e.g. active
+// or DoNotDisturb, and makes now their last activity.
+func (s *Server) SetStatus(email, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.personIndex(s.personByEmail(email).Id)
+	s.people[i].Status = status
+	s.people[i].LastActivity = s.now()
+}
+
+// AddRoom adds a group room with the authenticated user and the people with
+// the given emails as members.
+func (s *Server) AddRoom(title string, members ...string) api.Room {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	room := s.addRoom(title, "group", "")
+	for _, email := range members {
+		s.addMembership(room.Id, s.personByEmail(email), false)
+	}
+	return room
+}
+
+// AddMessage adds a message to a room, as posted by the person with the given
+// email.
+func (s *Server) AddMessage(roomId, email, text string) api.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	person := s.personByEmail(email)
+	return s.addMessage(api.Message{RoomId: roomId, Text: text, PersonId: person.Id, PersonEmail: email})
+}
+
+// AddAttachmentAction adds a submission of the card of a message, as made by
+// the person with the given email.
+func (s *Server) AddAttachmentAction(messageId, email string, inputs map[string]interface{}) api.AttachmentAction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	action := api.AttachmentAction{Id: s.newId("action"), Type: "submit", MessageId: messageId,
+		Inputs: inputs, PersonId: s.personByEmail(email).Id, Created: s.now()}
+	if i := s.messageIndex(messageId); i >= 0 {
+		action.RoomId = s.messages[i].RoomId
+	}
+	s.actions = append(s.actions, action)
+	return action
+}
+
+// AddLicense adds a license of which total units were bought.  Assigning it
+// to people (see api.People.Licenses) consumes units.
+func (s *Server) AddLicense(name string, total int) api.License {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	license := api.License{Id: s.newId("license"), Name: name, TotalUnits: total}
+	s.licenses = append(s.licenses, license)
+	return license
+}
+
+// AddRole adds an admin role.
+func (s *Server) AddRole(name string) api.Role {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	role := api.Role{Id: s.newId("role"), Name: name}
+	s.roles = append(s.roles, role)
+	return role
+}
+
+// AddTeam adds a team, with its general room, led by the authenticated user.
+func (s *Server) AddTeam(name string) api.Team {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addTeam(name)
+}
+
+// Rooms returns all rooms.
+func (s *Server) Rooms() []api.Room {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]api.Room(nil), s.rooms...)
+}
+
+// Messages returns the messages of a room, oldest first.
+func (s *Server) Messages(roomId string) []api.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var msgs []api.Message
+	for _, msg := range s.messages {
+		if msg.RoomId == roomId {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs
+}
+
+// Memberships returns the memberships of a room.
+func (s *Server) Memberships(roomId string) []api.Membership {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var mss []api.Membership
+	for _, ms := range s.memberships {
+		if ms.RoomId == roomId {
+			mss = append(mss, ms)
+		}
+	}
+	return mss
+}
+
+// Teams returns all teams.
+func (s *Server) Teams() []api.Team {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]api.Team(nil), s.teams...)
+}
+
+// Requests returns the requests served so far, as "METHOD /path?query".
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+// Fail makes the next count requests with method ("" for any) for a path
+// starting with path fail with status.  429 responses ask to retry after a
+// second.
+func (s *Server) Fail(method, path string, status, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, &failure{method: method, path: path, status: status, count: count})
+}
+
+// SetPageSize caps the number of items returned per page, whatever max the
+// request asks for, to test paging with a few items.
+func (s *Server) SetPageSize(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pageSize = n
+}
+
+// ExpireToken makes the server reject the current access token with 401,
+// until a new one is requested with the refresh token.
+func (s *Server) ExpireToken() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = s.newId("token")
+}
+
+// serve handles a request: it records it, checks for injected failures and
+// authentication, and passes it to the handler of the resource.
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+
+	for _, f := range s.failures {
+		if f.count > 0 && (f.method == "" || f.method == r.Method) && strings.HasPrefix(r.URL.Path, f.path) {
+			f.count--
+			if f.status == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "1")
+			}
+			writeError(w, f.status, "Injected failure.")
+			return
+		}
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] == "access_token" {
+		s.serveAccessToken(w, r)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+s.token {
+		writeError(w, http.StatusUnauthorized, "The request requires a valid access token.")
+		return
+	}
+	id := ""
+	if len(parts) > 1 {
+		id = parts[len(parts)-1]
+	}
+	switch {
+	case parts[0] == "people" && len(parts) <= 2:
+		s.servePeople(w, r, id)
+	case parts[0] == "rooms" && len(parts) <= 2:
+		s.serveRooms(w, r, id)
+	case parts[0] == "messages" && len(parts) <= 2:
+		s.serveMessages(w, r, id)
+	case parts[0] == "memberships" && len(parts) <= 2:
+		s.serveMemberships(w, r, id)
+	case parts[0] == "teams" && len(parts) <= 2:
+		s.serveTeams(w, r, id)
+	case parts[0] == "team" && len(parts) >= 2 && parts[1] == "memberships" && len(parts) <= 3:
+		if len(parts) == 2 {
+			id = ""
+		}
+		s.serveTeamMemberships(w, r, id)
+	case parts[0] == "attachment" && len(parts) == 3 && parts[1] == "actions":
+		s.serveAttachmentActions(w, r, id)
+	case parts[0] == "organizations" && len(parts) <= 2:
+		s.serveOrganizations(w, r, id)
+	case parts[0] == "licenses" && len(parts) <= 2:
+		s.serveLicenses(w, r, id)
+	case parts[0] == "roles" && len(parts) <= 2:
+		s.serveRoles(w, r, id)
+	case parts[0] == "events" && len(parts) <= 2:
+		s.serveEvents(w, r, id)
+	case parts[0] == "contents" && len(parts) == 2:
+		s.serveContents(w, r, id)
+	default:
+		writeError(w, http.StatusNotFound, "The requested resource could not be found.")
+	}
+}
+
+// serveAccessToken hands out a new access token for the refresh token.
+func (s *Server) serveAccessToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+		return
+	}
+	if r.FormValue("grant_type") != "refresh_token" || r.FormValue("refresh_token") != RefreshToken {
+		writeError(w, http.StatusUnauthorized, "Invalid grant.")
+		return
+	}
+	s.token = s.newId("token")
+	writeJson(w, http.StatusOK, util.Tokens{AccessToken: s.token, AccessExpires: 1209600})
+}
+
+// newId returns a new unique id.
+func (s *Server) newId(kind string) string {
+	s.seq++
+	return fmt.Sprintf("%s-%d", kind, s.seq)
+}
+
+// now returns the creation time for a new item.  Time moves a second per
+// item, so items are ordered by creation time.
+func (s *Server) now() string {
+	s.clock = s.clock.Add(time.Second)
+	return s.clock.Format("2006-01-02T15:04:05.000Z")
+}
+
+// page returns the range of the items to return for a list of total items,
+// from the max and offset parameters.  When there are more items, it adds a
+// Link header for the next page.
+func (s *Server) page(w http.ResponseWriter, r *http.Request, total int) (from, to int) {
+	max, err := strconv.Atoi(r.URL.Query().Get("max"))
+	if err != nil || max <= 0 {
+		max = defaultMax
+	}
+	if s.pageSize > 0 && max > s.pageSize {
+		max = s.pageSize
+	}
+	from, _ = strconv.Atoi(r.URL.Query().Get("offset"))
+	if from < 0 || from > total {
+		from = total
+	}
+	to = from + max
+	if to >= total {
+		return from, total
+	}
+	next := *r.URL
+	query := next.Query()
+	query.Set("offset", strconv.Itoa(to))
+	next.RawQuery = query.Encode()
+	next.Scheme = "http"
+	next.Host = r.Host
+	w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+	return from, to
+}
+
+// writeItems writes a page of a list of total items as {"items": [...]}.
+// slice returns the items in a range of the list.
+func (s *Server) writeItems(w http.ResponseWriter, r *http.Request, total int, slice func(from, to int) interface{}) {
+	from, to := s.page(w, r, total)
+	writeJson(w, http.StatusOK, map[string]interface{}{"items": slice(from, to)})
+}
+
+func writeJson(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error response like the ones of the service.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJson(w, status, map[string]interface{}{
+		"message":    message,
+		"errors":     []map[string]string{{"description": message}},
+		"trackingId": "sparktest",
+	})
+}
+
+// decode reads the json body of r into v, and writes an error response if
+// that fails.
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid json: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// filter reports whether value matches the query parameter name of r, which
+// matches anything when it's missing.
+func filter(query url.Values, name, value string) bool {
+	want := query.Get(name)
+	return want == "" || strings.EqualFold(want, value)
+}
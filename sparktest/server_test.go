@@ -0,0 +1,89 @@
+package sparktest
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/tdeckers/sparkcli/util"
+)
+
+func get(t *testing.T, s *Server, path, token string) *http.Response {
+	req, _ := http.NewRequest("GET", s.URL+path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return res
+}
+
+func TestServerAuth(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	if res := get(t, s, "/people/me", Token); res.StatusCode != 200 {
+		t.Errorf("GET with token = %d, want 200", res.StatusCode)
+	}
+	s.ExpireToken()
+	if res := get(t, s, "/people/me", Token); res.StatusCode != 401 {
+		t.Errorf("GET with expired token = %d, want 401", res.StatusCode)
+	}
+
+	res, err := http.PostForm(s.URL+"/access_token", url.Values{
+		"grant_type": {"refresh_token"}, "refresh_token": {RefreshToken}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatalf("POST /access_token = %d, want 200", res.StatusCode)
+	}
+	token := s.Config().AccessToken
+	if res := get(t, s, "/people/me", token); res.StatusCode != 200 {
+		t.Errorf("GET with refreshed token = %d, want 200", res.StatusCode)
+	}
+}
+
+func TestServerPaging(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	for _, title := range []string{"a", "b", "c"} {
+		s.AddRoom(title)
+	}
+	tests := []struct {
+		name     string
+		path     string
+		wantNext string
+	}{
+		{"first page", "/rooms?max=2", "/rooms?max=2&offset=2"},
+		{"last page", "/rooms?max=2&offset=2", ""},
+		{"all", "/rooms", ""},
+	}
+	for _, tt := range tests {
+		next := util.NextLink(get(t, s, tt.path, Token))
+		if tt.wantNext != "" {
+			tt.wantNext = s.URL + tt.wantNext
+		}
+		if next != tt.wantNext {
+			t.Errorf("%q. next link = %q, want %q", tt.name, next, tt.wantNext)
+		}
+	}
+}
+
+func TestServerFail(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.Fail("GET", "/rooms", 429, 2)
+
+	var codes []string
+	for i := 0; i < 3; i++ {
+		res := get(t, s, "/rooms", Token)
+		codes = append(codes, res.Status[:3]+"/"+res.Header.Get("Retry-After"))
+	}
+	if got := strings.Join(codes, " "); got != "429/1 429/1 200/" {
+		t.Errorf("responses = %s, want two failures then success", got)
+	}
+}
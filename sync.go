@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tdeckers/sparkcli/api"
+)
+
+// Kinds of sync actions.
+const (
+	syncAdd       = "add"
+	syncRemove    = "remove"
+	syncModerator = "moderator"
+)
+
+// rosterEntry is a person on a roster.
+type rosterEntry struct {
+	Email     string
+	Moderator bool
+}
+
+// syncAction is a change needed to bring a room in line with a roster.
+type syncAction struct {
+	Kind      string `json:"action"`
+	Email     string `json:"email"`
+	Moderator bool   `json:"moderator"`
+	// MembershipId is the membership to remove or update.
+	MembershipId string `json:"membershipId,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// syncReport summarizes a sync.
+type syncReport struct {
+	RoomId    string       `json:"roomId"`
+	DryRun    bool         `json:"dryRun"`
+	Unchanged int          `json:"unchanged"`
+	Actions   []syncAction `json:"actions"`
+}
+
+// readRoster reads a roster from a csv file.  The first column holds the
+// email address, an optional second column whether the person is a moderator
+// (true/yes/1).  A header line is skipped; it's recognized by a first column
+// which isn't an email address.
+func readRoster(r io.Reader) ([]rosterEntry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+	var roster []rosterEntry
+	seen := map[string]bool{}
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		email := strings.TrimSpace(record[0])
+		if email == "" {
+			continue
+		}
+		if !strings.Contains(email, "@") {
+			if line == 1 {
+				continue // header
+			}
+			return nil, fmt.Errorf("line %d: '%s' is not an email address", line, email)
+		}
+		if seen[strings.ToLower(email)] {
+			continue
+		}
+		seen[strings.ToLower(email)] = true
+		entry := rosterEntry{Email: email}
+		if len(record) > 1 {
+			switch strings.ToLower(strings.TrimSpace(record[1])) {
+			case "true", "yes", "y", "1", "moderator":
+				entry.Moderator = true
+			}
+		}
+		roster = append(roster, entry)
+	}
+	return roster, nil
+}
+
+// loadRoster reads the roster in the csv file at path.
+func loadRoster(path string) ([]rosterEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	roster, err := readRoster(f)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read %s: %s", path, err)
+	}
+	return roster, nil
+}
+
+// planSync compares the memberships of a room with a roster.  It returns the
+// actions to add people missing from the room and to update moderator flags,
+// and, when remove is set, to remove people not on the roster.  The
+// membership of self (the email of the current user) is never removed.  It
+// also returns the number of memberships left as they are.
+func planSync(members []api.Membership, roster []rosterEntry, remove bool, self string) ([]syncAction, int) {
+	current := map[string]api.Membership{}
+	for _, ms := range members {
+		current[strings.ToLower(ms.PersonEmail)] = ms
+	}
+	var actions []syncAction
+	unchanged := 0
+	wanted := map[string]bool{}
+	for _, entry := range roster {
+		key := strings.ToLower(entry.Email)
+		wanted[key] = true
+		ms, ok := current[key]
+		switch {
+		case !ok:
+			actions = append(actions, syncAction{Kind: syncAdd, Email: entry.Email, Moderator: entry.Moderator})
+		case ms.IsModerator != entry.Moderator:
+			actions = append(actions, syncAction{Kind: syncModerator, Email: ms.PersonEmail,
+				Moderator: entry.Moderator, MembershipId: ms.Id})
+		default:
+			unchanged++
+		}
+	}
+	var extra []syncAction
+	for key, ms := range current {
+		if wanted[key] {
+			continue
+		}
+		if !remove || strings.EqualFold(ms.PersonEmail, self) {
+			unchanged++
+			continue
+		}
+		extra = append(extra, syncAction{Kind: syncRemove, Email: ms.PersonEmail,
+			Moderator: ms.IsModerator, MembershipId: ms.Id})
+	}
+	sort.Slice(extra, func(i, j int) bool { return extra[i].Email < extra[j].Email })
+	return append(actions, extra...), unchanged
+}
+
+// Syncer brings the memberships of a room in line with a roster.
+type Syncer struct {
+	memberService api.MemberAPI
+	peopleService api.PeopleAPI
+	// concurrency is the maximum number of calls made at the same time.
+	concurrency int
+}
+
+// newSyncer creates a Syncer making at most concurrency calls at a time.
+func newSyncer(spark *api.Spark, concurrency int) *Syncer {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Syncer{
+		memberService: spark.Memberships,
+		peopleService: spark.People,
+		concurrency:   concurrency,
+	}
+}
+
+// Sync compares the room with the roster and, unless dryRun is set, applies
+// the changes.  Failed actions are reported with their error; the returned
+// error is only set when the room can't be compared at all.  When ctx is
+// cancelled, no more changes are started.
+func (s *Syncer) Sync(ctx context.Context, roomId string, roster []rosterEntry, remove bool, dryRun bool) (*syncReport, error) {
+	members, err := s.memberService.ListAll(ctx, roomId)
+	if err != nil {
+		return nil, err
+	}
+	self := ""
+	if remove {
+		me, err := s.peopleService.GetMe(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(me.Emails) > 0 {
+			self = me.Emails[0]
+		}
+	}
+	actions, unchanged := planSync(*members, roster, remove, self)
+	report := &syncReport{RoomId: roomId, DryRun: dryRun, Unchanged: unchanged, Actions: actions}
+	if dryRun {
+		return report, nil
+	}
+
+	sem := make(chan struct{}, s.concurrency)
+	var wg sync.WaitGroup
+	for i := range report.Actions {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			report.Actions[i].Error = "interrupted"
+			continue
+		}
+		wg.Add(1)
+		go func(action *syncAction) {
+			defer func() { <-sem; wg.Done() }()
+			err := retryRateLimited(ctx, func() error { return s.apply(ctx, roomId, *action) })
+			if err != nil {
+				action.Error = err.Error()
+			}
+		}(&report.Actions[i])
+	}
+	wg.Wait()
+	return report, nil
+}
+
+// apply makes a single change to the room.
+func (s *Syncer) apply(ctx context.Context, roomId string, action syncAction) error {
+	var err error
+	switch action.Kind {
+	case syncAdd:
+		_, err = s.memberService.Create(ctx, roomId, "", action.Email, action.Moderator)
+	case syncModerator:
+		_, err = s.memberService.Update(ctx, action.MembershipId, action.Moderator)
+	case syncRemove:
+		err = s.memberService.Delete(ctx, action.MembershipId)
+	default:
+		err = errors.New("unknown action " + action.Kind)
+	}
+	return err
+}
+
+// printSyncReport prints the actions of report and a summary line.
+func printSyncReport(report *syncReport) {
+	counts := map[string]int{}
+	failed := 0
+	for _, action := range report.Actions {
+		status := "done"
+		if report.DryRun {
+			status = "planned"
+		}
+		if action.Error != "" {
+			status = "failed: " + action.Error
+			failed++
+		} else {
+			counts[action.Kind]++
+		}
+		detail := ""
+		if action.Kind != syncRemove {
+			detail = fmt.Sprintf(" (moderator: %t)", action.Moderator)
+		}
+		fmt.Printf("%-9s %s%s  %s\n", action.Kind, action.Email, detail, status)
+	}
+	verb := "Added %d, removed %d, updated %d, unchanged %d"
+	if report.DryRun {
+		verb = "Would add %d, remove %d, update %d, unchanged %d"
+	}
+	fmt.Printf(verb, counts[syncAdd], counts[syncRemove], counts[syncModerator], report.Unchanged)
+	if failed > 0 {
+		fmt.Printf(", failed %d", failed)
+	}
+	fmt.Println(".")
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tdeckers/sparkcli/api"
+	"github.com/tdeckers/sparkcli/api/apimock"
+)
+
+func Test_readRoster(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		want    []rosterEntry
+		wantErr bool
+	}{
+		{"plain", "a@x.com\nb@x.com\n",
+			[]rosterEntry{{"a@x.com", false}, {"b@x.com", false}}, false},
+		{"header", "email,moderator\na@x.com,true\nb@x.com,no\n",
+			[]rosterEntry{{"a@x.com", true}, {"b@x.com", false}}, false},
+		{"duplicates and blanks", "a@x.com\n\n# comment\nA@x.com,yes\n",
+			[]rosterEntry{{"a@x.com", false}}, false},
+		{"not an email", "a@x.com\nbob\n", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := readRoster(strings.NewReader(tt.csv))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q. readRoster() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. readRoster() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_planSync(t *testing.T) {
+	members := []api.Membership{
+		{Id: "1", PersonEmail: "keep@x.com"},
+		{Id: "2", PersonEmail: "Mod@x.com"},
+		{Id: "3", PersonEmail: "extra@x.com"},
+		{Id: "4", PersonEmail: "bot@x.com", IsModerator: true},
+	}
+	roster := []rosterEntry{{"keep@x.com", false}, {"mod@x.com", true}, {"new@x.com", true}}
+	tests := []struct {
+		name          string
+		remove        bool
+		want          []syncAction
+		wantUnchanged int
+	}{
+		{"keep extras", false, []syncAction{
+			{Kind: syncModerator, Email: "Mod@x.com", Moderator: true, MembershipId: "2"},
+			{Kind: syncAdd, Email: "new@x.com", Moderator: true},
+		}, 3},
+		{"remove extras", true, []syncAction{
+			{Kind: syncModerator, Email: "Mod@x.com", Moderator: true, MembershipId: "2"},
+			{Kind: syncAdd, Email: "new@x.com", Moderator: true},
+			{Kind: syncRemove, Email: "extra@x.com", MembershipId: "3"},
+		}, 2},
+	}
+	for _, tt := range tests {
+		got, unchanged := planSync(members, roster, tt.remove, "bot@x.com")
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. planSync() = %v, want %v", tt.name, got, tt.want)
+		}
+		if unchanged != tt.wantUnchanged {
+			t.Errorf("%q. planSync() unchanged = %d, want %d", tt.name, unchanged, tt.wantUnchanged)
+		}
+	}
+}
+
+func TestSyncerSync(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	record := func(call string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, call)
+	}
+	spark := apimock.NewSpark()
+	spark.Memberships = &apimock.MemberAPI{
+		ListAllFunc: func(ctx context.Context, roomId string) (*[]api.Membership, error) {
+			return &[]api.Membership{
+				{Id: "1", PersonEmail: "me@x.com", IsModerator: true},
+				{Id: "2", PersonEmail: "old@x.com"},
+			}, nil
+		},
+		CreateFunc: func(ctx context.Context, roomId, personId, personEmail string, isModerator bool) (*api.Membership, error) {
+			if personEmail == "bad@x.com" {
+				return nil, errors.New("not allowed")
+			}
+			record("create " + personEmail)
+			return &api.Membership{}, nil
+		},
+		DeleteFunc: func(ctx context.Context, id string) error {
+			record("delete " + id)
+			return nil
+		},
+	}
+	spark.People = &apimock.PeopleAPI{
+		GetMeFunc: func(ctx context.Context) (*api.People, error) {
+			return &api.People{Emails: []string{"me@x.com"}}, nil
+		},
+	}
+
+	roster := []rosterEntry{{"me@x.com", true}, {"new@x.com", false}, {"bad@x.com", false}}
+	report, err := newSyncer(spark, 2).Sync(context.Background(), "room", roster, true, false)
+	if err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+	sort.Strings(calls)
+	if want := []string{"create new@x.com", "delete 2"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("Sync() made calls %q, want %q", calls, want)
+	}
+	var failed []string
+	for _, action := range report.Actions {
+		if action.Error != "" {
+			failed = append(failed, action.Email+": "+action.Error)
+		}
+	}
+	if want := []string{"bad@x.com: not allowed"}; !reflect.DeepEqual(failed, want) {
+		t.Errorf("Sync() failed %q, want %q", failed, want)
+	}
+}
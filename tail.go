@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/tdeckers/sparkcli/api"
+	"github.com/tdeckers/sparkcli/util"
+)
+
+const (
+	// tailPollMax is the number of messages requested on every poll.
+	tailPollMax = 50
+	// tailMinInterval and tailMaxInterval bound the time between polls.  The
+	// interval starts at the minimum, grows while the room is quiet and drops
+	// back as soon as new messages arrive.
+	tailMinInterval = 2 * time.Second
+	tailMaxInterval = time.Minute
+)
+
+// tailCursor identifies the last message printed for a room.  It's persisted
+// so a restarted tail continues where the previous one stopped.
+type tailCursor struct {
+	Id      string
+	Created string
+}
+
+// Tail prints new messages of a room as they're posted.
+type Tail struct {
+	msgService api.MessageAPI
+	roomId     string
+	json       bool
+	cursor     tailCursor
+}
+
+// newTail creates a Tail for room roomId.  When asJson is set, every message
+// is printed as a single line of json.
+func newTail(msgService api.MessageAPI, roomId string, asJson bool) *Tail {
+	return &Tail{msgService: msgService, roomId: roomId, json: asJson}
+}
+
+// Run prints the last lines messages.  When follow is set, it starts after
+// the last message of the previous follow run instead, if there was one, and
+// keeps polling for new messages until ctx is cancelled.  Only follow runs
+// save where they stopped.
+func (t *Tail) Run(ctx context.Context, lines int, follow bool) error {
+	if !follow || !util.LoadCache(t.cacheName(), 0, &t.cursor) {
+		if err := t.last(ctx, lines); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if !follow {
+			return nil
+		}
+		t.save()
+	}
+	err := pollLoop(ctx, tailMinInterval, func(wait time.Duration) (time.Duration, bool, error) {
+		count, err := t.poll(ctx)
+		if count > 0 {
+			t.save()
+			return tailMinInterval, false, err
+		}
+		return backoff(wait), false, err
+	})
+	if ctx.Err() != nil {
+		return nil // interrupted, the cursor is saved
+	}
+	return err
+}
+
+// last prints the last lines messages, oldest first, and moves the cursor to
+// the latest message.  When lines is more than a single request returns, it
+// pages back through older messages.
+func (t *Tail) last(ctx context.Context, lines int) error {
+	var msgs []api.Message
+	err := t.msgService.ListPages(ctx, t.roomId, min(max(lines, 1), tailPollMax), func(page []api.Message) bool {
+		msgs = append(msgs, page...)
+		return len(msgs) < lines
+	})
+	if err != nil {
+		return err
+	}
+	if len(msgs) > 0 {
+		t.cursor = tailCursor{Id: msgs[0].Id, Created: msgs[0].Created}
+	}
+	for i := min(lines, len(msgs)) - 1; i >= 0; i-- {
+		t.print(msgs[i])
+	}
+	return nil
+}
+
+// poll requests the latest messages and prints the ones that weren't printed
+// before, oldest first.  It returns the number of messages printed.
+func (t *Tail) poll(ctx context.Context) (int, error) {
+	msgs, err := t.msgService.List(ctx, t.roomId, tailPollMax)
+	if err != nil {
+		return 0, err
+	}
+	var fresh []api.Message
+	for _, msg := range *msgs {
+		if msg.Id == t.cursor.Id || msg.Created < t.cursor.Created {
+			break
+		}
+		fresh = append(fresh, msg)
+	}
+	if t.cursor.Id != "" && len(fresh) == len(*msgs) && len(fresh) == tailPollMax {
+		slog.Warn(fmt.Sprintf("More than %d new messages, older ones are skipped.", tailPollMax))
+	}
+	for i := len(fresh) - 1; i >= 0; i-- {
+		t.print(fresh[i])
+	}
+	if len(fresh) > 0 {
+		t.cursor = tailCursor{Id: fresh[0].Id, Created: fresh[0].Created}
+	}
+	return len(fresh), nil
+}
+
+// save persists the cursor, for the next follow run.
+func (t *Tail) save() {
+	if err := util.SaveCache(t.cacheName(), t.cursor); err != nil {
+		slog.Warn("Failed to save position", "err", err)
+	}
+}
+
+func (t *Tail) print(msg api.Message) {
+	if t.json {
+		line, err := json.Marshal(msg)
+		if err != nil {
+			slog.Error(err.Error())
+			return
+		}
+		fmt.Println(string(line))
+	} else {
+		fmt.Printf("[%v] %v: %v\n", msg.Created, msg.PersonEmail, msg.Text)
+	}
+}
+
+// cacheName returns the name of the cache entry holding the cursor.
+func (t *Tail) cacheName() string {
+	return "tail-" + t.roomId
+}
+
+// pollLoop calls poll until it's done, ctx is cancelled (returning ctx.Err())
+// or it fails with a client error (returning that error).  poll gets the time
+// waited before the call, and returns the time to wait before the next one.
+// When rate limited, it waits as long as Cisco Spark asks, and on server and
+// network errors it backs off and keeps going.
+func pollLoop(ctx context.Context, wait time.Duration, poll func(wait time.Duration) (time.Duration, bool, error)) error {
+	for {
+		next, done, err := poll(wait)
+		if err != nil {
+			apiErr, ok := err.(*util.APIError)
+			switch {
+			case ctx.Err() != nil:
+				return ctx.Err()
+			case ok && apiErr.StatusCode == 429:
+				next = apiErr.RetryAfter
+				if next == 0 {
+					next = tailMaxInterval
+				}
+				slog.Warn("Rate limited, retrying", "wait", next)
+			case ok && apiErr.StatusCode < 500:
+				return err
+			default: // server or network error, back off and try again
+				slog.Warn("Polling failed, retrying", "err", err)
+				next = backoff(wait)
+			}
+		} else if done {
+			return nil
+		}
+		wait = next
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// backoff returns the next, longer poll interval.
+func backoff(interval time.Duration) time.Duration {
+	interval *= 2
+	if interval > tailMaxInterval {
+		interval = tailMaxInterval
+	}
+	return interval
+}
@@ -0,0 +1,63 @@
+package util
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+)
+
+// cacheDir is the directory, relative to the home directory, where sparkcli
+// keeps cached API results and other local state.
+const cacheDir = ".sparkcli"
+
+// HomeDir returns the home directory of the current user: $HOME when it's
+// set, so it can be moved (e.g. in tests), or else the one of the account.
+func HomeDir() string {
+	if home := os.Getenv("HOME"); home != "" {
+		return home
+	}
+	if u, err := user.Current(); err == nil {
+		return u.HomeDir
+	}
+	return ""
+}
+
+// cachePath returns the location of the cache file for name.
+func cachePath(name string) string {
+	return filepath.Join(HomeDir(), cacheDir, name+".json")
+}
+
+// LoadCache reads the cached value for name into v.  It returns false when
+// there is no cached value, or when it's older than maxAge.  A maxAge of 0
+// accepts cached values of any age.
+func LoadCache(name string, maxAge time.Duration, v interface{}) bool {
+	path := cachePath(name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if maxAge > 0 && time.Since(info.ModTime()) > maxAge {
+		return false
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, v) == nil
+}
+
+// SaveCache stores v as the cached value for name.
+func SaveCache(name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	path := cachePath(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0600)
+}
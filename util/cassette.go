@@ -0,0 +1,212 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Environment variables switching the client to record or replay mode.  Both
+// hold the path of a cassette file.
+const (
+	RecordEnv = "SPARKCLI_RECORD"
+	ReplayEnv = "SPARKCLI_REPLAY"
+)
+
+// cassette holds the requests sent and the responses received during a run,
+// with secrets scrubbed.  It's stored as json:
+//
+//	{"interactions": [
+//	  {"request": {"method": "GET", "url": "/rooms", ...},
+//	   "response": {"status": 200, "body": "{\"items\": [...]}", ...}}
+//	]}
+type cassette struct {
+	Interactions []interaction `json:"interactions"`
+}
+
+type interaction struct {
+	Request  recordedRequest  `json:"request"`
+	Response recordedResponse `json:"response"`
+	// used is set when the interaction was replayed.
+	used bool
+}
+
+// recordedRequest is a request in a cassette.  Url holds the path and query
+// only, so a cassette replays against any host.  Text bodies are kept in
+// Body, others (uploads, downloads) in Data.
+type recordedRequest struct {
+	Method string      `json:"method"`
+	Url    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+	Data   []byte      `json:"data,omitempty"`
+}
+
+type recordedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+	Data   []byte      `json:"data,omitempty"`
+}
+
+// withCassette wraps next in a transport that records to or replays from the
+// cassette named by SPARKCLI_RECORD or SPARKCLI_REPLAY.  Without either it
+// returns next.
+func withCassette(next http.RoundTripper) (http.RoundTripper, error) {
+	record, replay := os.Getenv(RecordEnv), os.Getenv(ReplayEnv)
+	switch {
+	case record != "" && replay != "":
+		return nil, errors.New(RecordEnv + " and " + ReplayEnv + " can't be used together")
+	case record != "":
+		return &recordingTransport{next: next, path: record}, nil
+	case replay != "":
+		data, err := ioutil.ReadFile(replay)
+		if err != nil {
+			return nil, err
+		}
+		t := &replayingTransport{path: replay}
+		if err := json.Unmarshal(data, &t.cassette); err != nil {
+			return nil, fmt.Errorf("Failed to read cassette %s: %s", replay, err)
+		}
+		return t, nil
+	}
+	return next, nil
+}
+
+// recordingTransport sends requests through next, and saves every request
+// and response to the cassette at path.  The cassette is rewritten after
+// every response, so it's complete whenever the program ends.  Response
+// bodies are read in full before they're returned.
+type recordingTransport struct {
+	next http.RoundTripper
+	path string
+
+	mu       sync.Mutex
+	cassette cassette
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+	recorded := interaction{
+		Request: recordedRequest{Method: req.Method, Url: Redact(req.URL.RequestURI()),
+			Header: scrubHeader(req.Header)},
+		Response: recordedResponse{Status: res.StatusCode, Header: scrubHeader(res.Header)},
+	}
+	recorded.Request.Body, recorded.Request.Data = scrubBody(req.Header, body)
+	recorded.Response.Body, recorded.Response.Data = scrubBody(res.Header, data)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cassette.Interactions = append(t.cassette.Interactions, recorded)
+	out, err := json.MarshalIndent(t.cassette, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(t.path, out, 0600); err != nil {
+		return nil, fmt.Errorf("Failed to write cassette: %s", err)
+	}
+	return res, nil
+}
+
+// replayingTransport answers requests from a cassette, without sending them.
+// A request gets the response of the first unused interaction with the same
+// method and url; it fails if there's none.
+type replayingTransport struct {
+	path string
+
+	mu       sync.Mutex
+	cassette cassette
+}
+
+func (t *replayingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	url := Redact(req.URL.RequestURI())
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range t.cassette.Interactions {
+		recorded := &t.cassette.Interactions[i]
+		if recorded.used || recorded.Request.Method != req.Method || recorded.Request.Url != url {
+			continue
+		}
+		recorded.used = true
+		body := recorded.Response.Data
+		if body == nil {
+			body = []byte(recorded.Response.Body)
+		}
+		header := recorded.Response.Header
+		if header == nil {
+			header = http.Header{}
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", recorded.Response.Status, http.StatusText(recorded.Response.Status)),
+			StatusCode:    recorded.Response.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(bytes.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("no response for %s %s in cassette %s", req.Method, url, t.path)
+}
+
+// scrubHeader returns a copy of header with secrets redacted.
+func scrubHeader(header http.Header) http.Header {
+	if len(header) == 0 {
+		return nil
+	}
+	scrubbed := http.Header{}
+	for name, values := range header {
+		for _, value := range values {
+			if secretKeys[strings.ToLower(name)] {
+				value = redacted
+			} else {
+				value = Redact(value)
+			}
+			scrubbed.Add(name, value)
+		}
+	}
+	return scrubbed
+}
+
+// scrubBody returns a body for a cassette: text with secrets redacted, or
+// else the raw data.
+func scrubBody(header http.Header, body []byte) (string, []byte) {
+	if len(body) == 0 {
+		return "", nil
+	}
+	if isText(header.Get("Content-Type")) && utf8.Valid(body) {
+		return Redact(string(body)), nil
+	}
+	return "", body
+}
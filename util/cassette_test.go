@@ -0,0 +1,113 @@
+package util
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCassetteRecordReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rooms":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"items": [{"id": "1", "title": "ops"}]}`))
+		case "/access_token":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"access_token": "new-secret-token"}`))
+		case "/contents/1":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte{0x89, 'P', 'N', 'G', 0xff})
+		}
+	}))
+	config := &Configuration{BaseUrl: server.URL, AccessToken: "secret-token"}
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	ctx := context.Background()
+	type result struct {
+		Items []struct {
+			Title string `json:"title"`
+		} `json:"items"`
+	}
+
+	t.Setenv(RecordEnv, path)
+	client, err := NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := client.NewGetRequest("/rooms?max=10")
+	if _, err := client.Do(ctx, req, &result{}); err != nil {
+		t.Fatalf("Do() while recording: error = %v", err)
+	}
+	req, _ = client.NewPostRequest("/access_token", map[string]string{"refresh_token": "refresh-secret"})
+	if _, err := client.Do(ctx, req, nil); err != nil {
+		t.Fatalf("Do() while recording: error = %v", err)
+	}
+	req, _ = client.NewGetRequest(server.URL + "/contents/1")
+	if _, err := client.Do(ctx, req, nil); err != nil {
+		t.Fatalf("Do() while recording: error = %v", err)
+	}
+	server.Close()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"secret-token", "refresh-secret", "new-secret-token"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("cassette holds %q:\n%s", secret, data)
+		}
+	}
+
+	t.Setenv(RecordEnv, "")
+	t.Setenv(ReplayEnv, path)
+	client, err = NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rooms result
+	req, _ = client.NewGetRequest("/rooms?max=10")
+	if _, err := client.Do(ctx, req, &rooms); err != nil || len(rooms.Items) != 1 || rooms.Items[0].Title != "ops" {
+		t.Errorf("Do() while replaying = %+v, %v, want room ops", rooms, err)
+	}
+	req, _ = client.NewGetRequest("http://example.com/contents/1")
+	res, err := client.DoStream(ctx, req)
+	if err != nil {
+		t.Fatalf("DoStream() while replaying: error = %v", err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if string(body) != "\x89PNG\xff" {
+		t.Errorf("DoStream() while replaying = %q, want the recorded bytes", body)
+	}
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"replayed already", "/rooms?max=10"},
+		{"not recorded", "/rooms?max=20"},
+	}
+	for _, tt := range tests {
+		req, _ = client.NewGetRequest(tt.path)
+		if _, err := client.Do(ctx, req, nil); err == nil {
+			t.Errorf("%q. Do() while replaying: error = nil, want no response", tt.name)
+		}
+	}
+}
+
+func TestCassetteEnv(t *testing.T) {
+	t.Setenv(RecordEnv, "a.json")
+	t.Setenv(ReplayEnv, "b.json")
+	if _, err := NewClient(&Configuration{}); err == nil {
+		t.Errorf("NewClient() with %s and %s: error = nil, want an error", RecordEnv, ReplayEnv)
+	}
+	t.Setenv(RecordEnv, "")
+	t.Setenv(ReplayEnv, filepath.Join(t.TempDir(), "missing.json"))
+	if _, err := NewClient(&Configuration{}); err == nil {
+		t.Errorf("NewClient() with a missing cassette: error = nil, want an error")
+	}
+}
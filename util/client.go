@@ -2,20 +2,26 @@ package util
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
-	"os"
-	"mime/multipart"
-	"path/filepath"
-	"io"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
 	userAgent = "spark-cli"
+	// defaultConnectTimeout limits setting up a connection, when the
+	// configuration doesn't set ConnectTimeout.
+	defaultConnectTimeout = 10 * time.Second
+	// defaultResponseTimeout limits waiting for a response after sending a
+	// request, when the configuration doesn't set ResponseTimeout.
+	defaultResponseTimeout = 60 * time.Second
 )
 
 type Client struct {
@@ -24,16 +30,54 @@ type Client struct {
 	userAgent string
 
 	config *Configuration
+
+	// dryRun receives the requests that aren't sent in dry-run mode.
+	dryRun io.Writer
+}
+
+// NewClient creates a Client for the service configured in config, using the
+// network settings (proxy, certificates, timeouts) in config.  There's no
+// limit on the total duration of a request, as uploads, downloads and long
+// listings take as long as they take; connecting and waiting for the response
+// are limited by the ConnectTimeout and ResponseTimeout settings.  Requests
+// are cancelled through the context passed to Do.
+//
+// When SPARKCLI_RECORD is set, requests and responses are saved to the
+// cassette file it names; when SPARKCLI_REPLAY is set, responses are served
+// from that cassette without going to the network.
+func NewClient(config *Configuration) (*Client, error) {
+	transport, err := newTransport(config)
+	if err != nil {
+		return nil, err
+	}
+	next, err := withCassette(transport)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{client: &http.Client{Transport: &tracingTransport{next: next}},
+		userAgent: userAgent, config: config}
+	return c, nil
 }
 
-func NewClient(config *Configuration) *Client {
-	c := &Client{client: http.DefaultClient, userAgent: userAgent, config: config}
-	return c
+// postForm posts form to path (relative to the BaseUrl), e.g. to request
+// tokens.  Unlike Do it doesn't authenticate or check the status code.
+func (c *Client) postForm(ctx context.Context, path string, form url.Values) (*http.Response, error) {
+	req, err := http.NewRequest("POST", c.config.BaseUrl+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.client.Do(req.WithContext(ctx))
 }
 
+// NewRequest creates a request for path, relative to the BaseUrl.  Path can
+// also be an absolute url, e.g. for links to files.
 func (c *Client) NewRequest(method string, path string, body interface{}) (*http.Request, error) {
 	// concat base url and request url
-	reqUrl, err := url.Parse(c.config.BaseUrl + path)
+	if !strings.HasPrefix(path, "https://") && !strings.HasPrefix(path, "http://") {
+		path = c.config.BaseUrl + path
+	}
+	reqUrl, err := url.Parse(path)
 	if err != nil {
 		return nil, err
 	}
@@ -46,7 +90,6 @@ func (c *Client) NewRequest(method string, path string, body interface{}) (*http
 			return nil, err
 		}
 		bodyBuffer = bytes.NewBuffer(bodyJson)
-		log.Printf("Sending: %s", bodyBuffer)
 		// Create request with body
 		req, err = http.NewRequest(method, reqUrl.String(), bodyBuffer)
 		if err != nil {
@@ -60,50 +103,35 @@ func (c *Client) NewRequest(method string, path string, body interface{}) (*http
 			return nil, err
 		}
 	}
-	// Add other headers (that apply to all requests)
-	req.Header.Set("Authorization", "Bearer "+c.config.AccessToken)
+	c.authorize(req)
 	return req, nil
 }
 
-func (c *Client) NewFileUploadRequest(path string, roomId string, fileLocation string) (*http.Request, error) {
-	// concat base url and request url
-	reqUrl, err := url.Parse(c.config.BaseUrl + path)
-	if err != nil {
-		return nil, err
+// authorize adds the access token to req when it goes to the BaseUrl.  Other
+// urls, e.g. links in messages, don't get the token.
+func (c *Client) authorize(req *http.Request) {
+	base, err := url.Parse(c.config.BaseUrl)
+	if err != nil || req.URL.Scheme != base.Scheme || req.URL.Host != base.Host {
+		req.Header.Del("Authorization")
+		return
 	}
-
-	file, err := os.Open(fileLocation)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-	part, err := writer.CreateFormFile("files", filepath.Base(fileLocation))
-	if err != nil {
-		return nil, err
-	}
-	_, err = io.Copy(part, file)
-	_ = writer.WriteField("roomId", roomId)
-
-	err = writer.Close()
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequest("POST", reqUrl.String(), body)
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-
-	// Add other headers (that apply to all requests)
 	req.Header.Set("Authorization", "Bearer "+c.config.AccessToken)
-	return req, nil
+}
+
+// NewFileUploadRequest creates a request posting a single file to roomId.
+func (c *Client) NewFileUploadRequest(path string, roomId string, fileLocation string) (*http.Request, error) {
+	fields := map[string]string{"roomId": roomId}
+	return c.NewMultipartRequest(path, fields, []string{fileLocation}, nil)
 }
 
 func (c *Client) NewGetRequest(path string) (*http.Request, error) {
 	return c.NewRequest("GET", path, nil)
 }
 
+func (c *Client) NewHeadRequest(path string) (*http.Request, error) {
+	return c.NewRequest("HEAD", path, nil)
+}
+
 func (c *Client) NewPostRequest(path string, body interface{}) (*http.Request, error) {
 	return c.NewRequest("POST", path, body)
 }
@@ -120,61 +148,127 @@ func (c *Client) NewFilePostRequest(path string, roomId string, fileLocation str
 	return c.NewFileUploadRequest(path, roomId, fileLocation)
 }
 
-func (c *Client) Do(req *http.Request, to interface{}) (*http.Response, error) {
-	var res *http.Response
-	res, err := c.client.Do(req)
-
+// Do sends req and decodes the json response into to (unless to is nil).  The
+// request is cancelled when ctx is done.
+func (c *Client) Do(ctx context.Context, req *http.Request, to interface{}) (*http.Response, error) {
+	res, err := c.DoStream(ctx, req)
 	if err != nil {
 		return nil, err
 	}
 	defer res.Body.Close()
+	if to != nil {
+		decoder := json.NewDecoder(res.Body)
+		err = decoder.Decode(&to)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// DoStream sends req and returns the response with the body unread, e.g. to
+// stream a file to disk.  The caller must close the response body.
+func (c *Client) DoStream(ctx context.Context, req *http.Request) (*http.Response, error) {
+	req = req.WithContext(ctx)
+	if c.dryRun != nil && isMutating(req) {
+		err := printRequest(c.dryRun, req)
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		if err != nil {
+			return nil, err
+		}
+		return nil, ErrDryRun
+	}
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
 	// If 401, let's try to refresh tokens and try again.
 	if res.StatusCode == 401 {
+		res.Body.Close()
 		login := Login{config: c.config, client: c}
-		login.RefreshToken()
+		login.RefreshToken(ctx)
 		// Update the request with new AccessToken.
-		req.Header.Set("Authorization", "Bearer "+c.config.AccessToken)
+		c.authorize(req)
+		// The body was consumed by the first attempt, get a fresh copy.
+		if req.GetBody != nil {
+			req.Body, err = req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
 
 		res, err = c.client.Do(req)
 		if err != nil {
 			return nil, err
 		}
-		defer res.Body.Close()
 	}
 	err = checkStatusOk(res)
 	if err != nil {
-		log.Printf("Status: %s", res.Status)
+		res.Body.Close()
 		return nil, err
 	}
-	if to != nil {
-		decoder := json.NewDecoder(res.Body)
-		err = decoder.Decode(&to)
-		if err != nil {
-			return nil, err
-		}
-	}
 	return res, nil
 }
 
+// APIError is returned by Do when the service responds with a status code
+// outside the 2XX range.
+type APIError struct {
+	StatusCode int
+	Status     string
+	// Body holds the response body, which for some codes (e.g. 401) has more
+	// details:
+	// {
+	//	"message": "Failed to create room.",
+	//	"errors": [
+	//		{
+	//			"description": "Failed to create room."
+	//		}
+	//	],
+	//	"trackingId": "NA_f6e19aac-3a72-46d2-88ec-643f4d12fcbd"
+	//}
+	Body string
+	// RetryAfter is the delay the service asks for before retrying, as sent
+	// with 429 (Too Many Requests) responses.  It's 0 if none was given.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return e.Status + "\n" + e.Body
+}
+
 // error if status code is not in 2XX range
 func checkStatusOk(res *http.Response) error {
-	if 200 < res.StatusCode && res.StatusCode > 299 {
-		// Read the body.  For some HTTP codes there's more info (e.g. 401)
-		// {
-		//	"message": "Failed to create room.",
-		//	"errors": [
-		//		{
-		//			"description": "Failed to create room."
-		//		}
-		//	],
-		//	"trackingId": "NA_f6e19aac-3a72-46d2-88ec-643f4d12fcbd"
-		//}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		apiErr := &APIError{StatusCode: res.StatusCode, Status: res.Status}
+		if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil {
+			apiErr.RetryAfter = time.Duration(seconds) * time.Second
+		}
 		body, err := ioutil.ReadAll(res.Body)
 		if err != nil {
 			return errors.New(res.Status + " - " + err.Error())
-		} else {
-			return errors.New(res.Status + "\n" + string(body))
 		}
+		apiErr.Body = string(body)
+		return apiErr
 	}
 	return nil
 }
+
+// NextLink returns the url of the next page of results, as given in the Link
+// header of res, or "" if there are no more pages.
+func NextLink(res *http.Response) string {
+	for _, header := range res.Header["Link"] {
+		for _, link := range strings.Split(header, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.Trim(strings.TrimSpace(parts[0]), "<>")
+			for _, param := range parts[1:] {
+				param = strings.Replace(strings.TrimSpace(param), " ", "", -1)
+				if param == `rel="next"` || param == "rel=next" {
+					return target
+				}
+			}
+		}
+	}
+	return ""
+}
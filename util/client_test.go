@@ -1,8 +1,14 @@
 package util
 
 import (
+	"context"
+	"fmt"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func Test_checkStatusOk(t *testing.T) {
@@ -14,7 +20,13 @@ func Test_checkStatusOk(t *testing.T) {
 		args    args
 		wantErr bool
 	}{
-	// TODO: Add test cases.
+		{"ok", args{response(200, "")}, false},
+		{"no content", args{response(204, "")}, false},
+		{"bad request", args{response(400, `{"message": "Bad"}`)}, true},
+		{"unauthorized", args{response(401, "")}, true},
+		{"rate limited", args{response(429, "")}, true},
+		{"server error", args{response(500, "")}, true},
+		{"redirect", args{response(302, "")}, true},
 	}
 	for _, tt := range tests {
 		if err := checkStatusOk(tt.args.res); (err != nil) != tt.wantErr {
@@ -22,3 +34,75 @@ func Test_checkStatusOk(t *testing.T) {
 		}
 	}
 }
+
+// response returns a response with status code and body.
+func response(code int, body string) *http.Response {
+	return &http.Response{StatusCode: code, Status: fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(body))}
+}
+
+func TestCheckStatusOkDetails(t *testing.T) {
+	res := response(429, `{"message": "Too many"}`)
+	res.Header.Set("Retry-After", "3")
+	err := checkStatusOk(res)
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		t.Fatalf("checkStatusOk() error = %v, want an APIError", err)
+	}
+	if apiErr.StatusCode != 429 || apiErr.RetryAfter != 3*time.Second || apiErr.Body != `{"message": "Too many"}` {
+		t.Errorf("checkStatusOk() = %+v, want 429 with body, retry after 3s", apiErr)
+	}
+}
+
+func TestClientDoCancel(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client, err := NewClient(&Configuration{BaseUrl: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	req, _ := client.NewGetRequest("/rooms")
+	start := time.Now()
+	if _, err := client.Do(ctx, req, nil); err == nil {
+		t.Errorf("Do() with cancelled context: error = nil, want an error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Do() returned after %s, want it to stop when cancelled", elapsed)
+	}
+}
+
+func TestNewRequestAuthorization(t *testing.T) {
+	client, err := NewClient(&Configuration{BaseUrl: "https://api.example.com/v1", AccessToken: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"relative", "/rooms", "Bearer secret"},
+		{"absolute", "https://api.example.com/v1/contents/1", "Bearer secret"},
+		{"other host", "https://files.example.net/contents/1", ""},
+		{"other scheme", "http://api.example.com/v1/contents/1", ""},
+	}
+	for _, tt := range tests {
+		req, err := client.NewGetRequest(tt.path)
+		if err != nil {
+			t.Fatalf("%q. NewGetRequest() error = %v", tt.name, err)
+		}
+		if got := req.Header.Get("Authorization"); got != tt.want {
+			t.Errorf("%q. NewGetRequest() Authorization = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
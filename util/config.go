@@ -4,7 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"errors"
-	"log"
+	"fmt"
 	"net/url"
 	"os"
 	"os/user"
@@ -39,12 +39,31 @@ type Configuration struct {
 	RefreshToken   string
 	RefreshExpires float64
 	DefaultRoomId  string
-}
-
-var configFile string
-
-func init() {
-	configFile = findConfigFile()
+	// ConnectTimeout and ResponseTimeout (in seconds) limit connecting to
+	// the service and waiting for a response.  0 means the default.
+	ConnectTimeout  int
+	ResponseTimeout int
+	// ProxyUrl is the proxy for all requests, e.g. http://proxy:3128.  When
+	// empty, the HTTPS_PROXY environment variable is used.
+	ProxyUrl string
+	// CaFile holds extra root certificates (PEM), e.g. of a proxy inspecting
+	// traffic.
+	CaFile string
+	// ClientCertFile and ClientKeyFile (PEM) authenticate sparkcli to
+	// services asking for a client certificate.
+	ClientCertFile string
+	ClientKeyFile  string
+	// TlsMinVersion is the minimum TLS version: 1.0, 1.1, 1.2 (default) or
+	// 1.3.
+	TlsMinVersion string
+	// MaxIdleConns, MaxIdleConnsPerHost and MaxConnsPerHost size the
+	// connection pool.  0 means the default.
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+
+	// path is the file the configuration was loaded from, and is saved to.
+	path string
 }
 
 // instance is a singleton that ensures we're only using one copy of the
@@ -61,13 +80,17 @@ func GetConfiguration() *Configuration {
 	return instance
 }
 
-// Load the Configuration from the config file.
+// Load the Configuration from the config file (see findConfigFile).
 func (c *Configuration) Load() {
-	// TODO:change this to log to stderr, actuall all logs to stderr?
-	//log.Printf("Using configuration at %s\n", configFile)
+	c.LoadFile(findConfigFile())
+}
 
-	if _, err := toml.DecodeFile(configFile, &c); err != nil {
-		log.Fatalln("Failed to open file", err)
+// LoadFile loads the Configuration from the config file at path.  Save writes
+// it back to the same file.
+func (c *Configuration) LoadFile(path string) {
+	c.path = path
+	if _, err := toml.DecodeFile(path, &c); err != nil {
+		exit("Failed to open file %s", err)
 		return
 	}
 
@@ -120,15 +143,25 @@ func findConfigFile() string {
 	return "sparkcli.toml"
 }
 
-// Save writes c to the config file on disk.
+// Path returns the config file c was loaded from, or "" if it wasn't loaded
+// from a file.
+func (c Configuration) Path() string {
+	return c.path
+}
+
+// Save writes c to the config file it was loaded from.  A configuration that
+// wasn't loaded from a file (e.g. one set up in code) is only kept in memory.
 func (c Configuration) Save() {
+	if c.path == "" {
+		return
+	}
 	buf := new(bytes.Buffer)
 	if err := toml.NewEncoder(buf).Encode(c); err != nil {
-		log.Fatalln("Failed to encode config", err)
+		exit("Failed to encode config %s", err)
 	}
-	f, err := os.Create(configFile)
+	f, err := os.Create(c.path)
 	if err != nil {
-		log.Fatalln("Failed to create file", err)
+		exit("Failed to create file %s", err)
 		return
 	}
 
@@ -164,7 +197,7 @@ func (c Configuration) checkAccessToken() bool {
 
 // PrintAuthUrl writes the OAuth authorize URL to stdout.
 func (c Configuration) PrintAuthUrl() {
-	log.Printf("Visit \n%s/authorize?%s",
+	fmt.Printf("Visit \n%s/authorize?%s\n",
 		c.BaseUrl,
 		url.Values{"response_type": {"code"},
 			"client_id":    {c.ClientId},
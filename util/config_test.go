@@ -0,0 +1,37 @@
+package util
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigurationLoadSave(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.toml")
+	second := filepath.Join(dir, "second.toml")
+	ioutil.WriteFile(first, []byte(`AccessToken = "one"`), 0600)
+	ioutil.WriteFile(second, []byte(`AccessToken = "two"`+"\nBaseUrl = \"http://localhost\"\n"), 0600)
+
+	var c1, c2 Configuration
+	c1.LoadFile(first)
+	c2.LoadFile(second)
+	if c1.AccessToken != "one" || c1.BaseUrl != baseUrl || c2.AccessToken != "two" || c2.BaseUrl != "http://localhost" {
+		t.Fatalf("LoadFile() = %+v, %+v, want the settings of each file", c1, c2)
+	}
+	c1.AccessToken = "updated"
+	c1.Save()
+
+	var got1, got2 Configuration
+	got1.LoadFile(first)
+	got2.LoadFile(second)
+	if got1.AccessToken != "updated" || got2.AccessToken != "two" {
+		t.Errorf("Save() wrote %q and %q, want only the first file updated", got1.AccessToken, got2.AccessToken)
+	}
+
+	// Not loaded from a file, so there's nothing to save to.
+	(&Configuration{AccessToken: "memory"}).Save()
+	if matches, _ := filepath.Glob(filepath.Join(dir, "*")); len(matches) != 2 {
+		t.Errorf("Save() of an unloaded configuration wrote files: %v", matches)
+	}
+}
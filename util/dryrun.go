@@ -0,0 +1,58 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ErrDryRun is returned for requests that would change something while the
+// client is in dry-run mode.  Those requests are printed instead of sent.
+var ErrDryRun = errors.New("Request not sent (dry run).")
+
+// SetDryRun makes c print the requests that would change something (all but
+// GET and HEAD) to w, instead of sending them.  A nil w sends them again.
+func (c *Client) SetDryRun(w io.Writer) {
+	c.dryRun = w
+}
+
+// isMutating reports whether req changes something on the service.
+func isMutating(req *http.Request) bool {
+	return req.Method != "GET" && req.Method != "HEAD"
+}
+
+// printRequest writes the method, url, headers and body of req to w, with
+// secrets redacted.  Multipart bodies (file uploads) are summarized.
+func printRequest(w io.Writer, req *http.Request) error {
+	fmt.Fprintf(w, "%s %s\n", req.Method, Redact(req.URL.String()))
+	var names []string
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range req.Header[name] {
+			if name == "Authorization" {
+				value = "Bearer " + redacted
+			}
+			fmt.Fprintf(w, "%s: %s\n", name, value)
+		}
+	}
+	if req.Body == nil {
+		return nil
+	}
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/") {
+		_, err := fmt.Fprintf(w, "\n<multipart body, %s>\n", FormatSize(req.ContentLength))
+		return err
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "\n%s\n", Redact(string(body)))
+	return err
+}
@@ -0,0 +1,47 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClientDryRun(t *testing.T) {
+	sent := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent++
+		w.Write([]byte(`{"items":[]}`))
+	}))
+	defer server.Close()
+
+	out := &bytes.Buffer{}
+	client, err := NewClient(&Configuration{BaseUrl: server.URL, AccessToken: "secret-token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetDryRun(out)
+
+	req, _ := client.NewGetRequest("/rooms")
+	if _, err := client.Do(context.Background(), req, nil); err != nil {
+		t.Errorf("GET in dry run: error = %v, want nil", err)
+	}
+	req, _ = client.NewPostRequest("/rooms", map[string]string{"title": "new room"})
+	if _, err := client.Do(context.Background(), req, nil); err != ErrDryRun {
+		t.Errorf("POST in dry run: error = %v, want ErrDryRun", err)
+	}
+	if sent != 1 {
+		t.Errorf("requests sent = %d, want 1", sent)
+	}
+	printed := out.String()
+	for _, want := range []string{"POST " + server.URL + "/rooms", "Authorization: Bearer <redacted>", `{"title":"new room"}`} {
+		if !strings.Contains(printed, want) {
+			t.Errorf("printed request %q doesn't contain %q", printed, want)
+		}
+	}
+	if strings.Contains(printed, "secret-token") {
+		t.Errorf("printed request %q contains the access token", printed)
+	}
+}
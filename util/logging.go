@@ -0,0 +1,96 @@
+package util
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// LevelTrace is the level of the details of HTTP requests and responses
+// (headers and bodies), below slog.LevelDebug which logs a line per request.
+const LevelTrace = slog.LevelDebug - 4
+
+// redacted replaces secrets in log output.
+const redacted = "<redacted>"
+
+// secretKeys are the (lower case) names of log attributes and headers that
+// always hold secrets.
+var secretKeys = map[string]bool{
+	"authorization": true,
+	"cookie":        true,
+	"set-cookie":    true,
+	"token":         true,
+	"access_token":  true,
+	"accesstoken":   true,
+	"refresh_token": true,
+	"refreshtoken":  true,
+	"client_secret": true,
+	"clientsecret":  true,
+	"secret":        true,
+}
+
+// secretPatterns find secrets in text: bearer tokens, json fields and form or
+// query parameters.
+var secretPatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`(?i)\b(bearer\s+)[A-Za-z0-9._~+/=-]+`), "${1}" + redacted},
+	{regexp.MustCompile(`(?i)("(?:access_token|refresh_token|client_secret|secret|code|token)"\s*:\s*")(?:[^"\\]|\\.)*"`),
+		"${1}" + redacted + `"`},
+	{regexp.MustCompile(`(?i)\b((?:access_token|refresh_token|client_secret|code|token)=)[^&\s"]*`), "${1}" + redacted},
+}
+
+// Redact returns s with the secrets it contains (tokens, client secrets,
+// authorization codes) replaced by <redacted>.
+func Redact(s string) string {
+	for _, p := range secretPatterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	return s
+}
+
+// SetupLogging sends log output to w, as text or as json (one object per
+// line), from level on.  Output of the standard log package goes along at
+// info level.  Secrets are redacted from all messages and attributes.
+func SetupLogging(w io.Writer, level slog.Level, json bool) {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr}
+	var handler slog.Handler
+	if json {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+	slog.SetDefault(slog.New(handler))
+}
+
+// redactAttr redacts secrets from a log attribute, and names LevelTrace.
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.LevelKey && len(groups) == 0 {
+		if level, ok := a.Value.Any().(slog.Level); ok && level <= LevelTrace {
+			return slog.String(slog.LevelKey, "TRACE")
+		}
+		return a
+	}
+	if secretKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Redact(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, Redact(err.Error()))
+		}
+	}
+	return a
+}
+
+// exit logs a failure and ends the program.
+func exit(format string, v ...interface{}) {
+	slog.Error(fmt.Sprintf(format, v...))
+	os.Exit(1)
+}
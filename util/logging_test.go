@@ -0,0 +1,83 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Authorization: Bearer abc.DEF-123", "Authorization: Bearer <redacted>"},
+		{`{"access_token":"abc","expires_in":1209600,"refresh_token":"def"}`,
+			`{"access_token":"<redacted>","expires_in":1209600,"refresh_token":"<redacted>"}`},
+		{"grant_type=refresh_token&client_id=id&client_secret=s3cr3t&refresh_token=def",
+			"grant_type=refresh_token&client_id=id&client_secret=<redacted>&refresh_token=<redacted>"},
+		{`{"name":"hook","secret":"x\"y"}`, `{"name":"hook","secret":"<redacted>"}`},
+		{"nothing secret here", "nothing secret here"},
+	}
+	for _, tt := range tests {
+		if got := Redact(tt.in); got != tt.want {
+			t.Errorf("%q. Redact() = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSetupLoggingRedacts(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	out := &bytes.Buffer{}
+	SetupLogging(out, LevelTrace, true)
+	slog.Info("Token refreshed with Bearer abc123", "access_token", "abc123",
+		"err", errors.New("refresh_token=def456 rejected"))
+	slog.Log(context.Background(), LevelTrace, "details")
+	logged := out.String()
+	for _, secret := range []string{"abc123", "def456"} {
+		if strings.Contains(logged, secret) {
+			t.Errorf("log %q contains secret %q", logged, secret)
+		}
+	}
+	if !strings.Contains(logged, `"level":"TRACE"`) {
+		t.Errorf("log %q doesn't name the trace level", logged)
+	}
+}
+
+func TestTracingTransport(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"1","title":"room"}`))
+	}))
+	defer server.Close()
+
+	out := &bytes.Buffer{}
+	SetupLogging(out, LevelTrace, false)
+	client, err := NewClient(&Configuration{BaseUrl: server.URL, AccessToken: "secret-token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := client.NewPostRequest("/rooms", map[string]string{"title": "room"})
+	var room map[string]string
+	if _, err := client.Do(context.Background(), req, &room); err != nil {
+		t.Fatal(err)
+	}
+	if room["title"] != "room" {
+		t.Errorf("decoded response = %v, want the full body after tracing", room)
+	}
+	logged := out.String()
+	if strings.Contains(logged, "secret-token") {
+		t.Errorf("trace %q contains the access token", logged)
+	}
+	for _, want := range []string{`{\"title\":\"room\"}`, "status=200", `{\"id\":\"1\",\"title\":\"room\"}`} {
+		if !strings.Contains(logged, want) {
+			t.Errorf("trace %q doesn't contain %q", logged, want)
+		}
+	}
+}
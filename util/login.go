@@ -1,10 +1,10 @@
 package util
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"log"
-	"net/http"
+	"log/slog"
 	"net/url"
 	"os"
 )
@@ -33,19 +33,19 @@ func NewLogin(config *Configuration, client *Client) Login {
 // it will attempt to use the OAuth integration flow. to obtain an access token
 // based on the provided ClientId, ClientSecret and AuthCode in the
 // configuration.
-func (l Login) Authorize() {
+func (l Login) Authorize(ctx context.Context) {
 	// Check if AccessToken is present
 	tokenPresent := l.config.checkAccessToken()
 	if tokenPresent {
 		// Verify if token works.
-		err := l.test()
+		err := l.test(ctx)
 		if err != nil {
-			l.loginAsIntegration()
+			l.loginAsIntegration(ctx)
 		} else { // Success!
 			return
 		}
 	} else { // AccessToken not present
-		l.loginAsIntegration()
+		l.loginAsIntegration(ctx)
 	}
 }
 
@@ -55,33 +55,33 @@ func (l Login) Authorize() {
 // On successful authentication it will store the AccessToken and RefreshToken
 // in the configuration file for further use.  On failure it will exit the
 // program.
-func (l Login) loginAsIntegration() {
+func (l Login) loginAsIntegration(ctx context.Context) {
 	// Check if client credentials are set.
 	err := l.config.checkClientConfig()
 	if err != nil { // If client credentials are not set...
-		log.Fatalf("Not configured properly: %s", err)
+		exit("Not configured properly: %s", err)
 	}
 	// client credentials properly set, let's continue.
 
-	log.Println("Authorizing...")
+	slog.Info("Authorizing...")
 	// Post form to obtain access token based on authorization code (OAuth)
-	res, err := http.PostForm(l.config.BaseUrl+"/access_token",
+	res, err := l.client.postForm(ctx, "/access_token",
 		url.Values{"grant_type": {"authorization_code"},
 			"client_id":     {l.config.ClientId},
 			"client_secret": {l.config.ClientSecret},
 			"code":          {l.config.AuthCode},
 			"redirect_uri":  {l.config.RedirectUri}})
 	if err != nil {
-		log.Fatal(err)
+		exit("%s", err)
 	}
 	defer res.Body.Close()
 
 	// if 401, reauthorize? or refresh key.
 	if res.StatusCode == 401 {
-		log.Print("Unauthorized (401) - trying to refresh token")
-		l.RefreshToken()
+		slog.Warn("Unauthorized (401) - trying to refresh token")
+		l.RefreshToken(ctx)
 	} else if res.StatusCode != 200 {
-		log.Fatal("Unexpected status code ", res.StatusCode)
+		exit("Unexpected status code %d", res.StatusCode)
 	}
 
 	// Parse json code into Tokens struct
@@ -89,12 +89,9 @@ func (l Login) loginAsIntegration() {
 	tokens := new(Tokens)
 	err = decoder.Decode(&tokens)
 	if err != nil {
-		log.Fatalf("Failed to decode: %s", err)
+		exit("Failed to decode: %s", err)
 	}
 
-	log.Printf("Access token: %s", tokens.AccessToken)
-	log.Printf("Refresh token: %s", tokens.RefreshToken)
-
 	l.storeToken(tokens, false)
 }
 
@@ -103,26 +100,26 @@ func (l Login) loginAsIntegration() {
 // On success, the new AccessToken is written into the configuration
 // file.  The RefreshToken remains the same, its expiry is reset.
 // Note that sparkcli doesn't track token expiry.
-func (l Login) RefreshToken() {
-	log.Print("Refreshing token...")
+func (l Login) RefreshToken(ctx context.Context) {
+	slog.Info("Refreshing token...")
 	// Post form to obtain access token based on refresh token (OAuth)
-	res, err := http.PostForm(l.config.BaseUrl+"/access_token",
+	res, err := l.client.postForm(ctx, "/access_token",
 		url.Values{"grant_type": {"refresh_token"},
 			"client_id":     {l.config.ClientId},
 			"client_secret": {l.config.ClientSecret},
 			"refresh_token": {l.config.RefreshToken}})
 	if err != nil {
-		log.Fatal(err)
+		exit("%s", err)
 	}
 	defer res.Body.Close()
 
 	// if 401, reauthorize?
 	if res.StatusCode == 401 {
-		log.Print("Unauthorized (401)")
+		slog.Error("Unauthorized (401)")
 		l.config.PrintAuthUrl()
 		os.Exit(1)
 	} else if res.StatusCode != 200 {
-		log.Fatal("Unexpected status code ", res.StatusCode)
+		exit("Unexpected status code %d", res.StatusCode)
 	}
 
 	// Parse json code into Tokens struct
@@ -130,12 +127,12 @@ func (l Login) RefreshToken() {
 	tokens := new(Tokens)
 	err = decoder.Decode(&tokens)
 	if err != nil {
-		log.Fatalf("Failed to decode: %s", err)
+		exit("Failed to decode: %s", err)
 	}
 
 	l.storeToken(tokens, true)
 
-	log.Printf("Successfully refreshed token.")
+	slog.Info("Successfully refreshed token.")
 }
 
 // storeToken writes tokens to the configuration file.  When refresh
@@ -154,29 +151,29 @@ func (l Login) storeToken(tokens *Tokens, refresh bool) {
 		// typically 90 days
 		l.config.RefreshExpires = tokens.RefreshExpires
 	}
-	log.Println("Saving config")
+	slog.Debug("Saving config")
 	l.config.Save()
 
 }
 
 // test access to the Cisco Spark service to ensure authentication works as
 // expected.  Returns an error if the service request fails.
-func (l Login) test() error {
+func (l Login) test(ctx context.Context) error {
 	req, err := l.client.NewGetRequest("/people/me")
 	if err != nil {
-		log.Fatalf("Error testing connection: %s", err)
+		exit("Error testing connection: %s", err)
 	}
 	var result interface{}
-	res, err := l.client.Do(req, &result)
+	res, err := l.client.Do(ctx, req, &result)
 	if err != nil {
-		log.Fatalf("Error testing connection: %s", err)
+		exit("Error testing connection: %s", err)
 	}
 	if res.StatusCode == 401 {
 		return errors.New("401 Unauthorized")
 	}
 	if res.StatusCode != 200 {
 		// TODO: what should we do in case of another error while testing?
-		log.Printf("Got response code %v while testing.", res.StatusCode)
+		slog.Warn("Unexpected response code while testing", "status", res.StatusCode)
 	}
 	return nil
 }
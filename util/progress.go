@@ -0,0 +1,83 @@
+package util
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// progressInterval limits how often progress is redrawn.
+const progressInterval = 200 * time.Millisecond
+
+// Progress reports the progress of a transfer on stderr.  It's an io.Writer
+// counting the bytes written to it, so it can be combined with the
+// destination using io.MultiWriter or io.TeeReader.  Nothing is printed when
+// stderr isn't a terminal.
+type Progress struct {
+	label   string
+	total   int64 // 0 if unknown
+	done    int64
+	out     io.Writer
+	updated time.Time
+}
+
+// NewProgress creates a Progress for a transfer of total bytes (0 if unknown)
+// described by label.
+func NewProgress(label string, total int64) *Progress {
+	p := &Progress{label: label, total: total}
+	if info, err := os.Stderr.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		p.out = os.Stderr
+	}
+	return p
+}
+
+// Write counts len(b) bytes as transferred.
+func (p *Progress) Write(b []byte) (int, error) {
+	p.Add(int64(len(b)))
+	return len(b), nil
+}
+
+// Add counts n bytes as transferred.
+func (p *Progress) Add(n int64) {
+	p.done += n
+	if time.Since(p.updated) >= progressInterval {
+		p.draw()
+	}
+}
+
+// Finish draws the final state and ends the progress line.
+func (p *Progress) Finish() {
+	if p.out == nil {
+		return
+	}
+	p.draw()
+	fmt.Fprintln(p.out)
+}
+
+func (p *Progress) draw() {
+	p.updated = time.Now()
+	if p.out == nil {
+		return
+	}
+	if p.total > 0 {
+		fmt.Fprintf(p.out, "\r%s  %s / %s (%d%%)", p.label, FormatSize(p.done),
+			FormatSize(p.total), p.done*100/p.total)
+	} else {
+		fmt.Fprintf(p.out, "\r%s  %s", p.label, FormatSize(p.done))
+	}
+}
+
+// FormatSize returns size in bytes in a human readable form, e.g. 1.5 MB.
+func FormatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,127 @@
+package util
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// SplitMessage breaks text into parts of at most limit bytes.  It splits
+// between lines where possible.  When a part ends inside a markdown code block
+// (``` or ~~~), the block is closed at the end of that part and opened again,
+// with the same info string, at the start of the next part.  A block whose
+// fence line is too long to be repeated like that is split as plain text.
+func SplitMessage(text string, limit int) []string {
+	if len(text) <= limit {
+		return []string{text}
+	}
+
+	var parts []string
+	var lines []string // lines of the current part
+	size := 0          // size of lines when joined by newlines
+	base := 0          // number of lines that just reopen a code block
+	fence := ""        // line that opened the current code block, if any
+
+	// carried returns the fence that is closed and reopened where a part
+	// ends inside its block: fence, unless reopening it would leave no room
+	// for the content of the block.
+	carried := func(fence string) string {
+		if fence == "" || len(fence)+len(fenceMarker(fence))+2+utf8.UTFMax > limit {
+			return ""
+		}
+		return fence
+	}
+	add := func(line string) {
+		if len(lines) > 0 {
+			size++
+		}
+		size += len(line)
+		lines = append(lines, line)
+	}
+	flush := func() {
+		part := lines
+		reopen := carried(fence)
+		if reopen != "" {
+			part = append(part, fenceMarker(reopen))
+		}
+		parts = append(parts, strings.Join(part, "\n"))
+		lines, size, base = nil, 0, 0
+		if reopen != "" {
+			add(reopen)
+			base = 1
+		}
+	}
+	// cost returns the space needed to add line to the current part, including
+	// the closing fence if the part would end inside a code block.
+	cost := func(line string, next string) int {
+		n := len(line)
+		if len(lines) > 0 {
+			n++
+		}
+		if next = carried(next); next != "" {
+			n += len(fenceMarker(next)) + 1
+		}
+		return n
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		next := fence
+		if fence == "" && isFence(line) {
+			next = line
+		} else if fence != "" && closesFence(fence, line) {
+			next = ""
+		}
+		if size+cost(line, next) > limit && len(lines) > base {
+			flush()
+		}
+		// Lines that don't fit in a part by themselves are cut up.
+		for size+cost(line, next) > limit {
+			// The cut off part closes the current block, the rest may open one.
+			room := limit - size - max(cost("", fence), cost("", next))
+			cut := room
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			if cut <= 0 {
+				if len(lines) > base {
+					flush()
+					continue
+				}
+				// Only when limit is smaller than a rune.
+				_, cut = utf8.DecodeRuneInString(line)
+			}
+			add(line[:cut])
+			line = line[cut:]
+			flush()
+		}
+		add(line)
+		fence = next
+	}
+	if len(lines) > base {
+		flush()
+	}
+	return parts
+}
+
+// isFence reports whether line opens a markdown code block.
+func isFence(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")
+}
+
+// fenceMarker returns the marker (e.g. ``` or ~~~~) of the fence line.
+func fenceMarker(fence string) string {
+	trimmed := strings.TrimSpace(fence)
+	end := 0
+	for end < len(trimmed) && trimmed[end] == trimmed[0] {
+		end++
+	}
+	return trimmed[:end]
+}
+
+// closesFence reports whether line closes the code block opened by fence.
+func closesFence(fence, line string) bool {
+	marker := fenceMarker(fence)
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, marker) &&
+		strings.Trim(trimmed, marker[:1]) == ""
+}
@@ -0,0 +1,66 @@
+package util
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{"fits", "hello\nworld", 20, []string{"hello\nworld"}},
+		{"lines", "aaaa\nbbbb\ncccc", 9, []string{"aaaa\nbbbb", "cccc"}},
+		{"long line", "aaaaaaaaaa", 4, []string{"aaaa", "aaaa", "aa"}},
+		{"utf8", "ééé", 3, []string{"é", "é", "é"}},
+		{"code block", "text\n```go\nx := 1\ny := 2\n```", 21,
+			[]string{"text\n```go\nx := 1\n```", "```go\ny := 2\n```"}},
+		{"tilde block", "~~~\n1\n2\n3\n~~~\nend", 12,
+			[]string{"~~~\n1\n2\n~~~", "~~~\n3\n~~~", "end"}},
+		{"long fence", "```abcdefg\nx", 11, []string{"```abcdefg", "x"}},
+		{"fence too long to reopen", "aaa\n~~~bbbbbbbbbbbbbb\n", 21, []string{"aaa\n~~~bbbbbbbbbbbbbb", ""}},
+		{"fence too long to reopen at the message limit", "aaa\n```" + strings.Repeat("b", 7432) + "\n", 7439,
+			[]string{"aaa\n```" + strings.Repeat("b", 7432), ""}},
+	}
+	for _, tt := range tests {
+		got := SplitMessage(tt.text, tt.limit)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. SplitMessage() = %q, want %q", tt.name, got, tt.want)
+		}
+		for _, part := range got {
+			if len(part) > tt.limit {
+				t.Errorf("%q. SplitMessage() part %q longer than %d", tt.name, part, tt.limit)
+			}
+		}
+	}
+}
+
+func TestSplitMessageKeepsText(t *testing.T) {
+	text := strings.Repeat("some words on a line\n", 50)
+	got := strings.Join(SplitMessage(text, 100), "\n")
+	if got != text {
+		t.Errorf("SplitMessage() lost text: %q", got)
+	}
+}
+
+func FuzzSplitMessage(f *testing.F) {
+	f.Add("text\n```go\nx := 1\ny := 2\n```", 21)
+	f.Add("aaa\n~~~bbbbbbbbbbbbbb\n", 21)
+	f.Add("```abcdefg\nx", 11)
+	f.Add("ééé\n~~~\néé\n~~~", 5)
+	f.Fuzz(func(t *testing.T, text string, limit int) {
+		if limit < utf8.UTFMax || limit > 2*len(text)+utf8.UTFMax {
+			t.Skip()
+		}
+		for _, part := range SplitMessage(text, limit) {
+			if len(part) > limit {
+				t.Errorf("SplitMessage(%q, %d) part %q longer than %d", text, limit, part, limit)
+			}
+		}
+	})
+}
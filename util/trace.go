@@ -0,0 +1,109 @@
+package util
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxTraceBody limits the part of a body that's logged.
+const maxTraceBody = 64 * 1024
+
+// tracingTransport logs the requests sent through it: a line per request at
+// debug level, and headers and bodies at trace level.  Secrets are redacted.
+type tracingTransport struct {
+	next http.RoundTripper
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	logger := slog.Default()
+	if !logger.Enabled(ctx, slog.LevelDebug) {
+		return t.next.RoundTrip(req)
+	}
+	trace := logger.Enabled(ctx, LevelTrace)
+	if trace {
+		logger.Log(ctx, LevelTrace, "Request", "method", req.Method, "url", req.URL.String(),
+			"headers", traceHeaders(req.Header), "body", requestBody(req))
+	}
+	start := time.Now()
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		logger.Debug("Request failed", "method", req.Method, "url", req.URL.String(),
+			"duration", time.Since(start), "err", err)
+		return nil, err
+	}
+	logger.Debug("Response", "method", req.Method, "url", req.URL.String(),
+		"status", res.StatusCode, "duration", time.Since(start))
+	if trace {
+		logger.Log(ctx, LevelTrace, "Response", "status", res.Status,
+			"headers", traceHeaders(res.Header), "body", responseBody(res))
+	}
+	return res, nil
+}
+
+// traceHeaders formats headers for the log, with secrets redacted.
+func traceHeaders(header http.Header) string {
+	var names []string
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var lines []string
+	for _, name := range names {
+		for _, value := range header[name] {
+			if secretKeys[strings.ToLower(name)] {
+				value = redacted
+			}
+			lines = append(lines, name+": "+value)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isText reports whether a body of contentType is worth logging.
+func isText(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return strings.HasPrefix(mediaType, "text/") || mediaType == "application/json" ||
+		mediaType == "application/x-www-form-urlencoded"
+}
+
+// requestBody returns the body of req for the log, without consuming it.
+func requestBody(req *http.Request) string {
+	if req.Body == nil || req.Body == http.NoBody {
+		return ""
+	}
+	if !isText(req.Header.Get("Content-Type")) || req.GetBody == nil {
+		return "<" + req.Header.Get("Content-Type") + ", " + FormatSize(req.ContentLength) + ">"
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return "<" + err.Error() + ">"
+	}
+	defer body.Close()
+	data, _ := ioutil.ReadAll(io.LimitReader(body, maxTraceBody))
+	return string(data)
+}
+
+// responseBody returns (the start of) the body of res for the log.  The part
+// that's read is put back, so the caller still gets the whole body.
+func responseBody(res *http.Response) string {
+	if !isText(res.Header.Get("Content-Type")) {
+		return "<" + res.Header.Get("Content-Type") + ", " + FormatSize(res.ContentLength) + ">"
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, maxTraceBody))
+	res.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), res.Body), res.Body}
+	if err != nil {
+		return "<" + err.Error() + ">"
+	}
+	return string(data)
+}
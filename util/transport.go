@@ -0,0 +1,107 @@
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// tlsVersions maps the TlsMinVersion settings to TLS versions.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// newTransport creates the transport for all requests (API and token
+// requests) from the network settings in config.
+func newTransport(config *Configuration) (*http.Transport, error) {
+	connectTimeout := defaultConnectTimeout
+	if config.ConnectTimeout > 0 {
+		connectTimeout = time.Duration(config.ConnectTimeout) * time.Second
+	}
+	responseTimeout := defaultResponseTimeout
+	if config.ResponseTimeout > 0 {
+		responseTimeout = time.Duration(config.ResponseTimeout) * time.Second
+	}
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   connectTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   connectTimeout,
+		ResponseHeaderTimeout: responseTimeout,
+		ExpectContinueTimeout: time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       config.MaxConnsPerHost,
+	}
+	if config.MaxIdleConns > 0 {
+		transport.MaxIdleConns = config.MaxIdleConns
+	}
+
+	if config.ProxyUrl != "" {
+		proxy, err := url.Parse(config.ProxyUrl)
+		if err != nil || proxy.Host == "" {
+			return nil, fmt.Errorf("Invalid ProxyUrl '%s'", config.ProxyUrl)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	tlsConfig, err := newTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
+
+// newTLSConfig creates the TLS settings: the minimum version, extra root
+// certificates (e.g. of a proxy inspecting traffic) and a client certificate.
+func newTLSConfig(config *Configuration) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.TlsMinVersion != "" {
+		version, ok := tlsVersions[config.TlsMinVersion]
+		if !ok {
+			return nil, fmt.Errorf("Invalid TlsMinVersion '%s', use 1.0, 1.1, 1.2 or 1.3", config.TlsMinVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	if config.CaFile != "" {
+		pem, err := ioutil.ReadFile(config.CaFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read CaFile: %s", err)
+		}
+		// The certificates are trusted next to the system ones.
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificates found in CaFile %s", config.CaFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if (config.ClientCertFile == "") != (config.ClientKeyFile == "") {
+		return nil, errors.New("ClientCertFile and ClientKeyFile must be set together")
+	}
+	if config.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.ClientCertFile, config.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load client certificate: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,87 @@
+package util
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewClientCaFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "sparkcli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	caPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(caFile, caPem, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		caFile  string
+		wantErr bool
+	}{
+		{"untrusted", "", true},
+		{"trusted with CaFile", caFile, false},
+	}
+	for _, tt := range tests {
+		client, err := NewClient(&Configuration{BaseUrl: server.URL, CaFile: tt.caFile})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, _ := client.NewGetRequest("/people/me")
+		if _, err := client.Do(context.Background(), req, nil); (err != nil) != tt.wantErr {
+			t.Errorf("%q. Do() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNewClientProxyUrl(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.Write([]byte(`{}`))
+	}))
+	defer proxy.Close()
+
+	client, err := NewClient(&Configuration{BaseUrl: "http://api.example.com/v1", ProxyUrl: proxy.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := client.NewGetRequest("/rooms")
+	if _, err := client.Do(context.Background(), req, nil); err != nil {
+		t.Fatal(err)
+	}
+	if proxied != "http://api.example.com/v1/rooms" {
+		t.Errorf("proxy got request for %q, want http://api.example.com/v1/rooms", proxied)
+	}
+}
+
+func TestNewClientInvalidSettings(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Configuration
+	}{
+		{"tls version", Configuration{TlsMinVersion: "1.4"}},
+		{"proxy", Configuration{ProxyUrl: "not a url"}},
+		{"missing ca file", Configuration{CaFile: "/does/not/exist.pem"}},
+		{"cert without key", Configuration{ClientCertFile: "client.pem"}},
+	}
+	for _, tt := range tests {
+		if _, err := NewClient(&tt.config); err == nil {
+			t.Errorf("%q. NewClient() error = nil, want an error", tt.name)
+		}
+	}
+}
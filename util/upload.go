@@ -0,0 +1,205 @@
+package util
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// uploadFile is a file to be sent in a multipart request.
+type uploadFile struct {
+	path        string
+	size        int64
+	contentType string
+}
+
+// NewMultipartRequest creates a POST request with fields and the files at
+// paths (as "files" parts) in a multipart/form-data body.  The body is
+// streamed from disk while the request is sent, so memory use doesn't depend
+// on the size of the files.  When progress is not nil, the file content is
+// also written to it as it's sent, but not again when the body is sent once
+// more (e.g. after refreshing the access token).
+func (c *Client) NewMultipartRequest(path string, fields map[string]string, paths []string, progress io.Writer) (*http.Request, error) {
+	reqUrl, err := url.Parse(c.config.BaseUrl + path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the files up front, so errors are reported before sending.
+	var files []uploadFile
+	for _, p := range paths {
+		file, err := newUploadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	var names []string
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	boundary := multipart.NewWriter(nil).Boundary()
+	// write writes the body to w.  The file content is only written when
+	// content is set, otherwise just its size is counted.
+	write := func(w io.Writer, content bool, progress io.Writer) (int64, error) {
+		counter := &countingWriter{w: w}
+		writer := multipart.NewWriter(counter)
+		if err := writer.SetBoundary(boundary); err != nil {
+			return 0, err
+		}
+		for _, name := range names {
+			if err := writer.WriteField(name, fields[name]); err != nil {
+				return 0, err
+			}
+		}
+		for _, file := range files {
+			part, err := writer.CreatePart(file.header())
+			if err != nil {
+				return 0, err
+			}
+			if !content {
+				counter.n += file.size
+				continue
+			}
+			if err := file.copyTo(part, progress); err != nil {
+				return 0, err
+			}
+		}
+		err := writer.Close()
+		return counter.n, err
+	}
+	length, err := write(ioutil.Discard, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	newBody := func(progress io.Writer) *pipeBody {
+		return newPipeBody(func(w io.Writer) error {
+			_, err := write(w, true, progress)
+			return err
+		})
+	}
+
+	req, err := http.NewRequest("POST", reqUrl.String(), newBody(progress))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = length
+	req.GetBody = func() (io.ReadCloser, error) {
+		return newBody(nil), nil
+	}
+	req.Header.Set("Content-Type", "multipart/form-data; boundary="+boundary)
+
+	c.authorize(req)
+	return req, nil
+}
+
+// newUploadFile checks the file at path and detects its content type, from
+// the extension or else from the content.
+func newUploadFile(path string) (uploadFile, error) {
+	file := uploadFile{path: path}
+	f, err := os.Open(path)
+	if err != nil {
+		return file, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return file, err
+	}
+	if info.IsDir() {
+		return file, fmt.Errorf("%s is a directory", path)
+	}
+	file.size = info.Size()
+	file.contentType = mime.TypeByExtension(filepath.Ext(path))
+	if file.contentType == "" {
+		buf := make([]byte, 512)
+		n, err := io.ReadFull(f, buf)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return file, err
+		}
+		file.contentType = http.DetectContentType(buf[:n])
+	}
+	return file, nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// header returns the part header for the file.
+func (f uploadFile) header() textproto.MIMEHeader {
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="files"; filename="%s"`,
+		quoteEscaper.Replace(filepath.Base(f.path))))
+	h.Set("Content-Type", f.contentType)
+	return h
+}
+
+// copyTo writes the file content to w, and to progress if not nil.
+func (f uploadFile) copyTo(w io.Writer, progress io.Writer) error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if progress != nil {
+		w = io.MultiWriter(w, progress)
+	}
+	n, err := io.Copy(w, file)
+	if err == nil && n != f.size {
+		err = fmt.Errorf("%s changed size while uploading", f.path)
+	}
+	return err
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// pipeBody is a request body produced by a write function.  The function only
+// runs, in its own goroutine, once the body is read: a request that's never
+// sent doesn't leave it blocked.
+type pipeBody struct {
+	write func(w io.Writer) error
+	r     *io.PipeReader
+	w     *io.PipeWriter
+	once  sync.Once
+}
+
+func newPipeBody(write func(w io.Writer) error) *pipeBody {
+	r, w := io.Pipe()
+	return &pipeBody{write: write, r: r, w: w}
+}
+
+func (b *pipeBody) Read(p []byte) (int, error) {
+	b.once.Do(func() {
+		go func() {
+			b.w.CloseWithError(b.write(b.w))
+		}()
+	})
+	return b.r.Read(p)
+}
+
+// Close stops the write function, or makes sure it never starts.
+func (b *pipeBody) Close() error {
+	b.once.Do(func() {})
+	return b.r.Close()
+}
@@ -0,0 +1,127 @@
+package util
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewMultipartRequest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sparkcli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"notes.txt": "some notes",
+		"data":      "<html><body>page</body></html>",
+	}
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("ParseMultipartForm() error = %v", err)
+			return
+		}
+		if got := r.FormValue("roomId"); got != "room1" {
+			t.Errorf("roomId = %q, want %q", got, "room1")
+		}
+		received := r.MultipartForm.File["files"]
+		if len(received) != len(files) {
+			t.Errorf("got %d files, want %d", len(received), len(files))
+			return
+		}
+		for _, fh := range received {
+			f, _ := fh.Open()
+			content, _ := ioutil.ReadAll(f)
+			if string(content) != files[fh.Filename] {
+				t.Errorf("%s content = %q, want %q", fh.Filename, content, files[fh.Filename])
+			}
+			wantType := map[string]string{"notes.txt": "text/plain", "data": "text/html"}[fh.Filename]
+			if got := fh.Header.Get("Content-Type"); !strings.HasPrefix(got, wantType) {
+				t.Errorf("%s content type = %q, want %q", fh.Filename, got, wantType)
+			}
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(&Configuration{BaseUrl: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	progress := &countingWriter{w: ioutil.Discard}
+	req, err := client.NewMultipartRequest("/messages", map[string]string{"roomId": "room1"}, paths, progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Do(context.Background(), req, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(len(files["notes.txt"]) + len(files["data"])); progress.n != want {
+		t.Errorf("progress = %d, want %d", progress.n, want)
+	}
+
+	if _, err := client.NewMultipartRequest("/messages", nil, []string{filepath.Join(dir, "missing")}, nil); err == nil {
+		t.Error("NewMultipartRequest() with missing file, want error")
+	}
+}
+
+func TestNewMultipartRequestRetry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := ioutil.WriteFile(path, []byte("some notes"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/access_token" {
+			w.Write([]byte(`{"access_token": "fresh"}`))
+			return
+		}
+		attempts++
+		ioutil.ReadAll(r.Body)
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(&Configuration{BaseUrl: server.URL, AccessToken: "expired"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	progress := &countingWriter{w: ioutil.Discard}
+	req, err := client.NewMultipartRequest("/messages", nil, []string{path}, progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Do(context.Background(), req, nil); err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 2 || progress.n != int64(len("some notes")) {
+		t.Errorf("sent %d times with progress %d, want 2 times with progress %d", attempts, progress.n, len("some notes"))
+	}
+
+	// A request that's never sent doesn't hold on to its files.
+	req, err = client.NewMultipartRequest("/messages", nil, []string{path}, progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := req.Body.Close(); err != nil {
+		t.Errorf("Body.Close() error = %v", err)
+	}
+	if n, err := req.Body.Read(make([]byte, 10)); n != 0 || err == nil {
+		t.Errorf("Body.Read() after Close() = %d, %v, want an error", n, err)
+	}
+}
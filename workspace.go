@@ -0,0 +1,544 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/tdeckers/sparkcli/api"
+)
+
+// Workspace is the layout of teams, rooms and webhooks as described in a
+// workspace file (toml), which apply brings the account in line with.
+type Workspace struct {
+	Teams    []WorkspaceTeam    `toml:"teams"`
+	Rooms    []WorkspaceRoom    `toml:"rooms"` // rooms outside of teams
+	Webhooks []WorkspaceWebhook `toml:"webhooks"`
+}
+
+// WorkspaceTeam is a team and its rooms.  Members are only managed when
+// members or moderators are listed.
+type WorkspaceTeam struct {
+	Name       string          `toml:"name"`
+	Members    []string        `toml:"members"`
+	Moderators []string        `toml:"moderators"`
+	Rooms      []WorkspaceRoom `toml:"rooms"`
+}
+
+// WorkspaceRoom is a room.  Members are only managed when members or
+// moderators are listed.
+type WorkspaceRoom struct {
+	Title      string   `toml:"title"`
+	Members    []string `toml:"members"`
+	Moderators []string `toml:"moderators"`
+}
+
+// WorkspaceWebhook is a webhook.  Room is the title of a room in the
+// workspace; its id is added to the filter.
+type WorkspaceWebhook struct {
+	Name      string `toml:"name"`
+	TargetUrl string `toml:"targetUrl"`
+	Resource  string `toml:"resource"`
+	Event     string `toml:"event"`
+	Filter    string `toml:"filter"`
+	Room      string `toml:"room"`
+	Secret    string `toml:"secret"`
+}
+
+// loadWorkspace reads and checks the workspace file at path.
+func loadWorkspace(path string) (*Workspace, error) {
+	ws := &Workspace{}
+	md, err := toml.DecodeFile(path, ws)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read %s: %s", path, err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("Failed to read %s: unknown key %s", path, undecoded[0])
+	}
+	if err := ws.check(); err != nil {
+		return nil, fmt.Errorf("Failed to read %s: %s", path, err)
+	}
+	return ws, nil
+}
+
+// check verifies that everything has a name, names are unique and webhooks
+// refer to rooms in the workspace.
+func (ws *Workspace) check() error {
+	titles := map[string]int{}
+	checkRooms := func(rooms []WorkspaceRoom, scope string) error {
+		seen := map[string]bool{}
+		for _, room := range rooms {
+			if room.Title == "" {
+				return errors.New("room without title" + scope)
+			}
+			if seen[room.Title] {
+				return fmt.Errorf("room \"%s\" listed twice%s", room.Title, scope)
+			}
+			seen[room.Title] = true
+			titles[room.Title]++
+		}
+		return nil
+	}
+	teams := map[string]bool{}
+	for _, team := range ws.Teams {
+		if team.Name == "" {
+			return errors.New("team without name")
+		}
+		if teams[team.Name] {
+			return fmt.Errorf("team \"%s\" listed twice", team.Name)
+		}
+		teams[team.Name] = true
+		if err := checkRooms(team.Rooms, fmt.Sprintf(" in team \"%s\"", team.Name)); err != nil {
+			return err
+		}
+	}
+	if err := checkRooms(ws.Rooms, ""); err != nil {
+		return err
+	}
+	hooks := map[string]bool{}
+	for _, hook := range ws.Webhooks {
+		if hook.Name == "" || hook.TargetUrl == "" || hook.Resource == "" || hook.Event == "" {
+			return errors.New("webhooks need a name, targetUrl, resource and event")
+		}
+		if hooks[hook.Name] {
+			return fmt.Errorf("webhook \"%s\" listed twice", hook.Name)
+		}
+		hooks[hook.Name] = true
+		if hook.Room != "" && titles[hook.Room] != 1 {
+			return fmt.Errorf("webhook \"%s\" refers to room \"%s\", which isn't listed exactly once",
+				hook.Name, hook.Room)
+		}
+	}
+	return nil
+}
+
+// workspaceRoster turns the members and moderators of a team or room into a
+// roster.
+func workspaceRoster(members []string, moderators []string) []rosterEntry {
+	var roster []rosterEntry
+	seen := map[string]bool{}
+	for _, email := range moderators {
+		seen[strings.ToLower(email)] = true
+		roster = append(roster, rosterEntry{Email: email, Moderator: true})
+	}
+	for _, email := range members {
+		if !seen[strings.ToLower(email)] {
+			seen[strings.ToLower(email)] = true
+			roster = append(roster, rosterEntry{Email: email})
+		}
+	}
+	return roster
+}
+
+// Plan operations, as shown in the diff.
+const (
+	planCreate  = "+"
+	planUpdate  = "~"
+	planDelete  = "-"
+	planReplace = "-/+"
+)
+
+// planStep is a single change of a workspacePlan.
+type planStep struct {
+	op     string
+	what   string
+	detail string
+	apply  func(ctx context.Context) error
+}
+
+// workspacePlan is the list of changes needed to bring the account in line
+// with a Workspace, in the order they have to be made.
+type workspacePlan struct {
+	steps []planStep
+}
+
+func (p *workspacePlan) add(op, what, detail string, apply func(ctx context.Context) error) {
+	p.steps = append(p.steps, planStep{op: op, what: what, detail: detail, apply: apply})
+}
+
+// count returns the number of resources the plan creates, changes and
+// deletes.  A replacement counts as both a create and a delete.
+func (p *workspacePlan) count() (creates, updates, deletes int) {
+	for _, step := range p.steps {
+		switch step.op {
+		case planCreate:
+			creates++
+		case planUpdate:
+			updates++
+		case planDelete:
+			deletes++
+		case planReplace:
+			creates++
+			deletes++
+		}
+	}
+	return
+}
+
+// Print writes the plan as a diff, followed by a summary.
+func (p *workspacePlan) Print(w io.Writer) {
+	for _, step := range p.steps {
+		fmt.Fprintf(w, "%3s %s", step.op, step.what)
+		if step.detail != "" {
+			fmt.Fprintf(w, ": %s", step.detail)
+		}
+		fmt.Fprintln(w)
+	}
+	creates, updates, deletes := p.count()
+	if len(p.steps) == 0 {
+		fmt.Fprintln(w, "No changes, the workspace is up to date.")
+		return
+	}
+	fmt.Fprintf(w, "\nPlan: %d to add, %d to change, %d to delete.\n", creates, updates, deletes)
+}
+
+// Apply makes the changes in order, and stops at the first failure or when ctx
+// is cancelled.
+func (p *workspacePlan) Apply(ctx context.Context, w io.Writer) error {
+	for _, step := range p.steps {
+		if ctx.Err() != nil {
+			return errors.New("Interrupted, run apply again to make the remaining changes")
+		}
+		fmt.Fprintf(w, "%3s %s\n", step.op, step.what)
+		step := step
+		if err := retryRateLimited(ctx, func() error { return step.apply(ctx) }); err != nil {
+			return fmt.Errorf("Failed to apply %s %s: %s", step.op, step.what, err)
+		}
+	}
+	creates, updates, deletes := p.count()
+	fmt.Fprintf(w, "\nApply complete: %d added, %d changed, %d deleted.\n", creates, updates, deletes)
+	return nil
+}
+
+// workspacePlanner compares a Workspace with the account.  Ids of teams and
+// rooms are kept as pointers, as those created by the plan only get an id
+// once the plan is applied.
+type workspacePlanner struct {
+	spark *api.Spark
+	self  string // email of the current user, never removed
+	plan  *workspacePlan
+	// roomIds holds the ids of the rooms in the workspace, by title.
+	roomIds map[string]*string
+}
+
+// planWorkspace returns the changes needed to bring the account in line with
+// ws.  Teams and standalone rooms that aren't listed are left alone, but rooms
+// of listed teams that aren't listed are deleted, as are webhooks that aren't
+// listed (when any are).
+func planWorkspace(ctx context.Context, spark *api.Spark, ws *Workspace) (*workspacePlan, error) {
+	p := &workspacePlanner{spark: spark, plan: &workspacePlan{}, roomIds: map[string]*string{}}
+	peopleService := spark.People
+	me, err := peopleService.GetMe(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(me.Emails) > 0 {
+		p.self = me.Emails[0]
+	}
+	if err := p.planTeams(ctx, ws.Teams); err != nil {
+		return nil, err
+	}
+	if err := p.planStandaloneRooms(ctx, ws.Rooms); err != nil {
+		return nil, err
+	}
+	if err := p.planWebhooks(ctx, ws.Webhooks); err != nil {
+		return nil, err
+	}
+	return p.plan, nil
+}
+
+func (p *workspacePlanner) planTeams(ctx context.Context, teams []WorkspaceTeam) error {
+	if len(teams) == 0 {
+		return nil
+	}
+	teamService := p.spark.Teams
+	existing, err := teamService.List(ctx)
+	if err != nil {
+		return err
+	}
+	byName := map[string]api.Team{}
+	for _, team := range *existing {
+		byName[team.Name] = team
+	}
+	for _, team := range teams {
+		label := fmt.Sprintf("team \"%s\"", team.Name)
+		id := new(string)
+		current, exists := byName[team.Name]
+		if exists {
+			*id = current.Id
+		} else {
+			name := team.Name
+			p.plan.add(planCreate, label, "", func(ctx context.Context) error {
+				created, err := teamService.Create(ctx, name)
+				if err == nil {
+					*id = created.Id
+				}
+				return err
+			})
+		}
+		if err := p.planTeamMembers(ctx, team, label, id, exists); err != nil {
+			return err
+		}
+
+		var rooms []api.Room
+		if exists {
+			all, err := p.spark.Rooms.ListAll(ctx, api.RoomFilter{TeamId: current.Id})
+			if err != nil {
+				return err
+			}
+			rooms = *all
+		}
+		if err := p.planRooms(ctx, team.Rooms, rooms, id, label, team.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *workspacePlanner) planTeamMembers(ctx context.Context, team WorkspaceTeam, label string, teamId *string, exists bool) error {
+	if len(team.Members) == 0 && len(team.Moderators) == 0 {
+		return nil
+	}
+	teamMemberService := p.spark.TeamMembers
+	// The creator of a team is its first moderator.
+	members := []api.Membership{{PersonEmail: p.self, IsModerator: true}}
+	if exists {
+		current, err := teamMemberService.List(ctx, *teamId)
+		if err != nil {
+			return err
+		}
+		members = nil
+		for _, ms := range *current {
+			members = append(members, api.Membership{Id: ms.Id, PersonEmail: ms.PersonEmail,
+				IsModerator: ms.IsModerator})
+		}
+	}
+	p.planMembers(members, workspaceRoster(team.Members, team.Moderators), label, func(ctx context.Context, action syncAction) error {
+		var err error
+		switch action.Kind {
+		case syncAdd:
+			_, err = teamMemberService.Create(ctx, *teamId, "", action.Email, action.Moderator)
+		case syncModerator:
+			_, err = teamMemberService.Update(ctx, action.MembershipId, action.Moderator)
+		case syncRemove:
+			err = teamMemberService.Delete(ctx, action.MembershipId)
+		}
+		return err
+	})
+	return nil
+}
+
+// planStandaloneRooms plans the rooms outside of teams.  Only group rooms are
+// considered, and those that aren't listed are never deleted.
+func (p *workspacePlanner) planStandaloneRooms(ctx context.Context, rooms []WorkspaceRoom) error {
+	if len(rooms) == 0 {
+		return nil
+	}
+	all, err := p.spark.Rooms.ListAll(ctx, api.RoomFilter{})
+	if err != nil {
+		return err
+	}
+	var existing []api.Room
+	for _, room := range *all {
+		if room.TeamId == "" && room.Type != "direct" {
+			existing = append(existing, room)
+		}
+	}
+	return p.planRooms(ctx, rooms, existing, nil, "", "")
+}
+
+// planRooms plans the rooms of a team (teamId isn't nil) or the standalone
+// rooms.  For a team, existing rooms that aren't listed are deleted, except
+// for the general room which carries the name of the team.
+func (p *workspacePlanner) planRooms(ctx context.Context, rooms []WorkspaceRoom, existing []api.Room, teamId *string, teamLabel string, teamName string) error {
+	roomService := p.spark.Rooms
+	byTitle := map[string]api.Room{}
+	for _, room := range existing {
+		if _, ok := byTitle[room.Title]; ok {
+			return fmt.Errorf("There are several rooms titled \"%s\", rename one of them first", room.Title)
+		}
+		byTitle[room.Title] = room
+	}
+	listed := map[string]bool{}
+	for _, room := range rooms {
+		listed[room.Title] = true
+		label := fmt.Sprintf("room \"%s\"", room.Title)
+		if teamId != nil {
+			label += " in " + teamLabel
+		}
+		id := new(string)
+		p.roomIds[room.Title] = id
+		current, exists := byTitle[room.Title]
+		if exists {
+			*id = current.Id
+		} else {
+			title := room.Title
+			p.plan.add(planCreate, label, "", func(ctx context.Context) error {
+				team := ""
+				if teamId != nil {
+					team = *teamId
+				}
+				created, err := roomService.CreateInTeam(ctx, title, team)
+				if err == nil {
+					*id = created.Id
+				}
+				return err
+			})
+		}
+		if err := p.planRoomMembers(ctx, room, label, id, exists); err != nil {
+			return err
+		}
+	}
+	if teamId == nil {
+		return nil
+	}
+	for _, room := range existing {
+		if listed[room.Title] || room.Title == teamName {
+			continue
+		}
+		roomId := room.Id
+		p.plan.add(planDelete, fmt.Sprintf("room \"%s\" in %s", room.Title, teamLabel), "", func(ctx context.Context) error {
+			return roomService.Delete(ctx, roomId)
+		})
+	}
+	return nil
+}
+
+func (p *workspacePlanner) planRoomMembers(ctx context.Context, room WorkspaceRoom, label string, roomId *string, exists bool) error {
+	if len(room.Members) == 0 && len(room.Moderators) == 0 {
+		return nil
+	}
+	memberService := p.spark.Memberships
+	// The creator of a room is its first member.
+	members := []api.Membership{{PersonEmail: p.self}}
+	if exists {
+		current, err := memberService.ListAll(ctx, *roomId)
+		if err != nil {
+			return err
+		}
+		members = *current
+	}
+	p.planMembers(members, workspaceRoster(room.Members, room.Moderators), label, func(ctx context.Context, action syncAction) error {
+		var err error
+		switch action.Kind {
+		case syncAdd:
+			_, err = memberService.Create(ctx, *roomId, "", action.Email, action.Moderator)
+		case syncModerator:
+			_, err = memberService.Update(ctx, action.MembershipId, action.Moderator)
+		case syncRemove:
+			err = memberService.Delete(ctx, action.MembershipId)
+		}
+		return err
+	})
+	return nil
+}
+
+// planMembers adds the steps to bring members in line with roster, using
+// apply to make a change.
+func (p *workspacePlanner) planMembers(members []api.Membership, roster []rosterEntry, label string, apply func(context.Context, syncAction) error) {
+	actions, _ := planSync(members, roster, true, p.self)
+	for _, action := range actions {
+		action := action
+		what := fmt.Sprintf("member %s of %s", action.Email, label)
+		switch action.Kind {
+		case syncAdd:
+			detail := ""
+			if action.Moderator {
+				detail = "moderator"
+			}
+			p.plan.add(planCreate, what, detail, func(ctx context.Context) error { return apply(ctx, action) })
+		case syncModerator:
+			detail := fmt.Sprintf("moderator %t -> %t", !action.Moderator, action.Moderator)
+			p.plan.add(planUpdate, what, detail, func(ctx context.Context) error { return apply(ctx, action) })
+		case syncRemove:
+			p.plan.add(planDelete, what, "", func(ctx context.Context) error { return apply(ctx, action) })
+		}
+	}
+}
+
+// planWebhooks plans the webhooks.  They're only managed when the workspace
+// lists any.
+func (p *workspacePlanner) planWebhooks(ctx context.Context, hooks []WorkspaceWebhook) error {
+	if len(hooks) == 0 {
+		return nil
+	}
+	webhookService := p.spark.Webhooks
+	existing, err := webhookService.List(ctx)
+	if err != nil {
+		return err
+	}
+	byName := map[string]api.Webhook{}
+	for _, hook := range *existing {
+		byName[hook.Name] = hook
+	}
+	listed := map[string]bool{}
+	for _, hook := range hooks {
+		listed[hook.Name] = true
+		hook := hook
+		label := fmt.Sprintf("webhook \"%s\"", hook.Name)
+		// The filter is only known once the room it refers to exists.
+		filter := func() string {
+			if hook.Room == "" {
+				return hook.Filter
+			}
+			roomFilter := "roomId=" + *p.roomIds[hook.Room]
+			if hook.Filter == "" {
+				return roomFilter
+			}
+			return roomFilter + "&" + hook.Filter
+		}
+		plannedFilter := filter()
+		if hook.Room != "" && *p.roomIds[hook.Room] == "" {
+			plannedFilter = strings.Replace(plannedFilter, "roomId=", "roomId=(known after apply)", 1)
+		}
+		create := func(ctx context.Context) error {
+			_, err := webhookService.Create(ctx, api.Webhook{Name: hook.Name, TargetUrl: hook.TargetUrl,
+				Resource: hook.Resource, Event: hook.Event, Filter: filter(), Secret: hook.Secret})
+			return err
+		}
+
+		current, exists := byName[hook.Name]
+		if !exists {
+			p.plan.add(planCreate, label, hook.Resource+" "+hook.Event, create)
+			continue
+		}
+		var replaced []string
+		if current.Resource != hook.Resource {
+			replaced = append(replaced, fmt.Sprintf("resource %s -> %s", current.Resource, hook.Resource))
+		}
+		if current.Event != hook.Event {
+			replaced = append(replaced, fmt.Sprintf("event %s -> %s", current.Event, hook.Event))
+		}
+		if current.Filter != plannedFilter {
+			replaced = append(replaced, fmt.Sprintf("filter '%s' -> '%s'", current.Filter, plannedFilter))
+		}
+		id := current.Id
+		if len(replaced) > 0 {
+			p.plan.add(planReplace, label, strings.Join(replaced, ", "), func(ctx context.Context) error {
+				if err := webhookService.Delete(ctx, id); err != nil {
+					return err
+				}
+				return create(ctx)
+			})
+		} else if current.TargetUrl != hook.TargetUrl {
+			p.plan.add(planUpdate, label, fmt.Sprintf("targetUrl %s -> %s", current.TargetUrl, hook.TargetUrl),
+				func(ctx context.Context) error {
+					_, err := webhookService.Update(ctx, id, hook.Name, hook.TargetUrl)
+					return err
+				})
+		}
+	}
+	for _, hook := range *existing {
+		if listed[hook.Name] {
+			continue
+		}
+		id := hook.Id
+		p.plan.add(planDelete, fmt.Sprintf("webhook \"%s\"", hook.Name), hook.Resource+" "+hook.Event, func(ctx context.Context) error {
+			return webhookService.Delete(ctx, id)
+		})
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tdeckers/sparkcli/api"
+	"github.com/tdeckers/sparkcli/sparktest"
+	"github.com/tdeckers/sparkcli/util"
+)
+
+func TestWorkspaceCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		ws      Workspace
+		wantErr bool
+	}{
+		{"valid", Workspace{
+			Teams: []WorkspaceTeam{{Name: "Ops", Rooms: []WorkspaceRoom{{Title: "alerts"}}}},
+			Rooms: []WorkspaceRoom{{Title: "lobby"}},
+			Webhooks: []WorkspaceWebhook{{Name: "bot", TargetUrl: "https://x", Resource: "messages",
+				Event: "created", Room: "alerts"}},
+		}, false},
+		{"duplicate team", Workspace{Teams: []WorkspaceTeam{{Name: "Ops"}, {Name: "Ops"}}}, true},
+		{"room without title", Workspace{Rooms: []WorkspaceRoom{{}}}, true},
+		{"same title in two teams", Workspace{Teams: []WorkspaceTeam{
+			{Name: "A", Rooms: []WorkspaceRoom{{Title: "general"}}},
+			{Name: "B", Rooms: []WorkspaceRoom{{Title: "general"}}},
+		}}, false},
+		{"ambiguous webhook room", Workspace{
+			Teams: []WorkspaceTeam{
+				{Name: "A", Rooms: []WorkspaceRoom{{Title: "general"}}},
+				{Name: "B", Rooms: []WorkspaceRoom{{Title: "general"}}},
+			},
+			Webhooks: []WorkspaceWebhook{{Name: "bot", TargetUrl: "https://x", Resource: "messages",
+				Event: "created", Room: "general"}},
+		}, true},
+		{"unknown webhook room", Workspace{Webhooks: []WorkspaceWebhook{{Name: "bot",
+			TargetUrl: "https://x", Resource: "messages", Event: "created", Room: "nope"}}}, true},
+		{"incomplete webhook", Workspace{Webhooks: []WorkspaceWebhook{{Name: "bot"}}}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.ws.check(); (err != nil) != tt.wantErr {
+			t.Errorf("%q. Workspace.check() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func Test_workspaceRoster(t *testing.T) {
+	roster := workspaceRoster([]string{"a@x.com", "B@x.com"}, []string{"b@x.com"})
+	if len(roster) != 2 || roster[0] != (rosterEntry{"b@x.com", true}) || roster[1] != (rosterEntry{"a@x.com", false}) {
+		t.Errorf("workspaceRoster() = %v", roster)
+	}
+}
+
+// newTestPlanner returns a workspacePlanner for the account of server.
+func newTestPlanner(t *testing.T, server *sparktest.Server) *workspacePlanner {
+	client, err := util.NewClient(server.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &workspacePlanner{spark: api.NewSpark(client), self: sparktest.MeEmail,
+		plan: &workspacePlan{}, roomIds: map[string]*string{}}
+}
+
+// planSteps returns the steps of plan as "op what" lines.
+func planSteps(plan *workspacePlan) string {
+	var steps []string
+	for _, step := range plan.steps {
+		steps = append(steps, step.op+" "+step.what)
+	}
+	return strings.Join(steps, "\n")
+}
+
+func TestPlanTeams(t *testing.T) {
+	ctx := context.Background()
+	server := sparktest.NewServer()
+	defer server.Close()
+	p := newTestPlanner(t, server)
+	ops := server.AddTeam("Ops")
+	if _, err := p.spark.Rooms.CreateInTeam(ctx, "old", ops.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	teams := []WorkspaceTeam{
+		{Name: "Ops", Members: []string{"anna@example.com"}, Rooms: []WorkspaceRoom{{Title: "alerts"}}},
+		{Name: "Dev"},
+	}
+	if err := p.planTeams(ctx, teams); err != nil {
+		t.Fatalf("planTeams() error = %v", err)
+	}
+	want := strings.Join([]string{
+		`+ member anna@example.com of team "Ops"`,
+		`+ room "alerts" in team "Ops"`,
+		`- room "old" in team "Ops"`,
+		`+ team "Dev"`,
+	}, "\n")
+	if got := planSteps(p.plan); got != want {
+		t.Errorf("planTeams() planned\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestPlanStandaloneRooms(t *testing.T) {
+	ctx := context.Background()
+	server := sparktest.NewServer()
+	defer server.Close()
+	p := newTestPlanner(t, server)
+	lobby := server.AddRoom("lobby")
+	server.AddRoom("stale")
+	server.AddTeam("Ops")
+
+	rooms := []WorkspaceRoom{{Title: "lobby", Members: []string{"bob@example.com"}}, {Title: "new"}, {Title: "Ops"}}
+	if err := p.planStandaloneRooms(ctx, rooms); err != nil {
+		t.Fatalf("planStandaloneRooms() error = %v", err)
+	}
+	// Unlisted rooms are left alone, and the general room of a team isn't a
+	// standalone room.
+	want := strings.Join([]string{
+		`+ member bob@example.com of room "lobby"`,
+		`+ room "new"`,
+		`+ room "Ops"`,
+	}, "\n")
+	if got := planSteps(p.plan); got != want {
+		t.Errorf("planStandaloneRooms() planned\n%s\nwant\n%s", got, want)
+	}
+	if id := p.roomIds["lobby"]; id == nil || *id != lobby.Id {
+		t.Errorf("planStandaloneRooms() room id of lobby = %v, want %s", id, lobby.Id)
+	}
+}